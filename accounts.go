@@ -0,0 +1,496 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"log"
+	"net/http"
+	"rastros-da-mata/auth"
+	"rastros-da-mata/crud"
+	"rastros-da-mata/mail"
+	"time"
+)
+
+// Validade dos tokens emitidos no login
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+	resetTokenTTL   = time.Hour
+)
+
+// Limite do Argon2id: cada hash usa 64 MB, então uma rajada de cadastros, logins ou
+// redefinições esgotaria a memória do servidor se todos rodassem ao mesmo tempo
+const (
+	maxPasswordHashes = 4
+	passwordHashWait  = 5 * time.Second
+)
+
+// passwordSlots - vagas para o cálculo simultâneo do Argon2id
+var passwordSlots = make(chan struct{}, maxPasswordHashes)
+
+// errPasswordBusy - nenhuma vaga para o Argon2id foi liberada dentro de passwordHashWait
+var errPasswordBusy = errors.New("servidor ocupado com outras senhas")
+
+// accounts - contas de usuário com login por e-mail e senha
+type accounts struct {
+	users  auth.UserStore
+	mailer mail.Mailer
+	// resetURL - página que recebe o token de redefinição de senha (PASSWORD_RESET_URL)
+	resetURL string
+}
+
+// registerRequest - corpo do cadastro de usuário
+type registerRequest struct {
+	Email    string `json:"email" validate:"required,email,max=254"`
+	Name     string `json:"name" validate:"required,max=100"`
+	Password string `json:"password" validate:"required,min=10,max=256"`
+}
+
+// tokenResponse - tokens emitidos no login e na renovação
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// dummyHash - hash conferido quando o e-mail não existe, para que o tempo de resposta não revele os cadastrados
+var dummyHash, _ = auth.HashPassword("rastros-da-mata")
+
+// withPasswordSlot - executa fn quando houver vaga em passwordSlots, desistindo depois de
+// passwordHashWait ou quando a requisição for cancelada
+func withPasswordSlot(ctx context.Context, fn func()) error {
+	timer := time.NewTimer(passwordHashWait)
+	defer timer.Stop()
+
+	select {
+	case passwordSlots <- struct{}{}:
+	case <-timer.C:
+		return errPasswordBusy
+	case <-ctx.Done():
+		return errPasswordBusy
+	}
+
+	defer func() { <-passwordSlots }()
+
+	fn()
+	return nil
+}
+
+// hashPassword - auth.HashPassword dentro do limite de passwordSlots
+func hashPassword(ctx context.Context, password string) (hash string, err error) {
+	if slotErr := withPasswordSlot(ctx, func() { hash, err = auth.HashPassword(password) }); slotErr != nil {
+		return "", slotErr
+	}
+	return hash, err
+}
+
+// checkPassword - auth.CheckPassword dentro do limite de passwordSlots
+func checkPassword(ctx context.Context, hash, password string) (ok bool, err error) {
+	err = withPasswordSlot(ctx, func() { ok = auth.CheckPassword(hash, password) })
+	return ok, err
+}
+
+// register - cadastra um usuário, inicialmente sem papéis (somente leitura)
+//
+// O primeiro administrador é definido pelo comando "grant-admin" (ver grantAdmin).
+func (app *App) register(w http.ResponseWriter, r *http.Request) {
+	var body registerRequest
+
+	if !decodeBody(w, r, &body) {
+		return
+	}
+
+	hash, err := hashPassword(r.Context(), body.Password)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	user := &auth.User{
+		Email:        auth.NormalizeEmail(body.Email),
+		Name:         body.Name,
+		PasswordHash: hash,
+		Roles:        []string{},
+		CreatedAt:    time.Now().UTC(),
+	}
+
+	err = app.accounts.users.CreateUser(r.Context(), user)
+
+	if errors.Is(err, crud.ErrConflict) {
+		writeProblem(w, r, newProblem(http.StatusConflict, codeConflict, "E-mail já cadastrado"))
+		return
+	}
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, user)
+}
+
+// login - troca e-mail e senha por um token de acesso e um token de renovação
+func (app *App) login(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Email    string `json:"email" validate:"required"`
+		Password string `json:"password" validate:"required"`
+	}
+
+	if !decodeBody(w, r, &body) {
+		return
+	}
+
+	user, err := app.accounts.users.UserByEmail(r.Context(), body.Email)
+
+	if err != nil && !errors.Is(err, crud.ErrNotFound) {
+		writeError(w, r, err)
+		return
+	}
+
+	hash := dummyHash
+
+	if user != nil {
+		hash = user.PasswordHash
+	}
+
+	ok, err := checkPassword(r.Context(), hash, body.Password)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if user == nil || !ok {
+		writeUnauthorized(w, r, "", "E-mail ou senha inválidos")
+		return
+	}
+
+	app.writeTokens(w, r, user, primitive.NewObjectID())
+}
+
+// refresh - troca o token de renovação por um novo par de tokens (rotação)
+//
+// Cada token de renovação vale uma única vez; o reuso de um token já trocado
+// indica vazamento e encerra todas as sessões da mesma família.
+func (app *App) refresh(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		RefreshToken string `json:"refresh_token" validate:"required"`
+	}
+
+	if !decodeBody(w, r, &body) {
+		return
+	}
+
+	users := app.accounts.users
+	token, err := users.TokenByHash(r.Context(), auth.TokenRefresh, auth.HashKey(body.RefreshToken))
+
+	if errors.Is(err, crud.ErrNotFound) {
+		writeUnauthorized(w, r, "invalid_token", "Token de renovação inválido")
+		return
+	}
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	now := time.Now().UTC()
+
+	if token.UsedAt != nil && token.RevokedAt == nil {
+		app.revokeFamily(r.Context(), token.Family, now)
+	}
+
+	if !token.Usable(now) {
+		writeUnauthorized(w, r, "invalid_token", "Token de renovação expirado, revogado ou já usado")
+		return
+	}
+
+	if err := users.UseToken(r.Context(), token.ID, now); err != nil {
+		// outra requisição usou o token ao mesmo tempo
+		if errors.Is(err, crud.ErrConflict) {
+			app.revokeFamily(r.Context(), token.Family, now)
+			writeUnauthorized(w, r, "invalid_token", "Token de renovação já usado")
+			return
+		}
+
+		writeError(w, r, err)
+		return
+	}
+
+	user, err := users.UserByID(r.Context(), token.UserID)
+
+	if errors.Is(err, crud.ErrNotFound) {
+		writeUnauthorized(w, r, "invalid_token", "Usuário não encontrado")
+		return
+	}
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	app.writeTokens(w, r, user, token.Family)
+}
+
+// logout - revoga o token de renovação e os demais da mesma sessão
+func (app *App) logout(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		RefreshToken string `json:"refresh_token" validate:"required"`
+	}
+
+	if !decodeBody(w, r, &body) {
+		return
+	}
+
+	token, err := app.accounts.users.TokenByHash(r.Context(), auth.TokenRefresh, auth.HashKey(body.RefreshToken))
+
+	if err != nil && !errors.Is(err, crud.ErrNotFound) {
+		writeError(w, r, err)
+		return
+	}
+
+	if token != nil {
+		app.revokeFamily(r.Context(), token.Family, time.Now().UTC())
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// forgotPassword - envia por e-mail um token de redefinição de senha
+//
+// A resposta é sempre 202, exista ou não o e-mail, para não revelar os cadastrados.
+func (app *App) forgotPassword(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Email string `json:"email" validate:"required"`
+	}
+
+	if !decodeBody(w, r, &body) {
+		return
+	}
+
+	user, err := app.accounts.users.UserByEmail(r.Context(), body.Email)
+
+	if err == nil {
+		err = app.sendReset(r.Context(), user)
+	}
+
+	if err != nil && !errors.Is(err, crud.ErrNotFound) {
+		log.Printf("Erro ao enviar a redefinição de senha: %v", err)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// sendReset - cria o token de redefinição e o envia ao usuário
+func (app *App) sendReset(ctx context.Context, user *auth.User) error {
+	now := time.Now().UTC()
+	token := &auth.Token{Kind: auth.TokenReset, UserID: user.ID, CreatedAt: now, ExpiresAt: now.Add(resetTokenTTL)}
+
+	plain, err := auth.NewToken(token)
+	if err != nil {
+		return err
+	}
+
+	if err := app.accounts.users.SaveToken(ctx, token); err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Olá, %s.\n\nRecebemos um pedido para redefinir a sua senha. Use o token abaixo em até %d minutos:\n\n%s\n",
+		user.Name, int(resetTokenTTL.Minutes()), plain)
+
+	if app.accounts.resetURL != "" {
+		body += "\nOu acesse: " + app.accounts.resetURL + "?token=" + plain + "\n"
+	}
+
+	body += "\nSe você não fez o pedido, ignore esta mensagem.\n"
+
+	return app.accounts.mailer.Send(ctx, mail.Message{To: user.Email, Subject: "Redefinição de senha", Body: body})
+}
+
+// resetPassword - define uma nova senha com o token recebido por e-mail e encerra as sessões abertas
+func (app *App) resetPassword(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Token    string `json:"token" validate:"required"`
+		Password string `json:"password" validate:"required,min=10,max=256"`
+	}
+
+	if !decodeBody(w, r, &body) {
+		return
+	}
+
+	users := app.accounts.users
+	now := time.Now().UTC()
+	invalid := &crud.ValidationError{Fields: []crud.FieldError{{Field: "token", Code: "invalid_token", Message: "token inválido, expirado ou já usado"}}}
+
+	token, err := users.TokenByHash(r.Context(), auth.TokenReset, auth.HashKey(body.Token))
+
+	if errors.Is(err, crud.ErrNotFound) || (err == nil && !token.Usable(now)) {
+		writeError(w, r, invalid)
+		return
+	}
+
+	var hash string
+
+	if err == nil {
+		hash, err = hashPassword(r.Context(), body.Password)
+	}
+
+	if err == nil {
+		err = users.SetPassword(r.Context(), token.UserID, hash)
+	}
+
+	// o token só é consumido depois que a senha foi trocada, para que uma falha
+	// do armazenamento não obrigue o usuário a pedir outro e-mail
+	if err == nil {
+		err = users.UseToken(r.Context(), token.ID, now)
+	}
+
+	if errors.Is(err, crud.ErrConflict) {
+		writeError(w, r, invalid)
+		return
+	}
+
+	if err == nil {
+		err = users.RevokeUserTokens(r.Context(), token.UserID, now)
+	}
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listUsers - lista os usuários cadastrados
+func (app *App) listUsers(w http.ResponseWriter, r *http.Request) {
+	if !app.auth.allow(w, r, auth.ActionManageUsers, auth.AllCategories) {
+		return
+	}
+
+	users, err := app.accounts.users.ListUsers(r.Context())
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"data": users,
+	})
+}
+
+// setRoles - substitui os papéis do usuário; valem a partir da próxima renovação do token
+func (app *App) setRoles(w http.ResponseWriter, r *http.Request) {
+	if !app.auth.allow(w, r, auth.ActionManageUsers, auth.AllCategories) {
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+
+	if err != nil {
+		writeInvalidID(w, r)
+		return
+	}
+
+	var body struct {
+		Roles []string `json:"roles" validate:"unique"`
+	}
+
+	if !decodeBody(w, r, &body) {
+		return
+	}
+
+	var invalid []crud.FieldError
+
+	for i, grant := range auth.ParseGrants(body.Roles) {
+		known := grant.Role == auth.RoleEditor || grant.Role == auth.RoleReviewer || grant.Role == auth.RoleAdmin
+
+		if !known || (grant.Category != auth.AllCategories && app.category(grant.Category) == nil) {
+			invalid = append(invalid, crud.FieldError{Field: fmt.Sprintf("roles[%d]", i), Code: "unknown_role", Message: "papel ou categoria desconhecido: " + body.Roles[i]})
+		}
+	}
+
+	if len(invalid) > 0 {
+		writeError(w, r, &crud.ValidationError{Fields: invalid})
+		return
+	}
+
+	if body.Roles == nil {
+		body.Roles = []string{}
+	}
+
+	user, err := app.accounts.users.SetRoles(r.Context(), id, body.Roles)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, user)
+}
+
+// writeTokens - emite o token de acesso e um novo token de renovação da família
+func (app *App) writeTokens(w http.ResponseWriter, r *http.Request, user *auth.User, family primitive.ObjectID) {
+	now := time.Now().UTC()
+
+	access, err := app.auth.verifier.Sign(auth.Claims{
+		Subject:   "user:" + user.ID.Hex(),
+		ExpiresAt: now.Add(accessTokenTTL).Unix(),
+		Scope:     app.auth.writeScope,
+		Roles:     user.Roles,
+	})
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	token := &auth.Token{Kind: auth.TokenRefresh, UserID: user.ID, Family: family, CreatedAt: now, ExpiresAt: now.Add(refreshTokenTTL)}
+	refresh, err := auth.NewToken(token)
+
+	if err == nil {
+		err = app.accounts.users.SaveToken(r.Context(), token)
+	}
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, tokenResponse{
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(accessTokenTTL.Seconds()),
+		RefreshToken: refresh,
+	})
+}
+
+// revokeFamily - revoga os tokens de renovação da família; falhas só são registradas
+func (app *App) revokeFamily(ctx context.Context, family primitive.ObjectID, at time.Time) {
+	if err := app.accounts.users.RevokeFamily(ctx, family, at); err != nil {
+		log.Printf("Erro ao revogar os tokens da família %s: %v", family.Hex(), err)
+	}
+}
+
+// decodeBody - decodifica e valida o corpo JSON; quando false, a resposta de erro já foi escrita
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(v); err != nil {
+		writeBodyError(w, r, err)
+		return false
+	}
+
+	if err := crud.Validate(v); err != nil {
+		writeError(w, r, err)
+		return false
+	}
+
+	return true
+}
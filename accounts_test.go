@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"net/http/httptest"
+	"rastros-da-mata/auth"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failingPasswordStore - usuários em memória cuja troca de senha falha
+type failingPasswordStore struct {
+	*auth.MemoryUserStore
+}
+
+func (failingPasswordStore) SetPassword(context.Context, primitive.ObjectID, string) error {
+	return errors.New("armazenamento indisponível")
+}
+
+func TestResetPasswordKeepsTokenOnFailure(t *testing.T) {
+	ctx := context.Background()
+	users := auth.NewMemoryUserStore()
+
+	user := &auth.User{Email: "ana@example.com", Name: "Ana", Roles: []string{}, CreatedAt: time.Now()}
+	if err := users.CreateUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+
+	token := &auth.Token{Kind: auth.TokenReset, UserID: user.ID, CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}
+	plain, err := auth.NewToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.SaveToken(ctx, token); err != nil {
+		t.Fatal(err)
+	}
+
+	reset := func(store auth.UserStore) *httptest.ResponseRecorder {
+		app := &App{accounts: &accounts{users: store}}
+		body := `{"token":"` + plain + `","password":"uma senha nova e longa"}`
+		w := httptest.NewRecorder()
+		app.resetPassword(w, httptest.NewRequest("POST", "/api/auth/reset", strings.NewReader(body)))
+		return w
+	}
+
+	w := reset(failingPasswordStore{users})
+	expectStatus(t, "reset com falha", w.Code, http.StatusInternalServerError, w.Body.String())
+
+	// a falha não pode consumir o token: o usuário tenta de novo com o mesmo e-mail
+	w = reset(users)
+	expectStatus(t, "reset repetido", w.Code, http.StatusNoContent, w.Body.String())
+
+	w = reset(users)
+	expectStatus(t, "reset com token usado", w.Code, http.StatusUnprocessableEntity, w.Body.String())
+}
+
+func TestPasswordSlots(t *testing.T) {
+	for i := 0; i < maxPasswordHashes; i++ {
+		passwordSlots <- struct{}{}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := hashPassword(ctx, "uma senha qualquer")
+
+	for i := 0; i < maxPasswordHashes; i++ {
+		<-passwordSlots
+	}
+
+	if !errors.Is(err, errPasswordBusy) {
+		t.Fatalf("hashPassword sem vagas = %v, esperado errPasswordBusy", err)
+	}
+
+	if _, err := hashPassword(context.Background(), "uma senha qualquer"); err != nil {
+		t.Fatalf("hashPassword com vagas: %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"rastros-da-mata/auth"
+	"rastros-da-mata/crud"
+	"time"
+)
+
+// apiKeyRequest - corpo da criação de uma chave de API
+type apiKeyRequest struct {
+	Name       string     `json:"name" validate:"required,max=100"`
+	ReadOnly   bool       `json:"read_only"`
+	Categories []string   `json:"categories" validate:"unique"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+// createKey - gera uma chave de API; a chave em texto aparece somente nesta resposta
+func (app *App) createKey(w http.ResponseWriter, r *http.Request) {
+	if !app.auth.allow(w, r, auth.ActionManageKeys, auth.AllCategories) {
+		return
+	}
+
+	var body apiKeyRequest
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&body); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+
+	var invalid []crud.FieldError
+	var verr *crud.ValidationError
+
+	if err := crud.Validate(&body); errors.As(err, &verr) {
+		invalid = verr.Fields
+	} else if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	for _, category := range body.Categories {
+		if app.category(category) == nil {
+			invalid = append(invalid, crud.FieldError{Field: "categories", Code: "unknown_category", Message: "categoria desconhecida: " + category})
+		}
+	}
+
+	if body.ExpiresAt != nil && !body.ExpiresAt.After(time.Now()) {
+		invalid = append(invalid, crud.FieldError{Field: "expires_at", Code: "past", Message: "deve estar no futuro"})
+	}
+
+	if len(invalid) > 0 {
+		writeError(w, r, &crud.ValidationError{Fields: invalid})
+		return
+	}
+
+	claims, _ := auth.FromContext(r.Context())
+
+	key := &auth.APIKey{
+		Name:       body.Name,
+		ReadOnly:   body.ReadOnly,
+		Categories: body.Categories,
+		CreatedBy:  claims.Subject,
+		CreatedAt:  time.Now().UTC(),
+		ExpiresAt:  body.ExpiresAt,
+	}
+
+	plain, err := auth.NewAPIKey(key)
+
+	if err == nil {
+		err = app.auth.keys.Create(r.Context(), key)
+	}
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, struct {
+		*auth.APIKey
+		Key string `json:"key"`
+	}{key, plain})
+}
+
+// listKeys - lista as chaves de API, inclusive revogadas e expiradas, sem os segredos
+func (app *App) listKeys(w http.ResponseWriter, r *http.Request) {
+	if !app.auth.allow(w, r, auth.ActionManageKeys, auth.AllCategories) {
+		return
+	}
+
+	keys, err := app.auth.keys.List(r.Context())
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"data": keys,
+	})
+}
+
+// revokeKey - revoga a chave de API, que deixa de ser aceita imediatamente
+func (app *App) revokeKey(w http.ResponseWriter, r *http.Request) {
+	if !app.auth.allow(w, r, auth.ActionManageKeys, auth.AllCategories) {
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+
+	if err != nil {
+		writeInvalidID(w, r)
+		return
+	}
+
+	if err := app.auth.keys.Revoke(r.Context(), id, time.Now().UTC()); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// category - categoria registrada com o nome informado, ou nil
+func (app *App) category(name string) category {
+	for _, c := range app.categories {
+		if c.name() == name {
+			return c
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"log"
+	"net/http"
+	"rastros-da-mata/auth"
+	"rastros-da-mata/crud"
+	"time"
+)
+
+// requestIDHeader - cabeçalho com o ID da requisição, aceito do cliente ou gerado pela API
+const requestIDHeader = "X-Request-ID"
+
+// auditRetryInterval - frequência das novas tentativas de gravar os registros de auditoria pendentes
+const auditRetryInterval = time.Minute
+
+// maxRequestIDLength - tamanho máximo do X-Request-ID aceito do cliente
+const maxRequestIDLength = 64
+
+// auditParams - parâmetros aceitos na consulta ao histórico de auditoria
+var auditParams = map[string]bool{"actor": true, "category": true, "document": true, "from": true, "to": true, "limit": true, "cursor": true}
+
+// requestID - middleware que identifica cada requisição, devolvendo o ID na resposta e
+// guardando-o no contexto para o histórico de auditoria
+func requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+
+		if !validRequestID(id) {
+			b := make([]byte, 16)
+			_, _ = rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+
+		w.Header().Set(requestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(crud.WithRequestID(r.Context(), id)))
+	})
+}
+
+// validRequestID - aceita do cliente somente IDs curtos de letras, dígitos e ".", "_", ":" ou "-",
+// para que o histórico de auditoria não guarde textos arbitrários
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '.', c == '_', c == ':', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// retryAudit - grava os registros de auditoria que falharam, alertando enquanto houver pendentes
+func (app *App) retryAudit(ctx context.Context) {
+	if app.auditor.Status().Pending == 0 {
+		return
+	}
+
+	n, err := app.auditor.Retry(ctx)
+
+	if n > 0 {
+		log.Printf("%d registros de auditoria pendentes gravados", n)
+	}
+
+	if err != nil {
+		log.Printf("ALERTA: %d registros de auditoria continuam pendentes: %v", app.auditor.Status().Pending, err)
+	}
+}
+
+// auditLog - consulta o histórico de alterações, dos registros mais recentes aos mais antigos
+//
+// Filtros: actor, category, document (ID), from e to (RFC 3339, to exclusivo). O campo
+// "status" mostra os registros que ainda não foram gravados por falha do armazenamento.
+func (app *App) auditLog(w http.ResponseWriter, r *http.Request) {
+	if !app.auth.allow(w, r, auth.ActionViewAudit, auth.AllCategories) {
+		return
+	}
+
+	query := r.URL.Query()
+
+	for name := range query {
+		if !auditParams[name] {
+			writeInvalidParameter(w, r, name, "Parâmetro desconhecido")
+			return
+		}
+	}
+
+	q := crud.AuditQuery{Actor: query.Get("actor"), Category: query.Get("category")}
+
+	limit, qerr := pageSize(query)
+
+	if qerr != nil {
+		writeInvalidParameter(w, r, qerr.param, qerr.detail)
+		return
+	}
+
+	q.Limit = limit + 1
+
+	for name, target := range map[string]*primitive.ObjectID{"document": &q.DocumentID, "cursor": &q.Before} {
+		if value := query.Get(name); value != "" {
+			id, err := primitive.ObjectIDFromHex(value)
+
+			if err != nil {
+				writeInvalidParameter(w, r, name, "Use um ID válido")
+				return
+			}
+
+			*target = id
+		}
+	}
+
+	for name, target := range map[string]*time.Time{"from": &q.From, "to": &q.To} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+
+			if err != nil {
+				writeInvalidParameter(w, r, name, "Use uma data no formato RFC 3339, como 2024-01-31T12:00:00Z")
+				return
+			}
+
+			*target = t
+		}
+	}
+
+	entries, err := app.audit.Query(r.Context(), q)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	next := ""
+
+	// o registro a mais indica que há outra página
+	if int64(len(entries)) > limit {
+		entries = entries[:limit]
+		next = entries[limit-1].ID.Hex()
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"data":        entries,
+		"limit":       limit,
+		"next_cursor": next,
+		"status":      app.auditor.Status(),
+	})
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"rastros-da-mata/auth"
+	"rastros-da-mata/crud"
+	"strings"
+	"time"
+)
+
+// authConfig - regras de autenticação das rotas da API
+type authConfig struct {
+	verifier *auth.Verifier
+	// publicReads - GET e HEAD dispensam o token
+	publicReads bool
+	// writeScope - escopo exigido nas escritas; vazio aceita qualquer token válido
+	writeScope string
+	// policy - ações permitidas a cada papel
+	policy *auth.Policy
+	// keys - chaves de API aceitas no cabeçalho apiKeyHeader
+	keys auth.KeyStore
+}
+
+// apiKeyHeader - cabeçalho das chaves de API dos clientes automatizados
+const apiKeyHeader = "X-API-Key"
+
+// keyTouchInterval - intervalo mínimo entre os registros de último uso de uma chave
+const keyTouchInterval = time.Minute
+
+// authenticate - middleware que verifica o token Bearer ou a chave de API das rotas /api
+//
+// Credenciais presentes são sempre verificadas, mesmo nas leituras públicas. As
+// declarações do token ou da chave ficam no contexto da requisição (ver auth.FromContext).
+func (app *App) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// o cadastro e o login são as rotas que emitem as credenciais
+		if app.auth == nil || !strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/api/auth/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		read := r.Method == http.MethodGet || r.Method == http.MethodHead
+		token, found := bearerToken(r)
+		key := r.Header.Get(apiKeyHeader)
+
+		if !found && key == "" {
+			if read && app.auth.publicReads {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			writeUnauthorized(w, r, "", "Envie um token no cabeçalho Authorization: Bearer")
+			return
+		}
+
+		var claims *auth.Claims
+		var err error
+
+		if key != "" {
+			claims, err = app.auth.apiKeyClaims(r.Context(), key)
+		} else {
+			claims, err = app.auth.verifier.Verify(token)
+		}
+
+		switch {
+		case errors.Is(err, crud.ErrNotFound):
+			writeUnauthorized(w, r, "", "Chave de API inválida, revogada ou expirada")
+			return
+		case errors.Is(err, auth.ErrExpiredToken):
+			writeUnauthorized(w, r, "invalid_token", "O token expirou")
+			return
+		case errors.Is(err, auth.ErrInvalidToken):
+			writeUnauthorized(w, r, "invalid_token", "Token inválido")
+			return
+		case err != nil:
+			writeError(w, r, err)
+			return
+		}
+
+		if !read && app.auth.writeScope != "" && !claims.HasScope(app.auth.writeScope) {
+			w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+app.auth.writeScope+`"`)
+			writeProblem(w, r, newProblem(http.StatusForbidden, codeForbidden, "O token não concede o escopo "+app.auth.writeScope))
+			return
+		}
+
+		ctx := crud.WithActor(auth.WithClaims(r.Context(), claims), claims.Subject)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// apiKeyClaims - declarações da chave de API ativa; crud.ErrNotFound para chaves desconhecidas, revogadas ou expiradas
+func (a *authConfig) apiKeyClaims(ctx context.Context, plain string) (*auth.Claims, error) {
+	key, err := a.keys.FindByHash(ctx, auth.HashKey(plain))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	if !key.Active(now) {
+		return nil, crud.ErrNotFound
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= keyTouchInterval {
+		if err := a.keys.Touch(ctx, key.ID, now); err != nil {
+			log.Printf("Erro ao registrar o uso da chave %s: %v", key.ID.Hex(), err)
+		}
+	}
+
+	return key.Claims(a.writeScope), nil
+}
+
+// bearerToken - token do cabeçalho Authorization no esquema Bearer (RFC 6750)
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// writeUnauthorized - responde 401 com o desafio Bearer
+func writeUnauthorized(w http.ResponseWriter, r *http.Request, code, detail string) {
+	challenge := `Bearer realm="rastros-da-mata"`
+	if code != "" {
+		challenge += `, error="` + code + `"`
+	}
+
+	w.Header().Set("WWW-Authenticate", challenge)
+	writeProblem(w, r, newProblem(http.StatusUnauthorized, codeUnauthorized, detail))
+}
+
+// allow - consulta a política de acesso para a ação na categoria; quando negada,
+// escreve 401 (sem token) ou 403 e retorna false
+func (a *authConfig) allow(w http.ResponseWriter, r *http.Request, action auth.Action, category string) bool {
+	if a == nil {
+		return true
+	}
+
+	claims, ok := auth.FromContext(r.Context())
+
+	if !ok && action != auth.ActionRead {
+		writeUnauthorized(w, r, "", "Envie um token no cabeçalho Authorization: Bearer")
+		return false
+	}
+
+	if !a.policy.Allowed(claims, action, category) {
+		writeProblem(w, r, newProblem(http.StatusForbidden, codeForbidden, fmt.Sprintf("Seus papéis não permitem %s em %s", action, category)))
+		return false
+	}
+
+	return true
+}
+
+// permits - consulta a política de acesso sem escrever a resposta; sempre true com a autenticação desligada
+func (a *authConfig) permits(r *http.Request, action auth.Action, category string) bool {
+	if a == nil {
+		return true
+	}
+
+	claims, _ := auth.FromContext(r.Context())
+	return a.policy.Allowed(claims, action, category)
+}
+
+// me - identidade do token e permissões efetivas em cada categoria
+func (app *App) me(w http.ResponseWriter, r *http.Request) {
+	claims, ok := auth.FromContext(r.Context())
+
+	if !ok {
+		writeUnauthorized(w, r, "", "Envie um token no cabeçalho Authorization: Bearer")
+		return
+	}
+
+	categories := make([]string, len(app.categories))
+	for i, c := range app.categories {
+		categories[i] = c.name()
+	}
+
+	grants := auth.ParseGrants(claims.Roles)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"subject":      claims.Subject,
+		"roles":        grants,
+		"permissions":  app.auth.policy.Permissions(claims, categories),
+		"manage_users": app.auth.policy.Allowed(claims, auth.ActionManageUsers, auth.AllCategories),
+	})
+}
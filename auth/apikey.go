@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// keyPrefix - prefixo das chaves geradas, para que sejam reconhecíveis em logs e varreduras de segredos
+const keyPrefix = "rdm_"
+
+// APIKey - chave de acesso de clientes automatizados; somente o hash da chave é armazenado
+type APIKey struct {
+	ID   primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name string             `bson:"name" json:"name" validate:"required,max=100"`
+	// Hint - início da chave, para identificá-la sem revelá-la
+	Hint string `bson:"hint" json:"hint"`
+	Hash string `bson:"hash" json:"-"`
+	// ReadOnly - a chave só permite leituras
+	ReadOnly bool `bson:"read_only" json:"read_only"`
+	// Categories - categorias acessíveis com a chave; vazio permite todas
+	Categories []string   `bson:"categories,omitempty" json:"categories,omitempty"`
+	CreatedBy  string     `bson:"created_by" json:"created_by"`
+	CreatedAt  time.Time  `bson:"created_at" json:"created_at"`
+	ExpiresAt  *time.Time `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+}
+
+// NewAPIKey - gera uma chave aleatória e preenche Hint e Hash; a chave em texto só existe no retorno
+func NewAPIKey(key *APIKey) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	plain := keyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	key.Hint = plain[:len(keyPrefix)+6]
+	key.Hash = HashKey(plain)
+	return plain, nil
+}
+
+// HashKey - hash armazenado da chave; por ser aleatória e longa, dispensa sal e derivação lenta
+func HashKey(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// Active - indica se a chave não foi revogada nem expirou
+func (k *APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// Claims - declarações equivalentes às de um token para a chave
+//
+// Chaves de escrita recebem o papel de editor nas suas categorias (ou em
+// todas) e o escopo de escrita informado; chaves somente leitura não têm papéis.
+func (k *APIKey) Claims(writeScope string) *Claims {
+	claims := &Claims{Subject: "apikey:" + k.ID.Hex(), Categories: k.Categories}
+
+	if k.ReadOnly {
+		return claims
+	}
+
+	claims.Scope = writeScope
+
+	if len(k.Categories) == 0 {
+		claims.Roles = []string{RoleEditor}
+	}
+	for _, category := range k.Categories {
+		claims.Roles = append(claims.Roles, RoleEditor+":"+category)
+	}
+
+	return claims
+}
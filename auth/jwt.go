@@ -0,0 +1,262 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidToken - token mal formado, com assinatura inválida ou de outro emissor/audiência
+	ErrInvalidToken = errors.New("token inválido")
+	// ErrExpiredToken - token expirado ou ainda não válido
+	ErrExpiredToken = errors.New("token expirado")
+)
+
+// leeway - tolerância para diferenças de relógio entre o emissor e a API
+const leeway = 30 * time.Second
+
+// Claims - declarações do token usadas pela API
+type Claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	// Scope - escopos separados por espaço, como no OAuth 2.0
+	Scope string `json:"scope,omitempty"`
+	// Roles - papéis do usuário, opcionalmente restritos a uma categoria (ver ParseGrants)
+	Roles []string `json:"roles,omitempty"`
+	// Categories - quando informadas, limitam todas as ações, inclusive leituras, a essas categorias
+	Categories []string `json:"categories,omitempty"`
+}
+
+// HasScope - indica se o token concede o escopo
+func (c *Claims) HasScope(scope string) bool {
+	for _, s := range strings.Fields(c.Scope) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// audience - "aud" pode ser uma string ou uma lista (RFC 7519)
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*a = list
+	return nil
+}
+
+func (a audience) contains(value string) bool {
+	for _, item := range a {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Config - chaves e restrições aceitas na verificação dos tokens
+type Config struct {
+	// Secret - segredo compartilhado dos tokens HS256
+	Secret string
+	// JWKSFile - arquivo JWKS com as chaves públicas dos tokens RS256
+	JWKSFile string
+	// Issuer e Audience, quando informados, precisam constar no token
+	Issuer   string
+	Audience string
+}
+
+// ConfigFromEnv - lê JWT_SECRET, JWT_JWKS_FILE, JWT_ISSUER e JWT_AUDIENCE
+func ConfigFromEnv() Config {
+	return Config{
+		Secret:   os.Getenv("JWT_SECRET"),
+		JWKSFile: os.Getenv("JWT_JWKS_FILE"),
+		Issuer:   os.Getenv("JWT_ISSUER"),
+		Audience: os.Getenv("JWT_AUDIENCE"),
+	}
+}
+
+// Verifier - verifica tokens JWT assinados com HS256 ou RS256
+type Verifier struct {
+	secret   []byte
+	keys     map[string]*rsa.PublicKey
+	issuer   string
+	audience string
+	now      func() time.Time
+}
+
+// NewVerifier - cria o verificador; exige o segredo HS256, o arquivo JWKS ou ambos
+func NewVerifier(config Config) (*Verifier, error) {
+	if config.Secret == "" && config.JWKSFile == "" {
+		return nil, errors.New("configure JWT_SECRET ou JWT_JWKS_FILE")
+	}
+
+	v := &Verifier{
+		secret:   []byte(config.Secret),
+		issuer:   config.Issuer,
+		audience: config.Audience,
+		now:      time.Now,
+	}
+
+	if config.JWKSFile != "" {
+		keys, err := LoadJWKS(config.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = keys
+	}
+
+	return v, nil
+}
+
+// Verify - confere a assinatura, a validade e o emissor/audiência do token
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: formato", ErrInvalidToken)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: cabeçalho", ErrInvalidToken)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: assinatura", ErrInvalidToken)
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+	digest := sha256.Sum256(signed)
+
+	// o algoritmo só é aceito quando há chave configurada para ele, evitando a troca de HS256 por RS256
+	switch {
+	case header.Alg == "HS256" && len(v.secret) > 0:
+		mac := hmac.New(sha256.New, v.secret)
+		mac.Write(signed)
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return nil, fmt.Errorf("%w: assinatura", ErrInvalidToken)
+		}
+	case header.Alg == "RS256" && len(v.keys) > 0:
+		key, err := v.key(header.Kid)
+		if err != nil {
+			return nil, err
+		}
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return nil, fmt.Errorf("%w: assinatura", ErrInvalidToken)
+		}
+	default:
+		return nil, fmt.Errorf("%w: algoritmo %q não aceito", ErrInvalidToken, header.Alg)
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: declarações", ErrInvalidToken)
+	}
+
+	now := v.now()
+	if claims.ExpiresAt != 0 && now.After(time.Unix(claims.ExpiresAt, 0).Add(leeway)) {
+		return nil, ErrExpiredToken
+	}
+	if claims.NotBefore != 0 && now.Add(leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return nil, ErrExpiredToken
+	}
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return nil, fmt.Errorf("%w: emissor", ErrInvalidToken)
+	}
+	if v.audience != "" && !claims.Audience.contains(v.audience) {
+		return nil, fmt.Errorf("%w: audiência", ErrInvalidToken)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: sem sub", ErrInvalidToken)
+	}
+
+	return &claims, nil
+}
+
+// CanSign - indica se há segredo HS256 para emitir tokens (ver Sign)
+func (v *Verifier) CanSign() bool {
+	return len(v.secret) > 0
+}
+
+// Sign - emite um token HS256 com as declarações, preenchendo emissor, audiência e
+// data de emissão; os tokens RS256 são emitidos somente pelo provedor externo
+func (v *Verifier) Sign(claims Claims) (string, error) {
+	if !v.CanSign() {
+		return "", errors.New("emissão de tokens exige JWT_SECRET")
+	}
+
+	if claims.Issuer == "" {
+		claims.Issuer = v.issuer
+	}
+	if len(claims.Audience) == 0 && v.audience != "" {
+		claims.Audience = audience{v.audience}
+	}
+	claims.IssuedAt = v.now().Unix()
+
+	header, err := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	mac := hmac.New(sha256.New, v.secret)
+	mac.Write([]byte(signed))
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// key - chave RS256 indicada pelo kid; sem kid, somente quando há uma única chave
+func (v *Verifier) key(kid string) (*rsa.PublicKey, error) {
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: chave %q desconhecida", ErrInvalidToken, kid)
+}
+
+// decodeSegment - decodifica um segmento base64url do token como JSON
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
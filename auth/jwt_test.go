@@ -0,0 +1,295 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testNow - instante fixo usado como relógio dos verificadores de teste
+var testNow = time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+
+// newTestVerifier - verificador HS256 com o relógio fixo em testNow
+func newTestVerifier(t *testing.T, config Config) *Verifier {
+	t.Helper()
+
+	v, err := NewVerifier(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.now = func() time.Time { return testNow }
+	return v
+}
+
+// encodeToken - monta um token com o cabeçalho e as declarações informados, assinado por sign
+func encodeToken(t *testing.T, header map[string]string, claims interface{}, sign func(signed []byte) []byte) string {
+	t.Helper()
+
+	h, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(signed)))
+}
+
+// hmacSigner - assina os tokens HS256 com o segredo
+func hmacSigner(secret string) func(signed []byte) []byte {
+	return func(signed []byte) []byte {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(signed)
+		return mac.Sum(nil)
+	}
+}
+
+// hsToken - token HS256 com as declarações, assinado com o segredo
+func hsToken(t *testing.T, secret string, claims interface{}) string {
+	return encodeToken(t, map[string]string{"alg": "HS256", "typ": "JWT"}, claims, hmacSigner(secret))
+}
+
+// rsaSigner - assina os tokens RS256 com a chave privada
+func rsaSigner(t *testing.T, key *rsa.PrivateKey) func(signed []byte) []byte {
+	return func(signed []byte) []byte {
+		digest := sha256.Sum256(signed)
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signature
+	}
+}
+
+// writeJWKS - grava um arquivo JWKS com as chaves públicas, indexadas pelo kid
+func writeJWKS(t *testing.T, keys map[string]*rsa.PrivateKey) string {
+	t.Helper()
+
+	type jwk struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	}
+
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+
+	for kid, key := range keys {
+		set.Keys = append(set.Keys, jwk{
+			Kty: "RSA",
+			Kid: kid,
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+
+	data, err := json.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestVerifyHS256(t *testing.T) {
+	v := newTestVerifier(t, Config{Secret: "s3cret", Issuer: "rastros", Audience: "api"})
+
+	token := hsToken(t, "s3cret", map[string]interface{}{
+		"sub": "ana", "iss": "rastros", "aud": []string{"outra", "api"}, "exp": testNow.Add(time.Hour).Unix(),
+	})
+
+	claims, err := v.Verify(token)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if claims.Subject != "ana" || claims.Issuer != "rastros" || !claims.Audience.contains("api") {
+		t.Errorf("declarações inesperadas: %+v", claims)
+	}
+}
+
+func TestSign(t *testing.T) {
+	v := newTestVerifier(t, Config{Secret: "s3cret", Issuer: "rastros", Audience: "api"})
+
+	token, err := v.Sign(Claims{Subject: "ana", Roles: []string{"editor"}, ExpiresAt: testNow.Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sign preenche o emissor, a audiência e a data de emissão
+	claims, err := v.Verify(token)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if claims.Subject != "ana" || claims.Issuer != "rastros" || !claims.Audience.contains("api") || claims.IssuedAt != testNow.Unix() {
+		t.Errorf("declarações inesperadas: %+v", claims)
+	}
+	if len(claims.Roles) != 1 || claims.Roles[0] != "editor" {
+		t.Errorf("papéis = %v", claims.Roles)
+	}
+
+	rsaOnly := &Verifier{now: v.now}
+	if _, err := rsaOnly.Sign(Claims{Subject: "ana"}); err == nil || rsaOnly.CanSign() {
+		t.Error("Sign sem segredo HS256 deveria falhar")
+	}
+}
+
+func TestVerifyAlgorithm(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hmacOnly := newTestVerifier(t, Config{Secret: "s3cret"})
+	rsaOnly := newTestVerifier(t, Config{JWKSFile: writeJWKS(t, map[string]*rsa.PrivateKey{"k1": key})})
+
+	claims := Claims{Subject: "ana"}
+	none := func([]byte) []byte { return nil }
+
+	tests := []struct {
+		name  string
+		v     *Verifier
+		token string
+	}{
+		{"none", hmacOnly, encodeToken(t, map[string]string{"alg": "none"}, claims, none)},
+		{"RS256 sem JWKS", hmacOnly, encodeToken(t, map[string]string{"alg": "RS256", "kid": "k1"}, claims, rsaSigner(t, key))},
+		{"HS256 sem segredo", rsaOnly, encodeToken(t, map[string]string{"alg": "HS256"}, claims, none)},
+		{"HS512", hmacOnly, encodeToken(t, map[string]string{"alg": "HS512"}, claims, none)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.v.Verify(tt.token); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Verify = %v, esperado ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestVerifyTamperedSignature(t *testing.T) {
+	v := newTestVerifier(t, Config{Secret: "s3cret"})
+
+	if _, err := v.Verify(hsToken(t, "outro", Claims{Subject: "ana"})); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Verify = %v, esperado ErrInvalidToken", err)
+	}
+}
+
+func TestVerifyExpiration(t *testing.T) {
+	v := newTestVerifier(t, Config{Secret: "s3cret"})
+
+	tests := []struct {
+		name   string
+		claims Claims
+		err    error
+	}{
+		{"válido", Claims{Subject: "ana", ExpiresAt: testNow.Add(time.Minute).Unix()}, nil},
+		{"expirado dentro da tolerância", Claims{Subject: "ana", ExpiresAt: testNow.Add(-leeway / 2).Unix()}, nil},
+		{"expirado", Claims{Subject: "ana", ExpiresAt: testNow.Add(-time.Hour).Unix()}, ErrExpiredToken},
+		{"ainda não válido", Claims{Subject: "ana", NotBefore: testNow.Add(time.Hour).Unix()}, ErrExpiredToken},
+		{"sem sub", Claims{ExpiresAt: testNow.Add(time.Minute).Unix()}, ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := v.Verify(hsToken(t, "s3cret", tt.claims)); !errors.Is(err, tt.err) {
+				t.Errorf("Verify = %v, esperado %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestVerifyIssuerAndAudience(t *testing.T) {
+	v := newTestVerifier(t, Config{Secret: "s3cret", Issuer: "rastros", Audience: "api"})
+
+	tests := []struct {
+		name   string
+		claims Claims
+	}{
+		{"outro emissor", Claims{Subject: "ana", Issuer: "outro", Audience: audience{"api"}}},
+		{"outra audiência", Claims{Subject: "ana", Issuer: "rastros", Audience: audience{"outra"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := v.Verify(hsToken(t, "s3cret", tt.claims)); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Verify = %v, esperado ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestVerifyKeyID(t *testing.T) {
+	k1, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k2, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	single := newTestVerifier(t, Config{JWKSFile: writeJWKS(t, map[string]*rsa.PrivateKey{"k1": k1})})
+	both := newTestVerifier(t, Config{JWKSFile: writeJWKS(t, map[string]*rsa.PrivateKey{"k1": k1, "k2": k2})})
+
+	claims := Claims{Subject: "ana"}
+
+	tests := []struct {
+		name  string
+		v     *Verifier
+		kid   string
+		key   *rsa.PrivateKey
+		valid bool
+	}{
+		{"kid conhecido", both, "k2", k2, true},
+		{"kid de outra chave", both, "k1", k2, false},
+		{"kid desconhecido", both, "k3", k1, false},
+		{"sem kid com uma chave", single, "", k1, true},
+		{"sem kid com várias chaves", both, "", k1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := map[string]string{"alg": "RS256"}
+			if tt.kid != "" {
+				header["kid"] = tt.kid
+			}
+
+			_, err := tt.v.Verify(encodeToken(t, header, claims, rsaSigner(t, tt.key)))
+
+			if tt.valid && err != nil {
+				t.Errorf("Verify: %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("Verify = %v, esperado ErrInvalidToken", err)
+			}
+		})
+	}
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	v := newTestVerifier(t, Config{Secret: "s3cret"})
+
+	for _, token := range []string{"", "a.b", "a.b.c.d", "!!.e30.AA", "e30.!!.AA"} {
+		if _, err := v.Verify(token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("Verify(%q) = %v, esperado ErrInvalidToken", token, err)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"rastros-da-mata/crud"
+	"sort"
+	"sync"
+	"time"
+)
+
+// KeyStore - armazenamento das chaves de API
+type KeyStore interface {
+	Create(ctx context.Context, key *APIKey) error
+	// FindByHash - chave com o hash informado, inclusive revogada ou expirada
+	FindByHash(ctx context.Context, hash string) (*APIKey, error)
+	// List - todas as chaves, das mais recentes às mais antigas
+	List(ctx context.Context) ([]APIKey, error)
+	Revoke(ctx context.Context, id primitive.ObjectID, at time.Time) error
+	// Touch - registra o último uso da chave
+	Touch(ctx context.Context, id primitive.ObjectID, at time.Time) error
+}
+
+// MongoKeyStore - chaves de API em uma coleção do MongoDB
+type MongoKeyStore struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoKeyStore - cria o armazenamento e o índice único do hash
+func NewMongoKeyStore(ctx context.Context, coll *mongo.Collection) (*MongoKeyStore, error) {
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "hash", Value: 1}},
+		Options: options.Index().SetName("api_key_hash").SetUnique(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &MongoKeyStore{Collection: coll}, nil
+}
+
+func (s *MongoKeyStore) Create(ctx context.Context, key *APIKey) error {
+	key.ID = primitive.NewObjectID()
+
+	_, err := s.Collection.InsertOne(ctx, key)
+	if err != nil {
+		return keyError(err)
+	}
+	return nil
+}
+
+func (s *MongoKeyStore) FindByHash(ctx context.Context, hash string) (*APIKey, error) {
+	var key APIKey
+
+	if err := s.Collection.FindOne(ctx, bson.M{"hash": hash}).Decode(&key); err != nil {
+		return nil, keyError(err)
+	}
+	return &key, nil
+}
+
+func (s *MongoKeyStore) List(ctx context.Context) ([]APIKey, error) {
+	cur, err := s.Collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}))
+	if err != nil {
+		return nil, keyError(err)
+	}
+	defer func(cur *mongo.Cursor, ctx context.Context) {
+		err := cur.Close(ctx)
+		if err != nil {
+			log.Println(err)
+		}
+	}(cur, ctx)
+
+	keys := []APIKey{}
+	if err := cur.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (s *MongoKeyStore) Revoke(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	result, err := s.Collection.UpdateOne(ctx,
+		bson.M{"_id": id, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": at}})
+	if err != nil {
+		return keyError(err)
+	}
+
+	if result.MatchedCount == 0 {
+		return crud.ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoKeyStore) Touch(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	_, err := s.Collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"last_used_at": at}})
+	return keyError(err)
+}
+
+// keyError - traduz os erros do driver para os erros do pacote crud
+func keyError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case err == mongo.ErrNoDocuments:
+		return crud.ErrNotFound
+	case mongo.IsDuplicateKeyError(err):
+		return crud.ErrConflict
+	}
+	return err
+}
+
+// MemoryKeyStore - chaves de API em memória, para desenvolvimento sem MongoDB
+type MemoryKeyStore struct {
+	mu   sync.Mutex
+	keys map[primitive.ObjectID]APIKey
+}
+
+// NewMemoryKeyStore - cria o armazenamento vazio
+func NewMemoryKeyStore() *MemoryKeyStore {
+	return &MemoryKeyStore{keys: map[primitive.ObjectID]APIKey{}}
+}
+
+func (s *MemoryKeyStore) Create(_ context.Context, key *APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.keys {
+		if existing.Hash == key.Hash {
+			return crud.ErrConflict
+		}
+	}
+
+	key.ID = primitive.NewObjectID()
+	s.keys[key.ID] = *key
+	return nil
+}
+
+func (s *MemoryKeyStore) FindByHash(_ context.Context, hash string) (*APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range s.keys {
+		if key.Hash == hash {
+			return &key, nil
+		}
+	}
+	return nil, crud.ErrNotFound
+}
+
+func (s *MemoryKeyStore) List(_ context.Context) ([]APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]APIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID.Hex() > keys[j].ID.Hex() })
+	return keys, nil
+}
+
+func (s *MemoryKeyStore) Revoke(_ context.Context, id primitive.ObjectID, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok || key.RevokedAt != nil {
+		return crud.ErrNotFound
+	}
+
+	key.RevokedAt = &at
+	s.keys[id] = key
+	return nil
+}
+
+func (s *MemoryKeyStore) Touch(_ context.Context, id primitive.ObjectID, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := s.keys[id]; ok {
+		key.LastUsedAt = &at
+		s.keys[id] = key
+	}
+	return nil
+}
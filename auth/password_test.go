@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=3,p=2$") {
+		t.Errorf("formato inesperado: %s", hash)
+	}
+
+	if !CheckPassword(hash, "correct horse battery staple") {
+		t.Error("a senha correta foi recusada")
+	}
+	if CheckPassword(hash, "correct horse battery stapl") {
+		t.Error("uma senha errada foi aceita")
+	}
+
+	other, err := HashPassword("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == hash {
+		t.Error("hashes iguais para a mesma senha; o sal deveria variar")
+	}
+}
+
+func TestCheckPasswordMalformed(t *testing.T) {
+	hash, err := HashPassword("senha")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(hash, "$")
+
+	tests := map[string]string{
+		"vazio":          "",
+		"outro esquema":  strings.Replace(hash, "argon2id", "argon2i", 1),
+		"outra versão":   strings.Replace(hash, "v=19", "v=16", 1),
+		"parâmetros":     strings.Replace(hash, parts[3], "m=x", 1),
+		"sal inválido":   strings.Replace(hash, parts[4], "!!", 1),
+		"hash inválido":  strings.Replace(hash, parts[5], "!!", 1),
+		"partes a menos": strings.Join(parts[:5], "$"),
+	}
+
+	for name, hash := range tests {
+		t.Run(name, func(t *testing.T) {
+			if CheckPassword(hash, "senha") {
+				t.Errorf("hash %q aceito", hash)
+			}
+		})
+	}
+}
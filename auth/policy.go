@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"sort"
+	"strings"
+)
+
+// Action - operação controlada pela política de acesso
+type Action string
+
+// Ações verificadas pelos manipuladores
+const (
+	ActionRead        Action = "read"
+	ActionCreate      Action = "create"
+	ActionUpdate      Action = "update"
+	ActionPublish     Action = "publish"
+	ActionDelete      Action = "delete"
+	ActionManageUsers Action = "manage_users"
+	ActionManageKeys  Action = "manage_keys"
+	ActionViewAudit   Action = "view_audit"
+)
+
+// Papéis reconhecidos na declaração "roles" do token
+const (
+	RoleEditor   = "editor"
+	RoleReviewer = "reviewer"
+	RoleAdmin    = "admin"
+)
+
+// AllCategories - categoria das concessões válidas para todas as categorias
+const AllCategories = "*"
+
+// Grant - papel concedido em uma categoria ou em todas (AllCategories)
+//
+// No token, "editor:greens" concede o papel somente na categoria greens e
+// "editor" o concede em todas.
+type Grant struct {
+	Role     string `json:"role"`
+	Category string `json:"category"`
+}
+
+// ParseGrants - interpreta os papéis declarados no token
+func ParseGrants(roles []string) []Grant {
+	grants := make([]Grant, 0, len(roles))
+
+	for _, role := range roles {
+		name, category, ok := strings.Cut(strings.TrimSpace(role), ":")
+		if !ok || category == "" {
+			category = AllCategories
+		}
+		grants = append(grants, Grant{Role: name, Category: category})
+	}
+
+	return grants
+}
+
+// Policy - ações permitidas para cada papel
+type Policy struct {
+	roles map[string][]Action
+}
+
+// DefaultPolicy - editores criam e editam rascunhos, revisores também publicam e
+// administradores fazem tudo, inclusive excluir documentos e gerenciar usuários e chaves de API
+func DefaultPolicy() *Policy {
+	editor := []Action{ActionRead, ActionCreate, ActionUpdate}
+	reviewer := append(append([]Action{}, editor...), ActionPublish)
+	admin := append(append([]Action{}, reviewer...), ActionDelete, ActionManageUsers, ActionManageKeys, ActionViewAudit)
+
+	return &Policy{roles: map[string][]Action{
+		RoleEditor:   editor,
+		RoleReviewer: reviewer,
+		RoleAdmin:    admin,
+	}}
+}
+
+// Allowed - indica se algum papel do token permite a ação na categoria
+//
+// Leituras são permitidas fora das restrições de Claims.Categories; quem pode
+// ler sem token é decidido na autenticação.
+func (p *Policy) Allowed(claims *Claims, action Action, category string) bool {
+	if claims != nil && len(claims.Categories) > 0 && !contains(claims.Categories, category) {
+		return false
+	}
+
+	if action == ActionRead {
+		return true
+	}
+
+	if claims == nil {
+		return false
+	}
+
+	for _, grant := range ParseGrants(claims.Roles) {
+		if grant.Category != AllCategories && grant.Category != category {
+			continue
+		}
+		for _, allowed := range p.roles[grant.Role] {
+			if allowed == action {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Permissions - ações efetivas do token em cada categoria, em ordem alfabética
+func (p *Policy) Permissions(claims *Claims, categories []string) map[string][]Action {
+	permissions := map[string][]Action{}
+
+	for _, category := range categories {
+		actions := []Action{}
+		for _, action := range []Action{ActionRead, ActionCreate, ActionUpdate, ActionPublish, ActionDelete} {
+			if p.Allowed(claims, action, category) {
+				actions = append(actions, action)
+			}
+		}
+
+		sort.Slice(actions, func(i, j int) bool { return actions[i] < actions[j] })
+		permissions[category] = actions
+	}
+
+	return permissions
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
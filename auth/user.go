@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strings"
+	"time"
+)
+
+// User - conta de usuário com login por e-mail e senha
+type User struct {
+	ID    primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Email string             `bson:"email" json:"email"`
+	Name  string             `bson:"name" json:"name"`
+	// PasswordHash - hash Argon2id da senha (ver HashPassword)
+	PasswordHash string `bson:"password_hash" json:"-"`
+	// Roles - papéis do usuário, no mesmo formato da declaração "roles" dos tokens
+	Roles     []string  `bson:"roles,omitempty" json:"roles"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// NormalizeEmail - forma do e-mail usada na gravação e na busca dos usuários
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Tipos de Token
+const (
+	TokenRefresh = "refresh"
+	TokenReset   = "password_reset"
+)
+
+// Token - token opaco de uso único (renovação de sessão ou redefinição de senha);
+// somente o hash é armazenado
+type Token struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty"`
+	Kind   string             `bson:"kind"`
+	Hash   string             `bson:"hash"`
+	UserID primitive.ObjectID `bson:"user_id"`
+	// Family - tokens de renovação gerados a partir do mesmo login; o reuso de um
+	// token já trocado revoga a família inteira
+	Family    primitive.ObjectID `bson:"family,omitempty"`
+	CreatedAt time.Time          `bson:"created_at"`
+	ExpiresAt time.Time          `bson:"expires_at"`
+	UsedAt    *time.Time         `bson:"used_at,omitempty"`
+	RevokedAt *time.Time         `bson:"revoked_at,omitempty"`
+}
+
+// NewToken - gera o token opaco e preenche Hash; o token em texto só existe no retorno
+func NewToken(token *Token) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+
+	plain := base64.RawURLEncoding.EncodeToString(secret)
+	token.Hash = HashKey(plain)
+	return plain, nil
+}
+
+// Usable - indica se o token não foi usado, revogado nem expirou
+func (t *Token) Usable(now time.Time) bool {
+	return t.UsedAt == nil && t.RevokedAt == nil && now.Before(t.ExpiresAt)
+}
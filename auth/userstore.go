@@ -0,0 +1,323 @@
+package auth
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"rastros-da-mata/crud"
+	"sort"
+	"sync"
+	"time"
+)
+
+// UserStore - armazenamento dos usuários e dos seus tokens opacos
+type UserStore interface {
+	// CreateUser - grava o usuário; crud.ErrConflict quando o e-mail já existe
+	CreateUser(ctx context.Context, user *User) error
+	UserByID(ctx context.Context, id primitive.ObjectID) (*User, error)
+	UserByEmail(ctx context.Context, email string) (*User, error)
+	// ListUsers - todos os usuários, em ordem de cadastro
+	ListUsers(ctx context.Context) ([]User, error)
+	SetPassword(ctx context.Context, id primitive.ObjectID, hash string) error
+	SetRoles(ctx context.Context, id primitive.ObjectID, roles []string) (*User, error)
+
+	SaveToken(ctx context.Context, token *Token) error
+	TokenByHash(ctx context.Context, kind, hash string) (*Token, error)
+	// UseToken - marca o token como usado; crud.ErrConflict quando já foi usado ou revogado
+	UseToken(ctx context.Context, id primitive.ObjectID, at time.Time) error
+	// RevokeFamily - revoga os tokens de renovação ainda válidos da família
+	RevokeFamily(ctx context.Context, family primitive.ObjectID, at time.Time) error
+	// RevokeUserTokens - revoga todos os tokens ainda válidos do usuário
+	RevokeUserTokens(ctx context.Context, userID primitive.ObjectID, at time.Time) error
+}
+
+// MongoUserStore - usuários e tokens em coleções do MongoDB
+type MongoUserStore struct {
+	Users  *mongo.Collection
+	Tokens *mongo.Collection
+}
+
+// NewMongoUserStore - cria o armazenamento e os índices: e-mail único, hash
+// único e expiração automática dos tokens
+func NewMongoUserStore(ctx context.Context, users, tokens *mongo.Collection) (*MongoUserStore, error) {
+	_, err := users.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().SetName("user_email").SetUnique(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tokens.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetName("token_hash").SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}, Options: options.Index().SetName("token_user")},
+		{Keys: bson.D{{Key: "family", Value: 1}}, Options: options.Index().SetName("token_family")},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetName("token_expiry").SetExpireAfterSeconds(0)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &MongoUserStore{Users: users, Tokens: tokens}, nil
+}
+
+func (s *MongoUserStore) CreateUser(ctx context.Context, user *User) error {
+	user.ID = primitive.NewObjectID()
+
+	_, err := s.Users.InsertOne(ctx, user)
+	return keyError(err)
+}
+
+func (s *MongoUserStore) UserByID(ctx context.Context, id primitive.ObjectID) (*User, error) {
+	return s.findUser(ctx, bson.M{"_id": id})
+}
+
+func (s *MongoUserStore) UserByEmail(ctx context.Context, email string) (*User, error) {
+	return s.findUser(ctx, bson.M{"email": NormalizeEmail(email)})
+}
+
+func (s *MongoUserStore) findUser(ctx context.Context, filter bson.M) (*User, error) {
+	var user User
+
+	if err := s.Users.FindOne(ctx, filter).Decode(&user); err != nil {
+		return nil, keyError(err)
+	}
+	return &user, nil
+}
+
+func (s *MongoUserStore) ListUsers(ctx context.Context) ([]User, error) {
+	cur, err := s.Users.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, keyError(err)
+	}
+	defer func(cur *mongo.Cursor, ctx context.Context) {
+		err := cur.Close(ctx)
+		if err != nil {
+			log.Println(err)
+		}
+	}(cur, ctx)
+
+	users := []User{}
+	if err := cur.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (s *MongoUserStore) SetPassword(ctx context.Context, id primitive.ObjectID, hash string) error {
+	result, err := s.Users.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"password_hash": hash}})
+	if err != nil {
+		return keyError(err)
+	}
+	if result.MatchedCount == 0 {
+		return crud.ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoUserStore) SetRoles(ctx context.Context, id primitive.ObjectID, roles []string) (*User, error) {
+	var user User
+
+	err := s.Users.FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"roles": roles}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&user)
+	if err != nil {
+		return nil, keyError(err)
+	}
+	return &user, nil
+}
+
+func (s *MongoUserStore) SaveToken(ctx context.Context, token *Token) error {
+	token.ID = primitive.NewObjectID()
+
+	_, err := s.Tokens.InsertOne(ctx, token)
+	return keyError(err)
+}
+
+func (s *MongoUserStore) TokenByHash(ctx context.Context, kind, hash string) (*Token, error) {
+	var token Token
+
+	if err := s.Tokens.FindOne(ctx, bson.M{"kind": kind, "hash": hash}).Decode(&token); err != nil {
+		return nil, keyError(err)
+	}
+	return &token, nil
+}
+
+func (s *MongoUserStore) UseToken(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	// a condição garante que duas requisições simultâneas não usem o mesmo token
+	result, err := s.Tokens.UpdateOne(ctx,
+		bson.M{"_id": id, "used_at": bson.M{"$exists": false}, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"used_at": at}})
+	if err != nil {
+		return keyError(err)
+	}
+	if result.MatchedCount == 0 {
+		return crud.ErrConflict
+	}
+	return nil
+}
+
+func (s *MongoUserStore) RevokeFamily(ctx context.Context, family primitive.ObjectID, at time.Time) error {
+	return s.revoke(ctx, bson.M{"family": family}, at)
+}
+
+func (s *MongoUserStore) RevokeUserTokens(ctx context.Context, userID primitive.ObjectID, at time.Time) error {
+	return s.revoke(ctx, bson.M{"user_id": userID}, at)
+}
+
+func (s *MongoUserStore) revoke(ctx context.Context, filter bson.M, at time.Time) error {
+	filter["revoked_at"] = bson.M{"$exists": false}
+	_, err := s.Tokens.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"revoked_at": at}})
+	return keyError(err)
+}
+
+// MemoryUserStore - usuários e tokens em memória, para desenvolvimento sem MongoDB
+type MemoryUserStore struct {
+	mu     sync.Mutex
+	users  map[primitive.ObjectID]User
+	tokens map[primitive.ObjectID]Token
+}
+
+// NewMemoryUserStore - cria o armazenamento vazio
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{users: map[primitive.ObjectID]User{}, tokens: map[primitive.ObjectID]Token{}}
+}
+
+func (s *MemoryUserStore) CreateUser(_ context.Context, user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.users {
+		if existing.Email == user.Email {
+			return crud.ErrConflict
+		}
+	}
+
+	user.ID = primitive.NewObjectID()
+	s.users[user.ID] = *user
+	return nil
+}
+
+func (s *MemoryUserStore) UserByID(_ context.Context, id primitive.ObjectID) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, crud.ErrNotFound
+	}
+	return &user, nil
+}
+
+func (s *MemoryUserStore) UserByEmail(_ context.Context, email string) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	email = NormalizeEmail(email)
+	for _, user := range s.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+	return nil, crud.ErrNotFound
+}
+
+func (s *MemoryUserStore) ListUsers(_ context.Context) ([]User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users := make([]User, 0, len(s.users))
+	for _, user := range s.users {
+		users = append(users, user)
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].ID.Hex() < users[j].ID.Hex() })
+	return users, nil
+}
+
+func (s *MemoryUserStore) SetPassword(_ context.Context, id primitive.ObjectID, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return crud.ErrNotFound
+	}
+
+	user.PasswordHash = hash
+	s.users[id] = user
+	return nil
+}
+
+func (s *MemoryUserStore) SetRoles(_ context.Context, id primitive.ObjectID, roles []string) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[id]
+	if !ok {
+		return nil, crud.ErrNotFound
+	}
+
+	user.Roles = roles
+	s.users[id] = user
+	return &user, nil
+}
+
+func (s *MemoryUserStore) SaveToken(_ context.Context, token *Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token.ID = primitive.NewObjectID()
+	s.tokens[token.ID] = *token
+	return nil
+}
+
+func (s *MemoryUserStore) TokenByHash(_ context.Context, kind, hash string) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.tokens {
+		if token.Kind == kind && token.Hash == hash {
+			return &token, nil
+		}
+	}
+	return nil, crud.ErrNotFound
+}
+
+func (s *MemoryUserStore) UseToken(_ context.Context, id primitive.ObjectID, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[id]
+	if !ok || token.UsedAt != nil || token.RevokedAt != nil {
+		return crud.ErrConflict
+	}
+
+	token.UsedAt = &at
+	s.tokens[id] = token
+	return nil
+}
+
+func (s *MemoryUserStore) RevokeFamily(_ context.Context, family primitive.ObjectID, at time.Time) error {
+	s.revoke(func(t Token) bool { return t.Family == family }, at)
+	return nil
+}
+
+func (s *MemoryUserStore) RevokeUserTokens(_ context.Context, userID primitive.ObjectID, at time.Time) error {
+	s.revoke(func(t Token) bool { return t.UserID == userID }, at)
+	return nil
+}
+
+func (s *MemoryUserStore) revoke(match func(t Token) bool, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, token := range s.tokens {
+		if token.RevokedAt == nil && match(token) {
+			token.RevokedAt = &at
+			s.tokens[id] = token
+		}
+	}
+}
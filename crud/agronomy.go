@@ -0,0 +1,42 @@
+package crud
+
+// DayRange - tempo de desenvolvimento em dias, do mínimo ao máximo
+type DayRange struct {
+	MinDays int `bson:"min_days" json:"min_days" validate:"min=0,max=3650"`
+	MaxDays int `bson:"max_days" json:"max_days" validate:"min=0,max=3650,gtefield=MinDays"`
+}
+
+// TemperatureRange - faixa de temperatura em graus Celsius
+type TemperatureRange struct {
+	MinCelsius float64 `bson:"min_celsius" json:"min_celsius" validate:"min=-50,max=60"`
+	MaxCelsius float64 `bson:"max_celsius" json:"max_celsius" validate:"min=-50,max=60,gtefield=MinCelsius"`
+}
+
+// Exposições ao sol aceitas em Sunlight.Exposure
+const (
+	SunlightFull    = "full"
+	SunlightPartial = "partial"
+	SunlightShade   = "shade"
+)
+
+// Sunlight - exposição ao sol e horas diárias de luz direta recomendadas
+type Sunlight struct {
+	Exposure string  `bson:"exposure" json:"exposure" validate:"required,oneof=full partial shade"`
+	Hours    float64 `bson:"hours,omitempty" json:"hours,omitempty" validate:"min=0,max=24"`
+}
+
+// Frequências de rega aceitas em Irrigation.Frequency
+const (
+	IrrigationDaily         = "daily"
+	IrrigationAlternateDays = "alternate_days"
+	IrrigationTwiceWeekly   = "twice_weekly"
+	IrrigationWeekly        = "weekly"
+	IrrigationBiweekly      = "biweekly"
+	IrrigationMonthly       = "monthly"
+)
+
+// Irrigation - frequência de rega e volume de água por rega, em litros
+type Irrigation struct {
+	Frequency    string  `bson:"frequency" json:"frequency" validate:"required,oneof=daily alternate_days twice_weekly weekly biweekly monthly"`
+	VolumeLiters float64 `bson:"volume_liters,omitempty" json:"volume_liters,omitempty" validate:"min=0,max=10000"`
+}
@@ -0,0 +1,403 @@
+package crud
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+type auditContextKey int
+
+const (
+	actorKey auditContextKey = iota
+	requestIDKey
+)
+
+// Anonymous - autor das alterações feitas sem credenciais (autenticação desligada)
+const Anonymous = "anonymous"
+
+// WithActor - contexto com o autor das alterações, registrado no histórico de auditoria
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// Actor - autor das alterações do contexto, ou Anonymous
+func Actor(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey).(string); ok && actor != "" {
+		return actor
+	}
+	return Anonymous
+}
+
+// WithRequestID - contexto com o ID da requisição que originou as alterações
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID - ID da requisição do contexto, ou vazio
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// AuditEntry - registro imutável de uma alteração de documento
+type AuditEntry struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Time       time.Time          `bson:"time" json:"time"`
+	Actor      string             `bson:"actor" json:"actor"`
+	RequestID  string             `bson:"request_id,omitempty" json:"request_id,omitempty"`
+	Category   string             `bson:"category" json:"category"`
+	DocumentID primitive.ObjectID `bson:"document_id" json:"document_id"`
+	// Action - tipo da alteração (ver Change.Action)
+	Action string `bson:"action" json:"action"`
+	// Version - versão do documento após a alteração, ou a versão removida
+	Version int64         `bson:"version" json:"version"`
+	Changes []FieldChange `bson:"changes" json:"changes"`
+}
+
+// FieldChange - valor anterior e novo de um campo; o caminho usa "." para subdocumentos
+type FieldChange struct {
+	Field  string      `bson:"field" json:"field"`
+	Before interface{} `bson:"before,omitempty" json:"before,omitempty"`
+	After  interface{} `bson:"after,omitempty" json:"after,omitempty"`
+}
+
+// Diff - campos que diferem entre os dois estados, em ordem alfabética; um estado
+// nil equivale a um documento vazio. Listas são comparadas como um único valor.
+func Diff(before, after *Plant) ([]FieldChange, error) {
+	b, err := plantMap(before)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := plantMap(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []FieldChange{}
+	diffMaps("", b, a, &changes)
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
+
+// plantMap - documento como mapa BSON, sem os campos mantidos pelo repositório
+func plantMap(p *Plant) (bson.M, error) {
+	if p == nil {
+		return bson.M{}, nil
+	}
+
+	raw, err := bson.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var m bson.M
+	if err := bson.Unmarshal(raw, &m); err != nil {
+		return nil, err
+	}
+
+	delete(m, "_id")
+	delete(m, "version")
+	return m, nil
+}
+
+func diffMaps(prefix string, before, after bson.M, changes *[]FieldChange) {
+	keys := map[string]bool{}
+	for k := range before {
+		keys[k] = true
+	}
+	for k := range after {
+		keys[k] = true
+	}
+
+	for k := range keys {
+		b, a := before[k], after[k]
+
+		bm, bok := b.(bson.M)
+		am, aok := a.(bson.M)
+		if bok && aok {
+			diffMaps(prefix+k+".", bm, am, changes)
+			continue
+		}
+
+		if !reflect.DeepEqual(b, a) {
+			*changes = append(*changes, FieldChange{Field: prefix + k, Before: b, After: a})
+		}
+	}
+}
+
+// AuditQuery - filtros da consulta ao histórico; campos vazios não filtram
+type AuditQuery struct {
+	Actor      string
+	Category   string
+	DocumentID primitive.ObjectID
+	From, To   time.Time
+	// Before - continua a consulta a partir deste registro (exclusivo)
+	Before primitive.ObjectID
+	Limit  int64
+}
+
+// AuditLog - histórico de auditoria somente de inclusão
+type AuditLog interface {
+	Append(ctx context.Context, entry *AuditEntry) error
+	// Query - registros que atendem aos filtros, dos mais recentes aos mais antigos
+	Query(ctx context.Context, q AuditQuery) ([]AuditEntry, error)
+}
+
+// Limites da gravação dos registros de auditoria
+const (
+	// auditTimeout - prazo de cada gravação, independente do cancelamento da requisição
+	auditTimeout = 5 * time.Second
+	// maxAuditPending - registros guardados para nova tentativa; os seguintes são descartados
+	maxAuditPending = 10_000
+)
+
+// Auditor - ChangeListener que registra cada alteração no AuditLog
+//
+// A alteração já foi feita quando o registro é gravado, então uma falha não a
+// desfaz: o registro fica pendente até Retry conseguir gravá-lo, e Status mostra
+// quantos aguardam, para que nenhum se perca sem que se perceba.
+type Auditor struct {
+	Log AuditLog
+
+	mu       sync.Mutex
+	pending  []*AuditEntry
+	failures int64
+	dropped  int64
+}
+
+// AuditStatus - situação da gravação do histórico, ou das revisões (ver Revisioner), desde o início do processo
+type AuditStatus struct {
+	// Pending - registros que aguardam nova tentativa
+	Pending int `json:"pending"`
+	// Failures - gravações que falharam, inclusive as tentativas de Retry
+	Failures int64 `json:"failures"`
+	// Dropped - registros perdidos porque o limite de pendentes já tinha sido atingido
+	Dropped int64 `json:"dropped"`
+}
+
+// NewAuditor - cria o listener que grava no histórico informado
+func NewAuditor(l AuditLog) *Auditor {
+	return &Auditor{Log: l}
+}
+
+// Changed - grava o registro da alteração; na falha, o registro fica pendente (ver Retry)
+func (a *Auditor) Changed(ctx context.Context, change Change) {
+	changes, err := Diff(change.Before, change.Plant)
+	if err != nil {
+		log.Printf("Erro ao calcular as alterações de %s/%s: %v", change.Category, change.ID.Hex(), err)
+		return
+	}
+
+	entry := &AuditEntry{
+		Time:       time.Now().UTC(),
+		Actor:      Actor(ctx),
+		RequestID:  RequestID(ctx),
+		Category:   change.Category,
+		DocumentID: change.ID,
+		Action:     change.Action,
+		Changes:    changes,
+	}
+
+	if change.Plant != nil {
+		entry.Version = change.Plant.Version
+	} else if change.Before != nil {
+		entry.Version = change.Before.Version
+	}
+
+	// a requisição pode terminar antes da gravação, então o prazo é próprio
+	ctx, cancel := context.WithTimeout(context.Background(), auditTimeout)
+	defer cancel()
+
+	if err := a.Log.Append(ctx, entry); err != nil {
+		a.failed(entry, err)
+	}
+}
+
+// failed - guarda o registro que não foi gravado para nova tentativa
+func (a *Auditor) failed(entry *AuditEntry, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.failures++
+
+	if len(a.pending) >= maxAuditPending {
+		a.dropped++
+		log.Printf("ALERTA: registro de auditoria de %s/%s descartado, com %d pendentes: %v", entry.Category, entry.DocumentID.Hex(), len(a.pending), err)
+		return
+	}
+
+	a.pending = append(a.pending, entry)
+	log.Printf("ALERTA: registro de auditoria de %s/%s não gravado, %d pendentes: %v", entry.Category, entry.DocumentID.Hex(), len(a.pending), err)
+}
+
+// Retry - grava os registros pendentes na ordem em que falharam, parando na primeira falha
+func (a *Auditor) Retry(ctx context.Context) (int, error) {
+	a.mu.Lock()
+	pending := a.pending
+	a.pending = nil
+	a.mu.Unlock()
+
+	for i, entry := range pending {
+		if err := a.Log.Append(ctx, entry); err != nil {
+			a.mu.Lock()
+			a.failures++
+			a.pending = append(append([]*AuditEntry(nil), pending[i:]...), a.pending...)
+			a.mu.Unlock()
+			return i, err
+		}
+	}
+
+	return len(pending), nil
+}
+
+// Status - registros pendentes e falhas da gravação do histórico
+func (a *Auditor) Status() AuditStatus {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return AuditStatus{Pending: len(a.pending), Failures: a.failures, Dropped: a.dropped}
+}
+
+// MongoAuditLog - histórico de auditoria em uma coleção do MongoDB
+type MongoAuditLog struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoAuditLog - cria o histórico e os índices das consultas
+func NewMongoAuditLog(ctx context.Context, coll *mongo.Collection) (*MongoAuditLog, error) {
+	_, err := coll.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "document_id", Value: 1}, {Key: "_id", Value: -1}}, Options: options.Index().SetName("audit_document")},
+		{Keys: bson.D{{Key: "actor", Value: 1}, {Key: "_id", Value: -1}}, Options: options.Index().SetName("audit_actor")},
+		{Keys: bson.D{{Key: "time", Value: -1}}, Options: options.Index().SetName("audit_time")},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// os valores anteriores e novos voltam como mapas, e não como bson.D, para serem escritos em JSON
+	registry := bson.NewRegistryBuilder().
+		RegisterTypeMapEntry(bsontype.EmbeddedDocument, reflect.TypeOf(bson.M{})).
+		Build()
+
+	coll, err = coll.Clone(options.Collection().SetRegistry(registry))
+	if err != nil {
+		return nil, err
+	}
+
+	return &MongoAuditLog{Collection: coll}, nil
+}
+
+func (l *MongoAuditLog) Append(ctx context.Context, entry *AuditEntry) error {
+	entry.ID = primitive.NewObjectID()
+
+	_, err := l.Collection.InsertOne(ctx, entry)
+	return mongoError(err)
+}
+
+func (l *MongoAuditLog) Query(ctx context.Context, q AuditQuery) ([]AuditEntry, error) {
+	filter := bson.M{}
+	if q.Actor != "" {
+		filter["actor"] = q.Actor
+	}
+	if q.Category != "" {
+		filter["category"] = q.Category
+	}
+	if !q.DocumentID.IsZero() {
+		filter["document_id"] = q.DocumentID
+	}
+	if !q.Before.IsZero() {
+		filter["_id"] = bson.M{"$lt": q.Before}
+	}
+
+	period := bson.M{}
+	if !q.From.IsZero() {
+		period["$gte"] = q.From
+	}
+	if !q.To.IsZero() {
+		period["$lt"] = q.To
+	}
+	if len(period) > 0 {
+		filter["time"] = period
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}})
+	if q.Limit > 0 {
+		opts.SetLimit(q.Limit)
+	}
+
+	cur, err := l.Collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	defer func(cur *mongo.Cursor, ctx context.Context) {
+		err := cur.Close(ctx)
+		if err != nil {
+			log.Println(err)
+		}
+	}(cur, ctx)
+
+	entries := []AuditEntry{}
+	if err := cur.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// MemoryAuditLog - histórico de auditoria em memória, para desenvolvimento sem MongoDB
+type MemoryAuditLog struct {
+	mu      sync.RWMutex
+	entries []AuditEntry
+}
+
+// NewMemoryAuditLog - cria o histórico vazio
+func NewMemoryAuditLog() *MemoryAuditLog {
+	return &MemoryAuditLog{}
+}
+
+func (l *MemoryAuditLog) Append(_ context.Context, entry *AuditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry.ID = primitive.NewObjectID()
+	l.entries = append(l.entries, *entry)
+	return nil
+}
+
+func (l *MemoryAuditLog) Query(_ context.Context, q AuditQuery) ([]AuditEntry, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	entries := []AuditEntry{}
+
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		e := l.entries[i]
+
+		switch {
+		case q.Actor != "" && e.Actor != q.Actor,
+			q.Category != "" && e.Category != q.Category,
+			!q.DocumentID.IsZero() && e.DocumentID != q.DocumentID,
+			!q.Before.IsZero() && e.ID.Hex() >= q.Before.Hex(),
+			!q.From.IsZero() && e.Time.Before(q.From),
+			!q.To.IsZero() && !e.Time.Before(q.To):
+			continue
+		}
+
+		entries = append(entries, e)
+		if q.Limit > 0 && int64(len(entries)) == q.Limit {
+			break
+		}
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,132 @@
+package crud
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	before := &Plant{Name: "Pitanga", Description: "azeda", Taxonomy: &Taxonomy{Genus: "Eugenia", Species: "uniflora"}, Version: 1}
+	after := &Plant{Name: "Pitanga", Planting: "sementes", Taxonomy: &Taxonomy{Genus: "Eugenia", Species: "pitanga"}, Version: 2}
+
+	changes, err := Diff(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a versão é mantida pelo repositório e não aparece; subdocumentos são comparados campo a campo
+	want := []FieldChange{
+		{Field: "description", Before: "azeda"},
+		{Field: "planting", After: "sementes"},
+		{Field: "taxonomy.species", Before: "uniflora", After: "pitanga"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("Diff = %+v, esperado %+v", changes, want)
+	}
+}
+
+func TestAuditor(t *testing.T) {
+	log := NewMemoryAuditLog()
+	repo := NewNotifyingRepository[Fruit](NewMemoryRepository[Fruit](), "fruits", NewAuditor(log))
+
+	ctx := WithRequestID(WithActor(context.Background(), "ana"), "req-1")
+
+	doc := newFruit("Pitanga")
+	if err := repo.Create(ctx, doc); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Update(ctx, doc.ID, newFruit("Pitanga-roxa"), AnyVersion); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(context.Background(), doc.ID, AnyVersion); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := log.Query(ctx, AuditQuery{DocumentID: doc.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("registros = %+v, esperado 3", entries)
+	}
+
+	// Query devolve do mais recente ao mais antigo
+	wants := []struct {
+		action  string
+		actor   string
+		version int64
+		after   interface{}
+	}{
+		{ChangeDelete, Anonymous, 2, nil},
+		{ChangeUpdate, "ana", 2, "Pitanga-roxa"},
+		{ChangeCreate, "ana", 1, "Pitanga"},
+	}
+	for i, want := range wants {
+		e := entries[i]
+		if e.Action != want.action || e.Actor != want.actor || e.Version != want.version || e.Category != "fruits" {
+			t.Errorf("registro %d = %+v", i, e)
+		}
+		if len(e.Changes) != 1 || e.Changes[0].Field != "name" || e.Changes[0].After != want.after {
+			t.Errorf("registro %d com as alterações %+v", i, e.Changes)
+		}
+	}
+	if entries[1].RequestID != "req-1" {
+		t.Errorf("RequestID = %q", entries[1].RequestID)
+	}
+
+	if entries, _ := log.Query(ctx, AuditQuery{Actor: "ana", Limit: 1}); len(entries) != 1 || entries[0].Action != ChangeUpdate {
+		t.Errorf("Query por autor = %+v", entries)
+	}
+}
+
+// failingLog - AuditLog que falha enquanto down for verdadeiro
+type failingLog struct {
+	MemoryAuditLog
+	down bool
+}
+
+func (l *failingLog) Append(ctx context.Context, entry *AuditEntry) error {
+	if l.down {
+		return errors.New("fora do ar")
+	}
+	return l.MemoryAuditLog.Append(ctx, entry)
+}
+
+func TestAuditorRetry(t *testing.T) {
+	ctx := context.Background()
+	log := &failingLog{down: true}
+	auditor := NewAuditor(log)
+	repo := NewNotifyingRepository[Fruit](NewMemoryRepository[Fruit](), "fruits", auditor)
+
+	for _, name := range []string{"Pitanga", "Acerola"} {
+		if err := repo.Create(ctx, newFruit(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if status := auditor.Status(); status.Pending != 2 || status.Failures != 2 {
+		t.Fatalf("Status = %+v", status)
+	}
+	if n, err := auditor.Retry(ctx); n != 0 || err == nil {
+		t.Errorf("Retry com o histórico fora do ar = %d, %v", n, err)
+	}
+	if status := auditor.Status(); status.Pending != 2 || status.Failures != 3 {
+		t.Errorf("Status depois da nova falha = %+v", status)
+	}
+
+	log.down = false
+	if n, err := auditor.Retry(ctx); n != 2 || err != nil {
+		t.Fatalf("Retry = %d, %v", n, err)
+	}
+
+	// os registros pendentes são gravados na ordem em que falharam
+	entries, _ := log.Query(ctx, AuditQuery{})
+	if len(entries) != 2 || entries[0].Changes[0].After != "Acerola" || entries[1].Changes[0].After != "Pitanga" {
+		t.Errorf("registros = %+v", entries)
+	}
+	if status := auditor.Status(); status.Pending != 0 {
+		t.Errorf("Status depois do Retry = %+v", status)
+	}
+}
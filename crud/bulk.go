@@ -0,0 +1,164 @@
+package crud
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BulkOp - inclusão (Doc preenchido) ou alteração parcial de um documento em Bulk
+type BulkOp[T any] struct {
+	// Doc - documento a incluir; recebe o ID gerado
+	Doc *T
+	// ID, Patch e Version - alteração, com a mesma verificação de versão de Patch
+	ID      primitive.ObjectID
+	Patch   Patch
+	Version int64
+}
+
+// BulkResult - documento incluído ou alterado pela operação, ou o erro que a impediu
+type BulkResult[T any] struct {
+	Doc *T
+	Err error
+}
+
+// Bulk - executa as operações com um BulkWrite não ordenado e lê os documentos alterados em uma só consulta
+func (r *MongoRepository[T, PT]) Bulk(ctx context.Context, ops []BulkOp[T]) ([]BulkResult[T], error) {
+	results := make([]BulkResult[T], len(ops))
+	models := make([]mongo.WriteModel, len(ops))
+	var updated []primitive.ObjectID
+
+	for i, op := range ops {
+		if op.Doc != nil {
+			plant := PT(op.Doc).PlantData()
+			plant.ID = primitive.NewObjectID()
+			plant.Version = 1
+			models[i] = mongo.NewInsertOneModel().SetDocument(op.Doc)
+			results[i].Doc = op.Doc
+			continue
+		}
+
+		models[i] = mongo.NewUpdateOneModel().SetFilter(versionFilter(op.ID, op.Version)).SetUpdate(patchUpdate(op.Patch))
+		updated = append(updated, op.ID)
+	}
+
+	if len(models) == 0 {
+		return results, nil
+	}
+
+	_, err := r.Collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+
+	var bwe mongo.BulkWriteException
+	switch {
+	case errors.As(err, &bwe):
+		for _, we := range bwe.WriteErrors {
+			results[we.Index] = BulkResult[T]{Err: mongoError(mongo.WriteException{WriteErrors: mongo.WriteErrors{we.WriteError}})}
+		}
+		if bwe.WriteConcernError != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, mongoError(err)
+	}
+
+	if len(updated) == 0 {
+		return results, nil
+	}
+
+	var docs []T
+	cur, err := r.Collection.Find(ctx, bson.M{"_id": bson.M{"$in": updated}, "deleted_at": nil})
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, mongoError(err)
+	}
+
+	found := make(map[primitive.ObjectID]*T, len(docs))
+	for i := range docs {
+		found[PT(&docs[i]).PlantData().ID] = &docs[i]
+	}
+
+	// o UpdateOne não informa qual operação deixou de encontrar o documento; a versão lida decide
+	for i, op := range ops {
+		if op.Doc != nil || results[i].Err != nil {
+			continue
+		}
+
+		doc, ok := found[op.ID]
+		switch {
+		case !ok:
+			results[i].Err = ErrNotFound
+		case op.Version != AnyVersion && PT(doc).PlantData().Version != op.Version+1:
+			results[i].Err = ErrVersionMismatch
+		default:
+			results[i].Doc = doc
+		}
+	}
+
+	return results, nil
+}
+
+// Bulk - executa as operações uma a uma, sob as mesmas regras de Create e Patch
+func (r *MemoryRepository[T, PT]) Bulk(ctx context.Context, ops []BulkOp[T]) ([]BulkResult[T], error) {
+	results := make([]BulkResult[T], len(ops))
+
+	for i, op := range ops {
+		if op.Doc != nil {
+			PT(op.Doc).PlantData().ID = primitive.NilObjectID
+			if err := r.Create(ctx, op.Doc); err != nil {
+				results[i].Err = err
+				continue
+			}
+			results[i].Doc = op.Doc
+			continue
+		}
+
+		results[i].Doc, results[i].Err = r.Patch(ctx, op.ID, op.Patch, op.Version)
+	}
+
+	return results, nil
+}
+
+// Bulk - executa o lote e avisa os listeners de cada operação bem-sucedida
+func (r *NotifyingRepository[T, PT]) Bulk(ctx context.Context, ops []BulkOp[T]) ([]BulkResult[T], error) {
+	ids := bson.A{}
+	for _, op := range ops {
+		if op.Doc == nil {
+			ids = append(ids, op.ID)
+		}
+	}
+
+	before := map[primitive.ObjectID]*Plant{}
+	if len(ids) > 0 {
+		docs, err := r.PlantRepository.List(ctx, ListOptions{Filter: []Condition{{Field: "_id", Op: OpIn, Value: ids}}})
+		if err != nil {
+			return nil, err
+		}
+		for i := range docs {
+			plant := PT(&docs[i]).PlantData()
+			before[plant.ID] = plant
+		}
+	}
+
+	results, err := r.PlantRepository.Bulk(ctx, ops)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, op := range ops {
+		if results[i].Err != nil {
+			continue
+		}
+		if op.Doc != nil {
+			r.notify(ctx, ChangeCreate, PT(op.Doc).PlantData().ID, nil, op.Doc)
+		} else {
+			r.notify(ctx, ChangeUpdate, op.ID, before[op.ID], results[i].Doc)
+		}
+	}
+
+	return results, nil
+}
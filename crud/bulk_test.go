@@ -0,0 +1,55 @@
+package crud
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestNotifyingRepositoryBulk(t *testing.T) {
+	ctx := context.Background()
+	rec := &recorder{}
+	repo := NewNotifyingRepository[Fruit](NewMemoryRepository[Fruit](), "fruits", rec)
+
+	existing := newFruit("Pitanga")
+	if err := repo.Create(ctx, existing); err != nil {
+		t.Fatal(err)
+	}
+	rec.changes = nil
+
+	patch, err := NewPatch(&Fruit{Plant: Plant{Description: "doce"}}, []string{"description"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := repo.Bulk(ctx, []BulkOp[Fruit]{
+		{Doc: newFruit("Acerola")},
+		{ID: existing.ID, Patch: patch, Version: 1},
+		// a versão antiga falha sem impedir as demais operações
+		{ID: existing.ID, Patch: patch, Version: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if results[0].Err != nil || results[0].Doc.ID.IsZero() || results[0].Doc.Name != "Acerola" {
+		t.Errorf("inclusão = %+v", results[0])
+	}
+	if results[1].Err != nil || results[1].Doc.Description != "doce" || results[1].Doc.Version != 2 {
+		t.Errorf("alteração = %+v", results[1])
+	}
+	if !errors.Is(results[2].Err, ErrVersionMismatch) {
+		t.Errorf("alteração com versão antiga = %v, esperado ErrVersionMismatch", results[2].Err)
+	}
+
+	// somente as operações bem-sucedidas são avisadas, com o estado anterior das alterações
+	if len(rec.changes) != 2 {
+		t.Fatalf("alterações = %+v, esperado 2", rec.changes)
+	}
+	if rec.changes[0].Action != ChangeCreate || rec.changes[1].Action != ChangeUpdate {
+		t.Errorf("ações = %s, %s", rec.changes[0].Action, rec.changes[1].Action)
+	}
+	if before := rec.changes[1].Before; before == nil || before.Description != "" || before.Version != 1 {
+		t.Errorf("estado anterior = %+v", before)
+	}
+}
@@ -0,0 +1,68 @@
+package crud
+
+import (
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/mongo"
+	"strings"
+)
+
+var (
+	// ErrNotFound - o documento solicitado não existe
+	ErrNotFound = errors.New("documento não encontrado")
+	// ErrConflict - a operação conflita com o estado atual do documento
+	ErrConflict = errors.New("conflito com o estado atual do documento")
+	// ErrVersionMismatch - a versão do documento difere da esperada pelo cliente
+	ErrVersionMismatch = errors.New("a versão do documento difere da esperada")
+	// ErrValidation - o documento não atende às regras de validação
+	ErrValidation = errors.New("documento inválido")
+)
+
+// FieldError - violação de uma regra em um campo do documento
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError - conjunto de violações encontradas em um documento
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return ErrValidation.Error() + ": " + strings.Join(msgs, "; ")
+}
+
+// Is - permite comparar o erro com ErrValidation usando errors.Is
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// mongoError - traduz os erros do driver do MongoDB para os erros do pacote
+func mongoError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return ErrNotFound
+	case mongo.IsDuplicateKeyError(err):
+		return fmt.Errorf("%w: %v", ErrConflict, err)
+	}
+
+	var we mongo.WriteException
+	if errors.As(err, &we) {
+		for _, e := range we.WriteErrors {
+			// 121 - DocumentValidationFailure
+			if e.Code == 121 {
+				return fmt.Errorf("%w: %s", ErrValidation, e.Message)
+			}
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,104 @@
+package crud
+
+import (
+	"sort"
+)
+
+// DefaultLanguage - idioma dos campos de texto principais do documento
+const DefaultLanguage = "pt-BR"
+
+// Languages - idiomas suportados, começando pelo padrão
+var Languages = []string{DefaultLanguage, "en", "es"}
+
+// fallbacks - ordem em que os idiomas são tentados quando falta a tradução de um campo
+var fallbacks = map[string][]string{
+	DefaultLanguage: {DefaultLanguage},
+	"en":            {"en", DefaultLanguage},
+	"es":            {"es", DefaultLanguage},
+}
+
+// Translation - textos da planta traduzidos para um idioma
+type Translation struct {
+	Name        string `bson:"name,omitempty" json:"name,omitempty" validate:"max=100"`
+	Description string `bson:"description,omitempty" json:"description,omitempty" validate:"max=2000"`
+	Planting    string `bson:"planting,omitempty" json:"planting,omitempty" validate:"max=2000"`
+	ExtraInfo   string `bson:"extra_info,omitempty" json:"extra_info,omitempty" validate:"max=2000"`
+	Observation string `bson:"observation,omitempty" json:"observation,omitempty" validate:"max=2000"`
+}
+
+// textFields - ponteiros para os campos traduzíveis, na ordem de Translation
+func (t *Translation) textFields() map[string]*string {
+	return map[string]*string{
+		"name":        &t.Name,
+		"description": &t.Description,
+		"planting":    &t.Planting,
+		"extra_info":  &t.ExtraInfo,
+		"observation": &t.Observation,
+	}
+}
+
+// source - textos no idioma padrão
+func (p *Plant) source() Translation {
+	return Translation{
+		Name:        p.Name,
+		Description: p.Description,
+		Planting:    p.Planting,
+		ExtraInfo:   p.ExtraInfo,
+		Observation: p.Observation,
+	}
+}
+
+// Localize - troca os campos de texto pelas traduções do idioma, seguindo a cadeia de fallback
+// campo a campo, e marca o idioma em Language
+func (p *Plant) Localize(lang string) {
+	chain, ok := fallbacks[lang]
+	if !ok || lang == DefaultLanguage {
+		return
+	}
+
+	localized := Translation{}
+	fields := localized.textFields()
+	for field, target := range fields {
+		for _, l := range chain {
+			candidate := p.source()
+			if l != DefaultLanguage {
+				candidate = p.Translations[l]
+			}
+			if value := *candidate.textFields()[field]; value != "" {
+				*target = value
+				break
+			}
+		}
+	}
+
+	p.Name = localized.Name
+	p.Description = localized.Description
+	p.Planting = localized.Planting
+	p.ExtraInfo = localized.ExtraInfo
+	p.Observation = localized.Observation
+	p.Language = lang
+}
+
+// MissingTranslations - campos preenchidos no idioma padrão e sem tradução, por idioma
+func (p *Plant) MissingTranslations() map[string][]string {
+	source := p.source()
+	missing := map[string][]string{}
+
+	for _, lang := range Languages[1:] {
+		translation := p.Translations[lang]
+		translated := translation.textFields()
+		for field, value := range source.textFields() {
+			if *value != "" && *translated[field] == "" {
+				missing[lang] = append(missing[lang], field)
+			}
+		}
+		sort.Strings(missing[lang])
+	}
+
+	for lang, fields := range missing {
+		if len(fields) == 0 {
+			delete(missing, lang)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,144 @@
+package crud
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+)
+
+// Estágios de crescimento mostrados nas imagens
+const (
+	StageSeedling   = "seedling"
+	StageVegetative = "vegetative"
+	StageFlowering  = "flowering"
+	StageFruiting   = "fruiting"
+	StageHarvest    = "harvest"
+)
+
+// Image - imagem da planta; a ordem no array de Plant.Images é a ordem de exibição
+type Image struct {
+	ID primitive.ObjectID `bson:"id" json:"id"`
+	// Key - diretório do armazenamento com o original e as miniaturas; vazio para imagens externas
+	Key         string               `bson:"key,omitempty" json:"-"`
+	URL         string               `bson:"url" json:"url"`
+	ContentType string               `bson:"content_type,omitempty" json:"content_type,omitempty"`
+	Size        int64                `bson:"size,omitempty" json:"size,omitempty"`
+	Width       int                  `bson:"width,omitempty" json:"width,omitempty"`
+	Height      int                  `bson:"height,omitempty" json:"height,omitempty"`
+	Thumbnails  map[string]Thumbnail `bson:"thumbnails,omitempty" json:"thumbnails,omitempty"`
+
+	Caption string `bson:"caption,omitempty" json:"caption,omitempty" validate:"max=300"`
+	Stage   string `bson:"stage,omitempty" json:"stage,omitempty" validate:"oneof=seedling vegetative flowering fruiting harvest"`
+	Credit  string `bson:"credit,omitempty" json:"credit,omitempty" validate:"max=200"`
+	License string `bson:"license,omitempty" json:"license,omitempty" validate:"max=100"`
+	// Cover - imagem de capa, exatamente uma quando houver imagens
+	Cover bool `bson:"cover,omitempty" json:"cover,omitempty"`
+}
+
+// Thumbnail - miniatura gerada a partir da imagem enviada
+type Thumbnail struct {
+	URL    string `bson:"url" json:"url"`
+	Width  int    `bson:"width" json:"width"`
+	Height int    `bson:"height" json:"height"`
+}
+
+// ImageMetadata - campos da imagem que podem ser editados pelos clientes
+var ImageMetadata = []string{"caption", "stage", "credit", "license"}
+
+// FindImage - posição da imagem com o ID informado, ou -1
+func FindImage(images []Image, id primitive.ObjectID) int {
+	for i := range images {
+		if images[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// ImagesPatch - Patch que grava as imagens e mantém image_path com o endereço da capa
+//
+// image_path é o campo anterior às várias imagens, mantido para os clientes
+// antigos. Sem capa marcada, a primeira imagem passa a ser a capa.
+func ImagesPatch(images []Image) Patch {
+	if len(images) == 0 {
+		return Patch{Set: map[string]interface{}{}, Unset: []string{"images", "image_path"}}
+	}
+
+	cover := -1
+	for i := range images {
+		if images[i].Cover && cover < 0 {
+			cover = i
+		} else {
+			images[i].Cover = false
+		}
+	}
+	if cover < 0 {
+		cover = 0
+		images[0].Cover = true
+	}
+
+	return Patch{Set: map[string]interface{}{"images": images, "image_path": images[cover].URL}}
+}
+
+// MigrateImages - converte a imagem única dos documentos anteriores a Images em uma imagem de capa
+//
+// A imagem enviada pela API ("image") mantém os arquivos e miniaturas; um
+// image_path externo vira uma imagem sem arquivos no armazenamento. Retorna o
+// número de documentos migrados.
+func MigrateImages(ctx context.Context, coll *mongo.Collection) (int, error) {
+	filter := bson.M{
+		"images": bson.M{"$exists": false},
+		"$or": bson.A{
+			bson.M{"image": bson.M{"$type": "object"}},
+			bson.M{"image_path": bson.M{"$type": "string", "$ne": ""}},
+		},
+	}
+
+	cur, err := coll.Find(ctx, filter, options.Find().SetProjection(bson.M{"image": 1, "image_path": 1}))
+	if err != nil {
+		return 0, mongoError(err)
+	}
+	defer func(cur *mongo.Cursor, ctx context.Context) {
+		err := cur.Close(ctx)
+		if err != nil {
+			log.Println(err)
+		}
+	}(cur, ctx)
+
+	migrated := 0
+
+	for cur.Next(ctx) {
+		var doc struct {
+			ID        interface{} `bson:"_id"`
+			Image     *Image      `bson:"image"`
+			ImagePath string      `bson:"image_path"`
+		}
+		if err := cur.Decode(&doc); err != nil {
+			return migrated, err
+		}
+
+		image := Image{URL: doc.ImagePath}
+		if doc.Image != nil {
+			image = *doc.Image
+		}
+		image.ID = primitive.NewObjectID()
+
+		patch := ImagesPatch([]Image{image})
+		update := bson.M{
+			"$set":   patch.Set,
+			"$unset": bson.M{"image": ""},
+			"$inc":   bson.M{"version": 1},
+		}
+
+		if _, err := coll.UpdateOne(ctx, bson.M{"_id": doc.ID}, update); err != nil {
+			return migrated, mongoError(err)
+		}
+
+		migrated++
+	}
+
+	return migrated, cur.Err()
+}
@@ -0,0 +1,314 @@
+package crud
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryRepository - PlantRepository mantido em memória, seguro para uso concorrente
+//
+// Os documentos são guardados serializados em BSON, de modo que quem chama
+// nunca compartilha memória com o repositório, como aconteceria com o MongoDB.
+type MemoryRepository[T any, PT DocumentPtr[T]] struct {
+	mu   sync.RWMutex
+	docs map[primitive.ObjectID][]byte
+}
+
+// NewMemoryRepository - cria um repositório vazio
+func NewMemoryRepository[T any, PT DocumentPtr[T]]() *MemoryRepository[T, PT] {
+	return &MemoryRepository[T, PT]{docs: make(map[primitive.ObjectID][]byte)}
+}
+
+// Create - insere um novo documento e preenche o ID gerado
+func (r *MemoryRepository[T, PT]) Create(ctx context.Context, doc *T) error {
+	plant := PT(doc).PlantData()
+	if plant.ID.IsZero() {
+		plant.ID = primitive.NewObjectID()
+	}
+	plant.Version = 1
+
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.docs[plant.ID]; ok {
+		return fmt.Errorf("%w: ID %s já existe", ErrConflict, plant.ID.Hex())
+	}
+	r.docs[plant.ID] = raw
+	return nil
+}
+
+// Read - busca um documento pelo ID
+func (r *MemoryRepository[T, PT]) Read(ctx context.Context, id primitive.ObjectID) (*T, error) {
+	r.mu.RLock()
+	raw, ok := r.docs[id]
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	var doc T
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	if PT(&doc).PlantData().DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+	return &doc, nil
+}
+
+// Update - substitui todos os campos do documento com o ID informado e retorna o documento atualizado
+func (r *MemoryRepository[T, PT]) Update(ctx context.Context, id primitive.ObjectID, doc *T, version int64) (*T, error) {
+	patch, err := ReplacePatch(doc)
+	if err != nil {
+		return nil, err
+	}
+	return r.Patch(ctx, id, patch, version)
+}
+
+// Patch - aplica somente os campos alterados e retorna o documento atualizado
+func (r *MemoryRepository[T, PT]) Patch(ctx context.Context, id primitive.ObjectID, patch Patch, version int64) (*T, error) {
+	_, doc, err := r.PatchWithBefore(ctx, id, patch, version)
+	return doc, err
+}
+
+// PatchWithBefore - aplica somente os campos alterados e retorna o documento antes e depois da alteração
+func (r *MemoryRepository[T, PT]) PatchWithBefore(ctx context.Context, id primitive.ObjectID, patch Patch, version int64) (*T, *T, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, err := r.current(id, version)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	before, err := decodeDocument[T](m)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	patch.apply(m)
+
+	return r.store(id, m, before)
+}
+
+// Delete - move o documento com o ID informado para a lixeira
+func (r *MemoryRepository[T, PT]) Delete(ctx context.Context, id primitive.ObjectID, version int64) error {
+	_, err := r.DeleteWithBefore(ctx, id, version)
+	return err
+}
+
+// DeleteWithBefore - move o documento com o ID informado para a lixeira e retorna o documento antes da remoção
+func (r *MemoryRepository[T, PT]) DeleteWithBefore(ctx context.Context, id primitive.ObjectID, version int64) (*T, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, err := r.current(id, version)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := decodeDocument[T](m)
+	if err != nil {
+		return nil, err
+	}
+
+	m["deleted_at"] = primitive.NewDateTimeFromTime(time.Now())
+	m["deleted_by"] = Actor(ctx)
+	m["version"] = versionOf(m) + 1
+
+	before, _, err = r.store(id, m, before)
+	return before, err
+}
+
+// store - grava o documento alterado e o retorna junto com o estado anterior; exige r.mu travado
+func (r *MemoryRepository[T, PT]) store(id primitive.ObjectID, m bson.M, before *T) (*T, *T, error) {
+	raw, err := bson.Marshal(m)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var doc T
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, nil, err
+	}
+
+	r.docs[id] = raw
+	return before, &doc, nil
+}
+
+// current - decodifica o documento armazenado fora da lixeira, conferindo a versão esperada; exige r.mu travado
+func (r *MemoryRepository[T, PT]) current(id primitive.ObjectID, version int64) (bson.M, error) {
+	return r.stored(id, version, false)
+}
+
+// stored - decodifica o documento armazenado fora ou dentro da lixeira, conferindo a versão esperada; exige r.mu travado
+func (r *MemoryRepository[T, PT]) stored(id primitive.ObjectID, version int64, trash bool) (bson.M, error) {
+	raw, ok := r.docs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	var m bson.M
+	if err := bson.Unmarshal(raw, &m); err != nil {
+		return nil, err
+	}
+
+	if inTrash(m) != trash {
+		return nil, ErrNotFound
+	}
+
+	if version != AnyVersion && versionOf(m) != version {
+		return nil, ErrVersionMismatch
+	}
+	return m, nil
+}
+
+// List - retorna os documentos que atendem ao filtro, ordenados, limitando e pulando resultados
+func (r *MemoryRepository[T, PT]) List(ctx context.Context, opts ListOptions) ([]T, error) {
+	return r.find(false, opts)
+}
+
+// find - lista os documentos fora ou dentro da lixeira
+func (r *MemoryRepository[T, PT]) find(trash bool, opts ListOptions) ([]T, error) {
+	order := SortOrder(opts.Sort)
+	anchor, backward, err := opts.anchor(order)
+	if err != nil {
+		return nil, err
+	}
+
+	matched, err := r.match(opts.Filter, trash)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return compareKeys(matched[i].m, sortKey(matched[j].m, order), order) < 0
+	})
+
+	if anchor != nil {
+		var page []memoryEntry
+		for _, e := range matched {
+			c := compareKeys(e.m, anchor, order)
+			if (!backward && c > 0) || (backward && c < 0) {
+				page = append(page, e)
+			}
+		}
+		matched = page
+	}
+
+	if backward {
+		reverse(matched)
+	}
+
+	if opts.Offset > 0 {
+		if opts.Offset >= int64(len(matched)) {
+			matched = nil
+		} else {
+			matched = matched[opts.Offset:]
+		}
+	}
+	if opts.Limit > 0 && opts.Limit < int64(len(matched)) {
+		matched = matched[:opts.Limit]
+	}
+
+	if backward {
+		reverse(matched)
+	}
+
+	var docs []T
+	for _, e := range matched {
+		var doc T
+		if err := bson.Unmarshal(e.raw, &doc); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+
+	return docs, nil
+}
+
+// Count - retorna o total de documentos que atendem ao filtro
+func (r *MemoryRepository[T, PT]) Count(ctx context.Context, filter []Condition) (int64, error) {
+	matched, err := r.match(filter, false)
+	return int64(len(matched)), err
+}
+
+// memoryEntry - documento armazenado e sua forma decodificada
+type memoryEntry struct {
+	raw []byte
+	m   bson.M
+}
+
+// match - decodifica os documentos fora ou, com trash, dentro da lixeira que atendem às condições
+func (r *MemoryRepository[T, PT]) match(filter []Condition, trash bool) ([]memoryEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matched []memoryEntry
+	for _, raw := range r.docs {
+		var m bson.M
+		if err := bson.Unmarshal(raw, &m); err != nil {
+			return nil, err
+		}
+		if inTrash(m) == trash && matches(m, filter) {
+			matched = append(matched, memoryEntry{raw: raw, m: m})
+		}
+	}
+	return matched, nil
+}
+
+// setPath - atribui o valor no caminho "a.b", criando os documentos intermediários
+func setPath(m bson.M, path string, value interface{}) {
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := m[key].(bson.M)
+		if !ok {
+			next = bson.M{}
+			m[key] = next
+		}
+		m = next
+	}
+	m[keys[len(keys)-1]] = value
+}
+
+// unsetPath - remove o campo no caminho "a.b", se existir
+func unsetPath(m bson.M, path string) {
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := m[key].(bson.M)
+		if !ok {
+			return
+		}
+		m = next
+	}
+	delete(m, keys[len(keys)-1])
+}
+
+// inTrash - indica se o documento decodificado está na lixeira
+func inTrash(m bson.M) bool {
+	return m["deleted_at"] != nil
+}
+
+// versionOf - versão do documento, considerando 0 quando o campo não existe
+func versionOf(m bson.M) int64 {
+	switch v := m["version"].(type) {
+	case int64:
+		return v
+	case int32:
+		return int64(v)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,221 @@
+package crud
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"testing"
+)
+
+// newFruit - fruta de teste com o nome informado
+func newFruit(name string) *Fruit {
+	return &Fruit{Plant: Plant{Name: name}}
+}
+
+// fruitNames - nomes das frutas, na ordem recebida
+func fruitNames(docs []Fruit) []string {
+	names := make([]string, len(docs))
+	for i := range docs {
+		names[i] = docs[i].Name
+	}
+	return names
+}
+
+func TestMemoryRepositoryCRUD(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[Fruit]()
+
+	doc := newFruit("Pitanga")
+	if err := repo.Create(ctx, doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.ID.IsZero() {
+		t.Fatal("Create deveria preencher o ID")
+	}
+
+	got, err := repo.Read(ctx, doc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "Pitanga" {
+		t.Errorf("Read = %+v", got)
+	}
+
+	// o documento lido não compartilha memória com o repositório
+	got.Name = "Alterada"
+	if again, _ := repo.Read(ctx, doc.ID); again.Name != "Pitanga" {
+		t.Errorf("o repositório foi alterado pelo documento lido: %+v", again)
+	}
+
+	updated, err := repo.Update(ctx, doc.ID, &Fruit{Plant: Plant{Name: "Pitanga-roxa"}}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "Pitanga-roxa" || updated.ID != doc.ID || updated.Version != 2 {
+		t.Errorf("depois do Update: %+v", updated)
+	}
+
+	if err := repo.Delete(ctx, doc.ID, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Read(ctx, doc.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Read depois do Delete = %v, esperado ErrNotFound", err)
+	}
+	if _, err := repo.Update(ctx, doc.ID, newFruit("Pitanga"), AnyVersion); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update depois do Delete = %v, esperado ErrNotFound", err)
+	}
+	if err := repo.Delete(ctx, doc.ID, AnyVersion); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete repetido = %v, esperado ErrNotFound", err)
+	}
+}
+
+func TestMemoryRepositoryVersion(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[Fruit]()
+
+	doc := newFruit("Pitanga")
+	if err := repo.Create(ctx, doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Version != 1 {
+		t.Fatalf("Create deveria começar na versão 1: %d", doc.Version)
+	}
+
+	if _, err := repo.Update(ctx, doc.ID, newFruit("Acerola"), 2); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("Update com versão errada = %v, esperado ErrVersionMismatch", err)
+	}
+
+	updated, err := repo.Update(ctx, doc.ID, newFruit("Acerola"), AnyVersion)
+	if err != nil || updated.Version != 2 {
+		t.Fatalf("Update com AnyVersion = %+v, %v", updated, err)
+	}
+
+	if err := repo.Delete(ctx, doc.ID, 1); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("Delete com versão antiga = %v, esperado ErrVersionMismatch", err)
+	}
+}
+
+func TestMemoryRepositoryDuplicateID(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[Fruit]()
+
+	id := primitive.NewObjectID()
+	if err := repo.Create(ctx, &Fruit{Plant: Plant{ID: id, Name: "Pitanga"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Create(ctx, &Fruit{Plant: Plant{ID: id, Name: "Acerola"}}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Create com ID repetido = %v, esperado ErrConflict", err)
+	}
+}
+
+func TestMemoryRepositoryList(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[Fruit]()
+
+	for _, name := range []string{"Acerola", "Banana", "Caju", "Goiaba"} {
+		if err := repo.Create(ctx, newFruit(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		opts ListOptions
+		want []string
+	}{
+		{"tudo", ListOptions{}, []string{"Acerola", "Banana", "Caju", "Goiaba"}},
+		{"limite", ListOptions{Limit: 2}, []string{"Acerola", "Banana"}},
+		{"deslocamento", ListOptions{Limit: 2, Offset: 3}, []string{"Goiaba"}},
+		{"além do fim", ListOptions{Offset: 10}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs, err := repo.List(ctx, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fruitNames(docs); !equalStrings(got, tt.want) {
+				t.Errorf("List = %v, esperado %v", got, tt.want)
+			}
+		})
+	}
+
+	if n, err := repo.Count(ctx, nil); err != nil || n != 4 {
+		t.Errorf("Count = %d, %v", n, err)
+	}
+}
+
+// equalStrings - compara as listas elemento a elemento
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMemoryRepositoryPatch(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[Fruit]()
+
+	doc := &Fruit{Plant: Plant{Name: "Pitanga", Description: "azeda", Planting: "sementes"}}
+	if err := repo.Create(ctx, doc); err != nil {
+		t.Fatal(err)
+	}
+
+	// description vai para Set e planting, vazio, para Unset; name não foi informado
+	patch, err := NewPatch(&Fruit{Plant: Plant{Name: "Ignorado", Description: "doce"}}, []string{"description", "planting"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := repo.Patch(ctx, doc.ID, patch, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name != "Pitanga" || updated.Description != "doce" || updated.Planting != "" || updated.Version != 2 {
+		t.Errorf("Patch = %+v", updated)
+	}
+
+	if _, err := repo.Patch(ctx, primitive.NewObjectID(), patch, AnyVersion); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Patch de documento inexistente = %v, esperado ErrNotFound", err)
+	}
+}
+
+func TestMemoryRepositoryPatchNested(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[Fruit]()
+
+	doc := &Fruit{Plant: Plant{Name: "Pitanga", IdealTemperature: &TemperatureRange{MinCelsius: 18, MaxCelsius: 30}}}
+	if err := repo.Create(ctx, doc); err != nil {
+		t.Fatal(err)
+	}
+
+	// o caminho "a.b" altera somente o subcampo, mantendo os demais
+	patch, err := NewPatch(&Fruit{Plant: Plant{IdealTemperature: &TemperatureRange{MaxCelsius: 32}}}, []string{"ideal_temperature.max_celsius"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := repo.Patch(ctx, doc.ID, patch, AnyVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if temp := updated.IdealTemperature; temp == nil || temp.MinCelsius != 18 || temp.MaxCelsius != 32 {
+		t.Errorf("Patch = %+v", updated.IdealTemperature)
+	}
+
+	found, err := repo.FindByNames(ctx, []string{" PITANGA ", "Acerola"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalStrings(fruitNames(found), []string{"Pitanga"}) {
+		t.Errorf("FindByNames = %v", fruitNames(found))
+	}
+}
@@ -0,0 +1,322 @@
+package crud
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Campos em texto livre anteriores ao modelo estruturado
+const (
+	legacyDevelopment = "development_eta"
+	legacyTemperature = "ideal_development_temperature"
+	legacyHarvest     = "harvest"
+	legacySunlight    = "sunlight"
+	legacyIrrigation  = "irrigation"
+)
+
+// MigrationReport - resultado da migração dos campos agronômicos de uma coleção
+type MigrationReport struct {
+	Collection string
+	Migrated   int
+	// Flagged - IDs dos documentos com textos não interpretados, guardados em "legacy"
+	Flagged map[string][]string
+}
+
+// MigrateAgronomy - converte os campos agronômicos em texto livre para o modelo estruturado
+//
+// Os textos interpretados são substituídos pelos campos tipados; os demais são
+// movidos para o subdocumento "legacy" para revisão manual.
+func MigrateAgronomy(ctx context.Context, coll *mongo.Collection) (*MigrationReport, error) {
+	report := &MigrationReport{Collection: coll.Name(), Flagged: map[string][]string{}}
+
+	filter := bson.M{"$or": bson.A{
+		bson.M{legacyDevelopment: bson.M{"$exists": true}},
+		bson.M{legacyTemperature: bson.M{"$exists": true}},
+		bson.M{legacyHarvest: bson.M{"$exists": true}},
+		bson.M{legacySunlight: bson.M{"$type": "string"}},
+		bson.M{legacyIrrigation: bson.M{"$type": "string"}},
+	}}
+
+	cur, err := coll.Find(ctx, filter, options.Find().SetProjection(bson.M{
+		legacyDevelopment: 1, legacyTemperature: 1, legacyHarvest: 1, legacySunlight: 1, legacyIrrigation: 1,
+	}))
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	defer func(cur *mongo.Cursor, ctx context.Context) {
+		err := cur.Close(ctx)
+		if err != nil {
+			log.Println(err)
+		}
+	}(cur, ctx)
+
+	for cur.Next(ctx) {
+		var doc bson.M
+		if err := cur.Decode(&doc); err != nil {
+			return nil, err
+		}
+
+		set, unset, flagged := migrateDocument(doc)
+
+		update := bson.M{"$inc": bson.M{"version": 1}}
+		if len(set) > 0 {
+			update["$set"] = set
+		}
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+
+		if _, err := coll.UpdateOne(ctx, bson.M{"_id": doc["_id"]}, update); err != nil {
+			return nil, mongoError(err)
+		}
+
+		report.Migrated++
+		if len(flagged) > 0 {
+			report.Flagged[idString(doc["_id"])] = flagged
+		}
+	}
+
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// migrateDocument - calcula as alterações que estruturam os campos legados de um documento
+func migrateDocument(doc bson.M) (set bson.M, unset bson.M, flagged []string) {
+	set, unset = bson.M{}, bson.M{}
+
+	migrate := func(legacy, field string, parse func(string) (interface{}, bool)) {
+		text, ok := doc[legacy].(string)
+		if !ok {
+			return
+		}
+		if legacy != field {
+			unset[legacy] = ""
+		}
+		if strings.TrimSpace(text) == "" {
+			if legacy == field {
+				unset[legacy] = ""
+			}
+			return
+		}
+		if value, ok := parse(text); ok {
+			set[field] = value
+			return
+		}
+		if legacy == field {
+			unset[legacy] = ""
+		}
+		set["legacy."+legacy] = text
+		flagged = append(flagged, legacy)
+	}
+
+	migrate(legacyDevelopment, "development_days", func(s string) (interface{}, bool) { return ParseDayRange(s) })
+	migrate(legacyTemperature, "ideal_temperature", func(s string) (interface{}, bool) { return ParseTemperatureRange(s) })
+	migrate(legacyHarvest, "harvest_months", func(s string) (interface{}, bool) { return ParseHarvestMonths(s) })
+	migrate(legacySunlight, legacySunlight, func(s string) (interface{}, bool) { return ParseSunlight(s) })
+	migrate(legacyIrrigation, legacyIrrigation, func(s string) (interface{}, bool) { return ParseIrrigation(s) })
+
+	return set, unset, flagged
+}
+
+// idString - representação textual do _id para o relatório
+func idString(id interface{}) string {
+	if oid, ok := id.(primitive.ObjectID); ok {
+		return oid.Hex()
+	}
+	return fmt.Sprint(id)
+}
+
+var (
+	numberPattern = regexp.MustCompile(`-?\d+(?:[.,]\d+)?`)
+	rangeDash     = regexp.MustCompile(`(\d)\s*-\s*(\d)`)
+	volumePattern = regexp.MustCompile(`(\d+(?:[.,]\d+)?)\s*(ml|l\b|litro)`)
+)
+
+// numbers - extrai os números do texto, aceitando vírgula decimal
+func numbers(s string) []float64 {
+	var out []float64
+	for _, m := range numberPattern.FindAllString(s, -1) {
+		n, err := strconv.ParseFloat(strings.Replace(m, ",", ".", 1), 64)
+		if err == nil {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// ParseDayRange - interpreta textos como "90 a 120 dias", "3 meses" ou "2-4 semanas"
+func ParseDayRange(s string) (*DayRange, bool) {
+	text := normalizeText(s)
+	nums := numbers(text)
+	if len(nums) == 0 || len(nums) > 2 {
+		return nil, false
+	}
+
+	factor := 0.0
+	switch {
+	case strings.Contains(text, "dia"):
+		factor = 1
+	case strings.Contains(text, "semana"):
+		factor = 7
+	case strings.Contains(text, "mes"):
+		factor = 30
+	case strings.Contains(text, "ano"):
+		factor = 365
+	default:
+		return nil, false
+	}
+
+	min, max := nums[0], nums[len(nums)-1]
+	if min < 0 || max < min {
+		return nil, false
+	}
+	return &DayRange{MinDays: int(math.Round(min * factor)), MaxDays: int(math.Round(max * factor))}, true
+}
+
+// ParseTemperatureRange - interpreta textos como "20°C a 30°C" ou "entre 18 e 25 graus"
+func ParseTemperatureRange(s string) (*TemperatureRange, bool) {
+	// o hífen entre dois números indica faixa; nos demais casos, sinal negativo
+	text := rangeDash.ReplaceAllString(strings.ToLower(foldAccents(s)), "$1 a $2")
+	nums := numbers(text)
+	if len(nums) == 0 || len(nums) > 2 {
+		return nil, false
+	}
+
+	min, max := nums[0], nums[len(nums)-1]
+	if max < min || min < -50 || max > 60 {
+		return nil, false
+	}
+	return &TemperatureRange{MinCelsius: min, MaxCelsius: max}, true
+}
+
+// monthNames - prefixos dos nomes dos meses em português, sem acentos
+var monthNames = []string{"jan", "fev", "mar", "abr", "mai", "jun", "jul", "ago", "set", "out", "nov", "dez"}
+
+var wordPattern = regexp.MustCompile(`[a-z]+`)
+
+// ParseHarvestMonths - interpreta textos como "março a maio", "jan, fev" ou "o ano todo"
+func ParseHarvestMonths(s string) ([]int, bool) {
+	text := normalizeText(s)
+	if strings.Contains(text, "ano todo") || strings.Contains(text, "ano inteiro") {
+		return []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}, true
+	}
+
+	var months []int
+	rangeNext := false
+	for _, word := range wordPattern.FindAllString(text, -1) {
+		if word == "a" || word == "ate" {
+			rangeNext = len(months) > 0
+			continue
+		}
+
+		month := 0
+		for i, name := range monthNames {
+			if len(word) >= 3 && strings.HasPrefix(word, name) {
+				month = i + 1
+				break
+			}
+		}
+		if month == 0 {
+			continue
+		}
+
+		if rangeNext {
+			for m := months[len(months)-1]%12 + 1; m != month; m = m%12 + 1 {
+				months = append(months, m)
+			}
+			rangeNext = false
+		}
+		months = append(months, month)
+	}
+
+	if len(months) == 0 {
+		return nil, false
+	}
+	return uniqueSorted(months), true
+}
+
+// ParseSunlight - interpreta textos como "sol pleno", "meia-sombra" ou "6 horas de sol"
+func ParseSunlight(s string) (*Sunlight, bool) {
+	text := normalizeText(s)
+
+	sun := &Sunlight{}
+	switch {
+	case strings.Contains(text, "meia sombra") || strings.Contains(text, "parcial") || strings.Contains(text, "indireta"):
+		sun.Exposure = SunlightPartial
+	case strings.Contains(text, "sombra"):
+		sun.Exposure = SunlightShade
+	case strings.Contains(text, "sol"), strings.Contains(text, "pleno"), strings.Contains(text, "direta"):
+		sun.Exposure = SunlightFull
+	default:
+		return nil, false
+	}
+
+	if nums := numbers(text); len(nums) > 0 && strings.Contains(text, "hora") {
+		sun.Hours = nums[0]
+	}
+	return sun, true
+}
+
+// ParseIrrigation - interpreta textos como "diária, 2 litros" ou "duas vezes por semana"
+func ParseIrrigation(s string) (*Irrigation, bool) {
+	text := normalizeText(s)
+
+	irr := &Irrigation{}
+	switch {
+	case strings.Contains(text, "alternad") || strings.Contains(text, "dia sim"):
+		irr.Frequency = IrrigationAlternateDays
+	case strings.Contains(text, "diari") || strings.Contains(text, "todos os dias") || strings.Contains(text, "por dia"):
+		irr.Frequency = IrrigationDaily
+	case strings.Contains(text, "duas vezes por semana") || strings.Contains(text, "2 vezes por semana") || strings.Contains(text, "2x por semana"):
+		irr.Frequency = IrrigationTwiceWeekly
+	case strings.Contains(text, "quinzen"):
+		irr.Frequency = IrrigationBiweekly
+	case strings.Contains(text, "seman"):
+		irr.Frequency = IrrigationWeekly
+	case strings.Contains(text, "mens") || strings.Contains(text, "por mes"):
+		irr.Frequency = IrrigationMonthly
+	default:
+		return nil, false
+	}
+
+	if m := volumePattern.FindStringSubmatch(text); m != nil {
+		n, _ := strconv.ParseFloat(strings.Replace(m[1], ",", ".", 1), 64)
+		if m[2] == "ml" {
+			n /= 1000
+		}
+		irr.VolumeLiters = n
+	}
+	return irr, true
+}
+
+// normalizeText - minúsculas, sem acentos e com hífens trocados por espaços
+func normalizeText(s string) string {
+	s = strings.ToLower(foldAccents(s))
+	return strings.Join(strings.Fields(strings.ReplaceAll(s, "-", " ")), " ")
+}
+
+// uniqueSorted - ordena a lista removendo repetições
+func uniqueSorted(items []int) []int {
+	sort.Ints(items)
+
+	out := items[:0]
+	for i, item := range items {
+		if i == 0 || item != items[i-1] {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,253 @@
+package crud
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"time"
+)
+
+// MongoRepository - PlantRepository persistido em uma coleção do MongoDB
+type MongoRepository[T any, PT DocumentPtr[T]] struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoRepository - cria um repositório para a coleção informada
+func NewMongoRepository[T any, PT DocumentPtr[T]](coll *mongo.Collection) *MongoRepository[T, PT] {
+	return &MongoRepository[T, PT]{Collection: coll}
+}
+
+// Create - insere um novo documento e preenche o ID gerado
+func (r *MongoRepository[T, PT]) Create(ctx context.Context, doc *T) error {
+	PT(doc).PlantData().Version = 1
+	res, err := r.Collection.InsertOne(ctx, doc)
+	if err != nil {
+		return mongoError(err)
+	}
+	PT(doc).PlantData().ID = res.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// Read - busca um documento pelo ID
+func (r *MongoRepository[T, PT]) Read(ctx context.Context, id primitive.ObjectID) (*T, error) {
+	var doc T
+	filter := bson.M{"_id": id, "deleted_at": nil}
+	err := r.Collection.FindOne(ctx, filter).Decode(&doc)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	return &doc, nil
+}
+
+// Update - substitui todos os campos do documento com o ID informado e retorna o documento atualizado
+func (r *MongoRepository[T, PT]) Update(ctx context.Context, id primitive.ObjectID, doc *T, version int64) (*T, error) {
+	patch, err := ReplacePatch(doc)
+	if err != nil {
+		return nil, err
+	}
+	return r.Patch(ctx, id, patch, version)
+}
+
+// Patch - aplica somente os campos alterados com $set/$unset e retorna o documento atualizado
+func (r *MongoRepository[T, PT]) Patch(ctx context.Context, id primitive.ObjectID, patch Patch, version int64) (*T, error) {
+	_, doc, err := r.PatchWithBefore(ctx, id, patch, version)
+	return doc, err
+}
+
+// PatchWithBefore - aplica somente os campos alterados com $set/$unset e retorna o documento
+// antes e depois da alteração
+//
+// O MongoDB devolve só um dos estados; o outro é calculado aplicando o Patch ao anterior,
+// o que dá o mesmo resultado que o update, sem uma segunda leitura sujeita a concorrência.
+func (r *MongoRepository[T, PT]) PatchWithBefore(ctx context.Context, id primitive.ObjectID, patch Patch, version int64) (*T, *T, error) {
+	m, err := r.findAndUpdate(ctx, versionFilter(id, version), patchUpdate(patch))
+	if err != nil {
+		return nil, nil, r.missing(ctx, bson.M{"_id": id, "deleted_at": nil}, err)
+	}
+
+	before, err := decodeDocument[T](m)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	patch.apply(m)
+
+	after, err := decodeDocument[T](m)
+	if err != nil {
+		return nil, nil, err
+	}
+	return before, after, nil
+}
+
+// Delete - move o documento com o ID informado para a lixeira
+func (r *MongoRepository[T, PT]) Delete(ctx context.Context, id primitive.ObjectID, version int64) error {
+	_, err := r.DeleteWithBefore(ctx, id, version)
+	return err
+}
+
+// DeleteWithBefore - move o documento com o ID informado para a lixeira e retorna o documento antes da remoção
+func (r *MongoRepository[T, PT]) DeleteWithBefore(ctx context.Context, id primitive.ObjectID, version int64) (*T, error) {
+	update := bson.M{
+		"$set": bson.M{"deleted_at": time.Now().UTC(), "deleted_by": Actor(ctx)},
+		"$inc": bson.M{"version": 1},
+	}
+
+	m, err := r.findAndUpdate(ctx, versionFilter(id, version), update)
+	if err != nil {
+		return nil, r.missing(ctx, bson.M{"_id": id, "deleted_at": nil}, err)
+	}
+
+	return decodeDocument[T](m)
+}
+
+// findAndUpdate - aplica o update ao documento do filtro e o retorna decodificado como estava antes
+func (r *MongoRepository[T, PT]) findAndUpdate(ctx context.Context, filter, update bson.M) (bson.M, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+
+	raw, err := r.Collection.FindOneAndUpdate(ctx, filter, update, opts).DecodeBytes()
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	var m bson.M
+	if err := bson.Unmarshal(raw, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// missing - diferencia um documento inexistente de uma versão divergente quando o filtro não encontra nada;
+// exists localiza o documento sem considerar a versão
+func (r *MongoRepository[T, PT]) missing(ctx context.Context, exists bson.M, err error) error {
+	if err != ErrNotFound {
+		return err
+	}
+
+	n, cerr := r.Collection.CountDocuments(ctx, exists, options.Count().SetLimit(1))
+	if cerr != nil {
+		return mongoError(cerr)
+	}
+	if n > 0 {
+		return ErrVersionMismatch
+	}
+	return ErrNotFound
+}
+
+// patchUpdate - operadores $set/$unset do Patch, incrementando a versão
+func patchUpdate(patch Patch) bson.M {
+	update := bson.M{"$inc": bson.M{"version": 1}}
+	if len(patch.Set) > 0 {
+		update["$set"] = patch.Set
+	}
+	if len(patch.Unset) > 0 {
+		unset := bson.M{}
+		for _, field := range patch.Unset {
+			unset[field] = ""
+		}
+		update["$unset"] = unset
+	}
+	return update
+}
+
+// versionFilter - filtra pelo ID e, quando informada, pela versão esperada, fora da lixeira
+func versionFilter(id primitive.ObjectID, version int64) bson.M {
+	filter := bson.M{"_id": id, "deleted_at": nil}
+
+	switch {
+	case version == AnyVersion:
+	case version == 0:
+		// documentos anteriores ao controle de versão não têm o campo
+		filter["version"] = bson.M{"$in": bson.A{0, nil}}
+	default:
+		filter["version"] = version
+	}
+
+	return filter
+}
+
+// List - retorna os documentos que atendem ao filtro, ordenados, limitando e pulando resultados
+func (r *MongoRepository[T, PT]) List(ctx context.Context, opts ListOptions) ([]T, error) {
+	return r.find(ctx, false, opts)
+}
+
+// find - lista os documentos fora ou dentro da lixeira
+func (r *MongoRepository[T, PT]) find(ctx context.Context, trash bool, opts ListOptions) ([]T, error) {
+	order := SortOrder(opts.Sort)
+	anchor, backward, err := opts.anchor(order)
+	if err != nil {
+		return nil, err
+	}
+	if backward {
+		order = reversed(order)
+	}
+
+	filter := liveFilter(opts.Filter, trash)
+	if anchor != nil {
+		filter = bson.M{"$and": bson.A{filter, mongoKeyset(order, anchor)}}
+	}
+
+	findOptions := options.Find()
+	findOptions.SetLimit(opts.Limit)
+	findOptions.SetSkip(opts.Offset)
+	findOptions.SetSort(mongoSort(order))
+	if projection := mongoProjection(opts.Fields); projection != nil {
+		findOptions.SetProjection(projection)
+	}
+
+	cur, err := r.Collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	defer func(cur *mongo.Cursor, ctx context.Context) {
+		err := cur.Close(ctx)
+		if err != nil {
+			log.Println(err)
+		}
+	}(cur, ctx)
+
+	var docs []T
+	for cur.Next(ctx) {
+		var doc T
+		if err := cur.Decode(&doc); err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+
+	if backward {
+		reverse(docs)
+	}
+
+	return docs, nil
+}
+
+// Count - retorna o total de documentos que atendem ao filtro
+func (r *MongoRepository[T, PT]) Count(ctx context.Context, filter []Condition) (int64, error) {
+	n, err := r.Collection.CountDocuments(ctx, liveFilter(filter, false))
+	return n, mongoError(err)
+}
+
+// liveFilter - filtro das condições restrito aos documentos fora da lixeira ou, com trash, aos da lixeira
+func liveFilter(conds []Condition, trash bool) bson.M {
+	filter := mongoFilter(conds)
+	if trash {
+		filter["deleted_at"] = bson.M{"$ne": nil}
+	} else {
+		filter["deleted_at"] = nil
+	}
+	return filter
+}
+
+// reverse - inverte a ordem da lista
+func reverse[T any](items []T) {
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+}
@@ -0,0 +1,126 @@
+package crud
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Tipos de Change
+const (
+	ChangeCreate  = "create"
+	ChangeUpdate  = "update"
+	ChangeDelete  = "delete"
+	ChangeRestore = "restore"
+	ChangePurge   = "purge"
+)
+
+// Change - alteração bem-sucedida de um documento
+type Change struct {
+	Category string
+	ID       primitive.ObjectID
+	// Action - ChangeCreate, ChangeUpdate, ChangeDelete (ida para a lixeira), ChangeRestore ou ChangePurge
+	Action string
+	// Before - estado anterior, devolvido pela mesma operação que gravou a alteração; nil na criação
+	Before *Plant
+	// Plant - estado após a alteração; nil quando o documento foi removido ou está na lixeira
+	Plant *Plant
+}
+
+// ChangeListener - recebe as alterações feitas por um NotifyingRepository
+type ChangeListener interface {
+	Changed(ctx context.Context, change Change)
+}
+
+// NotifyingRepository - PlantRepository que avisa os listeners após cada Create, Update, Patch,
+// Delete, Restore e Purge
+type NotifyingRepository[T any, PT DocumentPtr[T]] struct {
+	PlantRepository[T]
+	Category  string
+	Listeners []ChangeListener
+
+	// writer - o mesmo repositório, para as alterações que devolvem o estado anterior
+	writer PreImageRepository[T]
+}
+
+// NewNotifyingRepository - envolve o repositório, avisando os listeners das alterações
+func NewNotifyingRepository[T any, PT DocumentPtr[T]](repo PreImageRepository[T], category string, listeners ...ChangeListener) *NotifyingRepository[T, PT] {
+	return &NotifyingRepository[T, PT]{PlantRepository: repo, Category: category, Listeners: listeners, writer: repo}
+}
+
+// Create - insere o documento e avisa os listeners
+func (r *NotifyingRepository[T, PT]) Create(ctx context.Context, doc *T) error {
+	if err := r.PlantRepository.Create(ctx, doc); err != nil {
+		return err
+	}
+	r.notify(ctx, ChangeCreate, PT(doc).PlantData().ID, nil, doc)
+	return nil
+}
+
+// Update - substitui o documento e avisa os listeners
+func (r *NotifyingRepository[T, PT]) Update(ctx context.Context, id primitive.ObjectID, doc *T, version int64) (*T, error) {
+	patch, err := ReplacePatch(doc)
+	if err != nil {
+		return nil, err
+	}
+	return r.Patch(ctx, id, patch, version)
+}
+
+// Patch - altera parcialmente o documento e avisa os listeners
+func (r *NotifyingRepository[T, PT]) Patch(ctx context.Context, id primitive.ObjectID, patch Patch, version int64) (*T, error) {
+	before, updated, err := r.writer.PatchWithBefore(ctx, id, patch, version)
+	if err != nil {
+		return nil, err
+	}
+	r.notify(ctx, ChangeUpdate, id, plantData[T, PT](before), updated)
+	return updated, nil
+}
+
+// Delete - move o documento para a lixeira e avisa os listeners
+func (r *NotifyingRepository[T, PT]) Delete(ctx context.Context, id primitive.ObjectID, version int64) error {
+	before, err := r.writer.DeleteWithBefore(ctx, id, version)
+	if err != nil {
+		return err
+	}
+	r.notify(ctx, ChangeDelete, id, plantData[T, PT](before), nil)
+	return nil
+}
+
+// Restore - devolve o documento da lixeira e avisa os listeners, com o documento da lixeira como estado anterior
+func (r *NotifyingRepository[T, PT]) Restore(ctx context.Context, id primitive.ObjectID, version int64) (*T, error) {
+	before, restored, err := r.writer.RestoreWithBefore(ctx, id, version)
+	if err != nil {
+		return nil, err
+	}
+	r.notify(ctx, ChangeRestore, id, plantData[T, PT](before), restored)
+	return restored, nil
+}
+
+// Purge - remove definitivamente os documentos antigos da lixeira e avisa os listeners de cada um
+func (r *NotifyingRepository[T, PT]) Purge(ctx context.Context, before time.Time) ([]T, error) {
+	purged, err := r.PlantRepository.Purge(ctx, before)
+	for i := range purged {
+		plant := PT(&purged[i]).PlantData()
+		r.notify(ctx, ChangePurge, plant.ID, plant, nil)
+	}
+	return purged, err
+}
+
+// plantData - campos comuns do documento, ou nil
+func plantData[T any, PT DocumentPtr[T]](doc *T) *Plant {
+	if doc == nil {
+		return nil
+	}
+	return PT(doc).PlantData()
+}
+
+// notify - avisa todos os listeners da alteração
+func (r *NotifyingRepository[T, PT]) notify(ctx context.Context, action string, id primitive.ObjectID, before *Plant, doc *T) {
+	change := Change{Category: r.Category, ID: id, Action: action, Before: before}
+	if doc != nil {
+		change.Plant = PT(doc).PlantData()
+	}
+	for _, l := range r.Listeners {
+		l.Changed(ctx, change)
+	}
+}
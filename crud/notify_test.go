@@ -0,0 +1,125 @@
+package crud
+
+import (
+	"context"
+	"testing"
+)
+
+// recorder - ChangeListener que guarda as alterações recebidas
+type recorder struct {
+	changes []Change
+}
+
+func (r *recorder) Changed(ctx context.Context, change Change) {
+	r.changes = append(r.changes, change)
+}
+
+func TestNotifyingRepository(t *testing.T) {
+	ctx := context.Background()
+	rec := &recorder{}
+	repo := NewNotifyingRepository[Fruit](NewMemoryRepository[Fruit](), "fruits", rec)
+
+	doc := newFruit("Pitanga")
+	if err := repo.Create(ctx, doc); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Update(ctx, doc.ID, newFruit("Pitanga-roxa"), AnyVersion); err != nil {
+		t.Fatal(err)
+	}
+
+	// as operações que falham não são avisadas
+	if _, err := repo.Update(ctx, doc.ID, newFruit("Acerola"), 1); err == nil {
+		t.Fatal("Update com versão antiga deveria falhar")
+	}
+
+	if err := repo.Delete(ctx, doc.ID, AnyVersion); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.changes) != 3 {
+		t.Fatalf("alterações = %+v, esperado 3", rec.changes)
+	}
+
+	for i, want := range []string{"Pitanga", "Pitanga-roxa", ""} {
+		change := rec.changes[i]
+		if change.Category != "fruits" || change.ID != doc.ID {
+			t.Errorf("alteração %d = %+v", i, change)
+		}
+		if name := plantName(change.Plant); name != want {
+			t.Errorf("alteração %d com o nome %q, esperado %q", i, name, want)
+		}
+	}
+}
+
+func TestNameIndexListener(t *testing.T) {
+	ctx := context.Background()
+	ix := NewNameIndex()
+	repo := NewNotifyingRepository[Fruit](NewMemoryRepository[Fruit](), "fruits", ix)
+
+	doc := newFruit("Pitanga")
+	if err := repo.Create(ctx, doc); err != nil {
+		t.Fatal(err)
+	}
+	if got := ix.Suggest("pita", 10); len(got) != 1 || got[0].Name != "Pitanga" {
+		t.Errorf("Suggest depois do Create = %+v", got)
+	}
+
+	if err := repo.Delete(ctx, doc.ID, AnyVersion); err != nil {
+		t.Fatal(err)
+	}
+	if got := ix.Suggest("pita", 10); len(got) != 0 {
+		t.Errorf("Suggest depois do Delete = %+v", got)
+	}
+}
+
+// plantName - nome da planta, vazio quando ela foi removida
+func plantName(p *Plant) string {
+	if p == nil {
+		return ""
+	}
+	return p.Name
+}
+
+func TestNotifyingRepositoryBefore(t *testing.T) {
+	ctx := WithActor(context.Background(), "ana")
+	rec := &recorder{}
+	repo := NewNotifyingRepository[Fruit](NewMemoryRepository[Fruit](), "fruits", rec)
+
+	doc := newFruit("Pitanga")
+	if err := repo.Create(ctx, doc); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Update(ctx, doc.ID, newFruit("Pitanga-roxa"), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Delete(ctx, doc.ID, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Restore(ctx, doc.ID, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.changes) != 4 {
+		t.Fatalf("alterações = %+v, esperado 4", rec.changes)
+	}
+
+	// o estado anterior vem da própria gravação, com a versão que ela substituiu
+	for i, want := range []struct {
+		name    string
+		version int64
+	}{{"", 0}, {"Pitanga", 1}, {"Pitanga-roxa", 2}, {"Pitanga-roxa", 3}} {
+		before := rec.changes[i].Before
+		if name := plantName(before); name != want.name || (before != nil && before.Version != want.version) {
+			t.Errorf("alteração %d com o estado anterior %+v, esperado %s na versão %d", i, before, want.name, want.version)
+		}
+	}
+
+	// a restauração registra o documento como estava na lixeira
+	restore := rec.changes[3]
+	if restore.Action != ChangeRestore || restore.Before.DeletedAt == nil || restore.Before.DeletedBy != "ana" {
+		t.Errorf("restauração = %+v, antes %+v", restore, restore.Before)
+	}
+	if restore.Plant == nil || restore.Plant.DeletedAt != nil || restore.Plant.Version != 4 {
+		t.Errorf("restaurado = %+v", restore.Plant)
+	}
+}
@@ -0,0 +1,86 @@
+package crud
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+// Plant - campos comuns a todas as categorias de plantas
+//
+// As regras de validação ficam declaradas na tag `validate` de cada campo (ver Validate).
+type Plant struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Version          int64              `bson:"version" json:"version"`
+	Name             string             `bson:"name,omitempty" json:"name,omitempty" validate:"required,max=100"`
+	Description      string             `bson:"description,omitempty" json:"description,omitempty" validate:"max=2000"`
+	Development      *DayRange          `bson:"development_days,omitempty" json:"development_days,omitempty"`
+	IdealTemperature *TemperatureRange  `bson:"ideal_temperature,omitempty" json:"ideal_temperature,omitempty"`
+	HarvestMonths    []int              `bson:"harvest_months,omitempty" json:"harvest_months,omitempty" validate:"unique,dive,min=1,max=12"`
+	Sunlight         *Sunlight          `bson:"sunlight,omitempty" json:"sunlight,omitempty"`
+	Irrigation       *Irrigation        `bson:"irrigation,omitempty" json:"irrigation,omitempty"`
+	Planting         string             `bson:"planting,omitempty" json:"planting,omitempty" validate:"max=2000"`
+	ExtraInfo        string             `bson:"extra_info,omitempty" json:"extra_info,omitempty" validate:"max=2000"`
+	Observation      string             `bson:"observation,omitempty" json:"observation,omitempty" validate:"max=2000"`
+	// ImagePath - endereço da imagem de capa, mantido para os clientes anteriores a Images
+	ImagePath string `bson:"image_path,omitempty" json:"image_path,omitempty"`
+	// Images - imagens da planta, mantidas somente pelos endpoints de imagens
+	Images []Image `bson:"images,omitempty" json:"images,omitempty"`
+
+	Taxonomy    *Taxonomy    `bson:"taxonomy,omitempty" json:"taxonomy,omitempty"`
+	CommonNames []CommonName `bson:"common_names,omitempty" json:"common_names,omitempty"`
+
+	// Translations - textos traduzidos por idioma (ver Languages); os campos acima estão em DefaultLanguage
+	Translations map[string]Translation `bson:"translations,omitempty" json:"translations,omitempty" validate:"keys=en es"`
+	// Language - idioma dos campos de texto na resposta, quando diferente do padrão (ver Localize);
+	// na escrita só é aceito o padrão, para que uma resposta traduzida não sobrescreva o original
+	Language string `bson:"-" json:"language,omitempty" validate:"oneof=pt-BR"`
+
+	// Status - situação editorial (ver Transitions); vazio nos documentos anteriores ao fluxo, tratados como publicados
+	Status string `bson:"status,omitempty" json:"status,omitempty"`
+	// PublishAt - publicação agendada do documento aprovado, ou o momento em que foi publicado
+	PublishAt *time.Time `bson:"publish_at,omitempty" json:"publish_at,omitempty"`
+	// Reviews - pareceres dos revisores, do mais antigo ao mais recente
+	Reviews []Review `bson:"reviews,omitempty" json:"reviews,omitempty"`
+	// CreatedBy - autor da criação (ver Actor)
+	CreatedBy string `bson:"created_by,omitempty" json:"created_by,omitempty"`
+
+	// DeletedAt - momento em que o documento foi para a lixeira; os documentos da lixeira
+	// não aparecem nas leituras e listagens (ver PlantRepository.Trash)
+	DeletedAt *time.Time `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	// DeletedBy - autor da remoção (ver Actor)
+	DeletedBy string `bson:"deleted_by,omitempty" json:"deleted_by,omitempty"`
+
+	// Legacy - textos livres anteriores ao modelo estruturado que a migração não conseguiu interpretar
+	Legacy map[string]string `bson:"legacy,omitempty" json:"legacy,omitempty"`
+}
+
+// PlantData - retorna os campos comuns da planta
+func (p *Plant) PlantData() *Plant {
+	return p
+}
+
+// Document - documento de planta armazenado em uma coleção
+type Document interface {
+	PlantData() *Plant
+}
+
+// DocumentPtr - restringe T aos tipos cujo ponteiro implementa Document
+type DocumentPtr[T any] interface {
+	*T
+	Document
+}
+
+// Fruit - documento da coleção "fruits"
+type Fruit struct {
+	Plant `bson:",inline"`
+}
+
+// Vegetable - documento da coleção "vegetables"
+type Vegetable struct {
+	Plant `bson:",inline"`
+}
+
+// Green - documento da coleção "greens"
+type Green struct {
+	Plant `bson:",inline"`
+}
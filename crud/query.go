@@ -0,0 +1,384 @@
+package crud
+
+import (
+	"bytes"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strings"
+	"time"
+)
+
+// Operadores aceitos em Condition.Op
+const (
+	OpEq  = "eq"
+	OpIn  = "in"
+	OpGte = "gte"
+	OpLte = "lte"
+	// OpOr - alguma das listas de condições em Value ([][]Condition) é atendida; Field é ignorado
+	OpOr = "or"
+)
+
+// Condition - comparação de um campo (caminho BSON, "a.b") com um valor
+//
+// Assim como no MongoDB, OpEq e OpIn sobre um campo de lista correspondem
+// quando algum dos itens corresponde.
+type Condition struct {
+	Field string
+	Op    string
+	Value interface{}
+}
+
+// SortField - campo (caminho BSON) usado na ordenação
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// mongoFilter - converte as condições para um filtro do MongoDB
+func mongoFilter(conds []Condition) bson.M {
+	filter := bson.M{}
+	for _, c := range conds {
+		if c.Op == OpOr {
+			var or bson.A
+			for _, alt := range c.Value.([][]Condition) {
+				or = append(or, mongoFilter(alt))
+			}
+			and, _ := filter["$and"].(bson.A)
+			filter["$and"] = append(and, bson.M{"$or": or})
+			continue
+		}
+
+		ops, ok := filter[c.Field].(bson.M)
+		if !ok {
+			ops = bson.M{}
+			filter[c.Field] = ops
+		}
+		ops["$"+c.Op] = c.Value
+	}
+	return filter
+}
+
+// SortOrder - ordenação completa da listagem, desempatando pelo _id quando ele não está na lista
+func SortOrder(fields []SortField) []SortField {
+	order := append([]SortField{}, fields...)
+	for _, f := range fields {
+		if f.Field == "_id" {
+			return order
+		}
+	}
+	return append(order, SortField{Field: "_id"})
+}
+
+// SortKey - valores do documento nos campos da ordenação, usados como âncora de paginação
+func SortKey(doc interface{}, order []SortField) ([]interface{}, error) {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var m bson.M
+	if err := bson.Unmarshal(raw, &m); err != nil {
+		return nil, err
+	}
+
+	return sortKey(m, order), nil
+}
+
+// sortKey - valores do documento decodificado nos campos da ordenação
+func sortKey(m bson.M, order []SortField) []interface{} {
+	key := make([]interface{}, len(order))
+	for i, f := range order {
+		key[i] = lookup(m, f.Field)
+	}
+	return key
+}
+
+// reversed - inverte a direção de todos os campos da ordenação
+func reversed(order []SortField) []SortField {
+	out := make([]SortField, len(order))
+	for i, f := range order {
+		out[i] = SortField{Field: f.Field, Desc: !f.Desc}
+	}
+	return out
+}
+
+// mongoSort - converte a ordenação para o formato do MongoDB
+func mongoSort(order []SortField) bson.D {
+	sort := bson.D{}
+	for _, f := range order {
+		dir := 1
+		if f.Desc {
+			dir = -1
+		}
+		sort = append(sort, bson.E{Key: f.Field, Value: dir})
+	}
+	return sort
+}
+
+// mongoKeyset - filtro dos documentos posteriores à âncora na ordenação informada
+//
+// Para a ordenação (a, b) e a âncora (x, y), equivale a a > x OU (a = x E b > y),
+// considerando, como o MongoDB, que valores ausentes vêm antes de todos os outros.
+func mongoKeyset(order []SortField, anchor []interface{}) bson.M {
+	var or bson.A
+	for i, f := range order {
+		clause := bson.M{}
+		for j := 0; j < i; j++ {
+			clause[order[j].Field] = anchor[j]
+		}
+
+		after, ok := mongoAfter(f, anchor[i])
+		if !ok {
+			continue
+		}
+		for k, v := range after {
+			clause[k] = v
+		}
+		or = append(or, clause)
+	}
+
+	if len(or) == 0 {
+		// nenhum documento vem depois da âncora
+		return bson.M{"_id": bson.M{"$exists": false}}
+	}
+	return bson.M{"$or": or}
+}
+
+// mongoAfter - condição de um campo ser posterior ao valor na direção da ordenação
+func mongoAfter(f SortField, value interface{}) (bson.M, bool) {
+	switch {
+	case !f.Desc && value == nil:
+		return bson.M{f.Field: bson.M{"$ne": nil}}, true
+	case !f.Desc:
+		return bson.M{f.Field: bson.M{"$gt": value}}, true
+	case value == nil:
+		return nil, false
+	default:
+		return bson.M{"$or": bson.A{
+			bson.M{f.Field: bson.M{"$lt": value}},
+			bson.M{f.Field: nil},
+		}}, true
+	}
+}
+
+// compareKeys - compara dois documentos decodificados na ordenação informada
+func compareKeys(a bson.M, key []interface{}, order []SortField) int {
+	for i, f := range order {
+		c := compareValues(lookup(a, f.Field), key[i])
+		if c != 0 {
+			if f.Desc {
+				return -c
+			}
+			return c
+		}
+	}
+	return 0
+}
+
+// mongoProjection - converte a lista de campos para uma projeção do MongoDB
+func mongoProjection(fields []string) bson.M {
+	if len(fields) == 0 {
+		return nil
+	}
+	projection := bson.M{}
+	for _, f := range fields {
+		projection[f] = 1
+	}
+	return projection
+}
+
+// matches - avalia as condições sobre um documento decodificado em bson.M
+func matches(doc bson.M, conds []Condition) bool {
+	for _, c := range conds {
+		if c.Op == OpOr {
+			if !matchesAny(doc, c.Value.([][]Condition)) {
+				return false
+			}
+			continue
+		}
+
+		if !matchCondition(lookup(doc, c.Field), c) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesAny - indica se o documento atende a alguma das listas de condições
+func matchesAny(doc bson.M, alternatives [][]Condition) bool {
+	for _, conds := range alternatives {
+		if matches(doc, conds) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchCondition - avalia uma condição sobre o valor do campo
+func matchCondition(value interface{}, c Condition) bool {
+	if items, ok := value.(bson.A); ok && (c.Op == OpEq || c.Op == OpIn) {
+		for _, item := range items {
+			if matchCondition(item, c) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch c.Op {
+	case OpEq:
+		return compareValues(value, c.Value) == 0
+	case OpIn:
+		for _, candidate := range toList(c.Value) {
+			if compareValues(value, candidate) == 0 {
+				return true
+			}
+		}
+		return false
+	case OpGte:
+		return value != nil && compareValues(value, c.Value) >= 0
+	case OpLte:
+		return value != nil && compareValues(value, c.Value) <= 0
+	default:
+		return false
+	}
+}
+
+// lookup - valor no caminho "a.b" do documento, ou nil quando não existe
+func lookup(doc bson.M, path string) interface{} {
+	var current interface{} = doc
+	for _, key := range strings.Split(path, ".") {
+		m, ok := current.(bson.M)
+		if !ok {
+			return nil
+		}
+		current = m[key]
+	}
+	return current
+}
+
+// toList - converte o valor de OpIn em lista
+func toList(v interface{}) []interface{} {
+	switch l := v.(type) {
+	case bson.A:
+		return l
+	case []interface{}:
+		return l
+	case []string:
+		out := make([]interface{}, len(l))
+		for i, s := range l {
+			out[i] = s
+		}
+		return out
+	case []int:
+		out := make([]interface{}, len(l))
+		for i, n := range l {
+			out[i] = n
+		}
+		return out
+	default:
+		return []interface{}{v}
+	}
+}
+
+// compareValues - compara dois valores BSON; valores ausentes vêm antes de todos os outros
+func compareValues(a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+
+	if x, ok := toFloat(a); ok {
+		if y, ok := toFloat(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y)
+		}
+	case bool:
+		if y, ok := b.(bool); ok && x != y {
+			if !x {
+				return -1
+			}
+			return 1
+		} else if ok {
+			return 0
+		}
+	case primitive.ObjectID:
+		if y, ok := b.(primitive.ObjectID); ok {
+			return bytes.Compare(x[:], y[:])
+		}
+	case primitive.DateTime:
+		return compareValues(int64(x), timeMillis(b))
+	case time.Time:
+		return compareValues(x.UnixMilli(), timeMillis(b))
+	}
+
+	return strings.Compare(typeRank(a), typeRank(b))
+}
+
+// toFloat - valor numérico, quando for um número
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+// timeMillis - milissegundos de uma data BSON ou time.Time
+func timeMillis(v interface{}) interface{} {
+	switch t := v.(type) {
+	case primitive.DateTime:
+		return int64(t)
+	case time.Time:
+		return t.UnixMilli()
+	default:
+		return v
+	}
+}
+
+// typeRank - ordem entre tipos diferentes, aproximando a ordenação do MongoDB
+func typeRank(v interface{}) string {
+	switch v.(type) {
+	case int, int32, int64, float64:
+		return "1"
+	case string:
+		return "2"
+	case bson.M, bson.D:
+		return "3"
+	case bson.A:
+		return "4"
+	case primitive.ObjectID:
+		return "7"
+	case bool:
+		return "8"
+	case primitive.DateTime, time.Time:
+		return "9"
+	default:
+		return "z"
+	}
+}
@@ -0,0 +1,163 @@
+package crud
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"reflect"
+	"testing"
+)
+
+// newQueryRepository - repositório com frutas de meses de colheita e temperaturas variados
+func newQueryRepository(t *testing.T) *MemoryRepository[Fruit, *Fruit] {
+	t.Helper()
+
+	repo := NewMemoryRepository[Fruit]()
+	docs := []Fruit{
+		{Plant: Plant{Name: "Acerola", HarvestMonths: []int{1, 2}, IdealTemperature: &TemperatureRange{MinCelsius: 22, MaxCelsius: 32}}},
+		{Plant: Plant{Name: "Banana", HarvestMonths: []int{6}, IdealTemperature: &TemperatureRange{MinCelsius: 20, MaxCelsius: 35}}},
+		{Plant: Plant{Name: "Caju", HarvestMonths: []int{10, 11}}},
+		{Plant: Plant{Name: "Goiaba", HarvestMonths: []int{2, 3}, IdealTemperature: &TemperatureRange{MinCelsius: 20, MaxCelsius: 30}}},
+	}
+
+	for i := range docs {
+		if err := repo.Create(context.Background(), &docs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return repo
+}
+
+func TestMemoryRepositoryFilter(t *testing.T) {
+	ctx := context.Background()
+	repo := newQueryRepository(t)
+
+	tests := []struct {
+		name   string
+		filter []Condition
+		want   []string
+	}{
+		{"igualdade", []Condition{{Field: "name", Op: OpEq, Value: "Caju"}}, []string{"Caju"}},
+		{"item de lista", []Condition{{Field: "harvest_months", Op: OpEq, Value: 2}}, []string{"Acerola", "Goiaba"}},
+		{"algum dos valores", []Condition{{Field: "harvest_months", Op: OpIn, Value: []int{6, 11}}}, []string{"Banana", "Caju"}},
+		{"faixa em campo aninhado", []Condition{
+			{Field: "ideal_temperature.min_celsius", Op: OpGte, Value: 20},
+			{Field: "ideal_temperature.max_celsius", Op: OpLte, Value: 32},
+		}, []string{"Acerola", "Goiaba"}},
+		{"campo ausente não atende à faixa", []Condition{{Field: "ideal_temperature.min_celsius", Op: OpLte, Value: 100}}, []string{"Acerola", "Banana", "Goiaba"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs, err := repo.List(ctx, ListOptions{Filter: tt.filter})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fruitNames(docs); !equalStrings(got, tt.want) {
+				t.Errorf("List = %v, esperado %v", got, tt.want)
+			}
+
+			if n, err := repo.Count(ctx, tt.filter); err != nil || n != int64(len(tt.want)) {
+				t.Errorf("Count = %d, %v; esperado %d", n, err, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestMemoryRepositorySort(t *testing.T) {
+	ctx := context.Background()
+	repo := newQueryRepository(t)
+
+	tests := []struct {
+		name string
+		sort []SortField
+		want []string
+	}{
+		{"nome decrescente", []SortField{{Field: "name", Desc: true}}, []string{"Goiaba", "Caju", "Banana", "Acerola"}},
+		// os empates seguem a ordem de criação e os campos ausentes vêm primeiro, como no MongoDB
+		{"campo aninhado", []SortField{{Field: "ideal_temperature.min_celsius"}}, []string{"Caju", "Banana", "Goiaba", "Acerola"}},
+		{"dois campos", []SortField{{Field: "ideal_temperature.min_celsius", Desc: true}, {Field: "name", Desc: true}}, []string{"Acerola", "Goiaba", "Banana", "Caju"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			docs, err := repo.List(ctx, ListOptions{Sort: tt.sort})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fruitNames(docs); !equalStrings(got, tt.want) {
+				t.Errorf("List = %v, esperado %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMongoQuery(t *testing.T) {
+	filter := mongoFilter([]Condition{
+		{Field: "ideal_temperature.min_celsius", Op: OpGte, Value: 20},
+		{Field: "ideal_temperature.min_celsius", Op: OpLte, Value: 30},
+		{Field: "name", Op: OpEq, Value: "Caju"},
+	})
+
+	want := bson.M{
+		"ideal_temperature.min_celsius": bson.M{"$gte": 20, "$lte": 30},
+		"name":                          bson.M{"$eq": "Caju"},
+	}
+	if !reflect.DeepEqual(filter, want) {
+		t.Errorf("mongoFilter = %v, esperado %v", filter, want)
+	}
+
+	sort := mongoSort(SortOrder([]SortField{{Field: "name", Desc: true}}))
+	if want := (bson.D{{Key: "name", Value: -1}, {Key: "_id", Value: 1}}); !reflect.DeepEqual(sort, want) {
+		t.Errorf("mongoSort = %v, esperado %v", sort, want)
+	}
+}
+
+func TestMemoryRepositoryKeyset(t *testing.T) {
+	ctx := context.Background()
+	repo := newQueryRepository(t)
+
+	// Caju não tem temperatura e vem primeiro; Banana e Goiaba empatam e desempatam pelo _id
+	order := SortOrder([]SortField{{Field: "ideal_temperature.min_celsius"}})
+	all := []string{"Caju", "Banana", "Goiaba", "Acerola"}
+
+	var pages []string
+	var anchors [][]interface{}
+	opts := ListOptions{Sort: order, Limit: 1}
+
+	for {
+		docs, err := repo.List(ctx, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(docs) == 0 {
+			break
+		}
+
+		pages = append(pages, docs[0].Name)
+
+		key, err := SortKey(&docs[0], order)
+		if err != nil {
+			t.Fatal(err)
+		}
+		anchors = append(anchors, key)
+		opts.After = key
+	}
+
+	if !equalStrings(pages, all) {
+		t.Fatalf("páginas = %v, esperado %v", pages, all)
+	}
+
+	// Before devolve os documentos imediatamente anteriores à âncora, na ordem normal
+	docs, err := repo.List(ctx, ListOptions{Sort: order, Limit: 2, Before: anchors[3]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fruitNames(docs), []string{"Banana", "Goiaba"}; !equalStrings(got, want) {
+		t.Errorf("Before = %v, esperado %v", got, want)
+	}
+
+	if _, err := repo.List(ctx, ListOptions{Sort: order, After: []interface{}{20}}); !errors.Is(err, ErrValidation) {
+		t.Errorf("âncora incompleta = %v, esperado ErrValidation", err)
+	}
+}
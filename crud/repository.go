@@ -0,0 +1,214 @@
+package crud
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// AnyVersion - dispensa a verificação de versão em Update, Patch e Delete
+const AnyVersion int64 = -1
+
+// PlantRepository - armazenamento dos documentos de uma categoria de plantas
+//
+// Update, Patch e Delete só alteram o documento se sua versão for igual à informada
+// (ou AnyVersion) e retornam ErrVersionMismatch caso contrário; toda alteração
+// incrementa a versão.
+//
+// Delete move o documento para a lixeira, preenchendo DeletedAt e DeletedBy. Os
+// documentos da lixeira só são vistos por Trash: os demais métodos os tratam como
+// inexistentes até que Restore os devolva ou Purge os remova definitivamente.
+type PlantRepository[T any] interface {
+	Create(ctx context.Context, doc *T) error
+	Read(ctx context.Context, id primitive.ObjectID) (*T, error)
+	Update(ctx context.Context, id primitive.ObjectID, doc *T, version int64) (*T, error)
+	Patch(ctx context.Context, id primitive.ObjectID, patch Patch, version int64) (*T, error)
+	Delete(ctx context.Context, id primitive.ObjectID, version int64) error
+	List(ctx context.Context, opts ListOptions) ([]T, error)
+	Count(ctx context.Context, filter []Condition) (int64, error)
+	// Search - busca textual entre os documentos que atendem ao filtro, limitada depois de filtrar
+	Search(ctx context.Context, text string, filter []Condition, limit int64) ([]SearchResult[T], error)
+	FindByName(ctx context.Context, name string) ([]T, error)
+	// FindByNames - documentos cujo nome principal é algum dos informados, sem diferenciar acentos e maiúsculas
+	FindByNames(ctx context.Context, names []string) ([]T, error)
+	// Trash - documentos da lixeira que atendem ao filtro
+	Trash(ctx context.Context, opts ListOptions) ([]T, error)
+	Restore(ctx context.Context, id primitive.ObjectID, version int64) (*T, error)
+	// Purge - remove definitivamente os documentos que estão na lixeira desde antes do
+	// momento informado e os retorna
+	Purge(ctx context.Context, before time.Time) ([]T, error)
+	// Bulk - executa as inclusões e alterações em um único lote, sem interromper nas
+	// falhas individuais; o resultado de cada operação fica na mesma posição
+	Bulk(ctx context.Context, ops []BulkOp[T]) ([]BulkResult[T], error)
+}
+
+// PreImageRepository - PlantRepository que devolve o estado anterior de cada alteração, lido
+// na mesma operação que a grava, para que o histórico não dependa de uma leitura separada
+// que uma alteração concorrente pode invalidar
+type PreImageRepository[T any] interface {
+	PlantRepository[T]
+	// PatchWithBefore - como Patch, retornando também o documento antes da alteração
+	PatchWithBefore(ctx context.Context, id primitive.ObjectID, patch Patch, version int64) (before, after *T, err error)
+	// DeleteWithBefore - como Delete, retornando o documento antes de ir para a lixeira
+	DeleteWithBefore(ctx context.Context, id primitive.ObjectID, version int64) (*T, error)
+	// RestoreWithBefore - como Restore, retornando também o documento como estava na lixeira
+	RestoreWithBefore(ctx context.Context, id primitive.ObjectID, version int64) (before, after *T, err error)
+}
+
+// ListOptions - filtro, ordenação, projeção e paginação da listagem
+type ListOptions struct {
+	Filter []Condition
+	Sort   []SortField
+	// Fields - caminhos BSON a retornar; vazio retorna o documento inteiro
+	Fields []string
+	Limit  int64
+	Offset int64
+	// After - âncora (ver SortKey): retorna os documentos seguintes a ela na ordenação
+	After []interface{}
+	// Before - âncora: retorna os documentos imediatamente anteriores a ela, na ordem normal
+	Before []interface{}
+}
+
+// anchor - âncora da paginação e se ela aponta para trás, conferindo seu tamanho
+func (o ListOptions) anchor(order []SortField) ([]interface{}, bool, error) {
+	key, backward := o.After, false
+	if o.Before != nil {
+		key, backward = o.Before, true
+	}
+	if key != nil && len(key) != len(order) {
+		return nil, false, fmt.Errorf("%w: a âncora da paginação não corresponde à ordenação", ErrValidation)
+	}
+	return key, backward, nil
+}
+
+// Patch - alteração parcial de um documento, com caminhos no formato do BSON ("a.b")
+type Patch struct {
+	Set   map[string]interface{}
+	Unset []string
+}
+
+// apply - aplica o Patch ao documento decodificado, incrementando a versão como patchUpdate
+func (p Patch) apply(m bson.M) {
+	for path, value := range p.Set {
+		setPath(m, path, value)
+	}
+	for _, path := range p.Unset {
+		unsetPath(m, path)
+	}
+	m["version"] = versionOf(m) + 1
+}
+
+// decodeDocument - converte o documento decodificado em T
+func decodeDocument[T any](m bson.M) (*T, error) {
+	raw, err := bson.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc T
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// NewPatch - monta o Patch que leva os campos informados (nomes do JSON) aos valores de doc:
+// campos presentes vão para Set e campos vazios para Unset
+//
+// Além dos campos de primeiro nível, aceita caminhos "a.b", que alteram somente o
+// subcampo; abaixo do primeiro nível, os nomes do JSON e do BSON são iguais.
+func NewPatch(doc interface{}, fields []string) (Patch, error) {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return Patch{}, err
+	}
+
+	var m bson.M
+	if err := bson.Unmarshal(raw, &m); err != nil {
+		return Patch{}, err
+	}
+
+	names := bsonNames(reflect.TypeOf(doc))
+	patch := Patch{Set: bson.M{}}
+	seen := map[string]bool{}
+
+	for _, field := range fields {
+		top, rest, nested := strings.Cut(field, ".")
+		name, ok := names[top]
+		if !ok {
+			return Patch{}, &ValidationError{Fields: []FieldError{{Field: field, Code: "unknown_field", Message: "campo desconhecido"}}}
+		}
+		if contains(ServerManaged, top) {
+			continue
+		}
+
+		path := name
+		value, ok := m[name]
+		if nested {
+			path += "." + rest
+			value = lookup(m, path)
+			ok = value != nil
+		}
+
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		if ok {
+			patch.Set[path] = value
+		} else {
+			patch.Unset = append(patch.Unset, path)
+		}
+	}
+
+	return patch, nil
+}
+
+// ReplacePatch - monta o Patch que substitui todos os campos do documento, removendo os vazios
+func ReplacePatch(doc interface{}) (Patch, error) {
+	names := bsonNames(reflect.TypeOf(doc))
+	fields := make([]string, 0, len(names))
+	for field := range names {
+		fields = append(fields, field)
+	}
+	return NewPatch(doc, fields)
+}
+
+// ServerManaged - campos (nomes do JSON) mantidos pelo repositório ou por endpoints próprios;
+// os patches os ignoram, e a API os rejeita nos corpos enviados pelos clientes
+var ServerManaged = []string{"id", "version", "images", "image_path", "deleted_at", "deleted_by", "status", "publish_at", "reviews", "created_by"}
+
+// bsonNames - mapeia o nome JSON de cada campo de primeiro nível para o nome no BSON
+func bsonNames(t reflect.Type) map[string]string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	names := map[string]string{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		if sf.Anonymous {
+			for k, v := range bsonNames(sf.Type) {
+				names[k] = v
+			}
+			continue
+		}
+
+		name, _, _ := strings.Cut(sf.Tag.Get("bson"), ",")
+		if name == "" {
+			name = strings.ToLower(sf.Name)
+		}
+		if name != "-" {
+			names[jsonName(sf)] = name
+		}
+	}
+	return names
+}
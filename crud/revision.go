@@ -0,0 +1,270 @@
+package crud
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+const restoreKey auditContextKey = requestIDKey + 1
+
+// WithRestore - contexto de uma alteração que restaura a revisão informada
+func WithRestore(ctx context.Context, number int64) context.Context {
+	return context.WithValue(ctx, restoreKey, number)
+}
+
+// restoredFrom - revisão restaurada pela alteração do contexto, ou 0
+func restoredFrom(ctx context.Context) int64 {
+	number, _ := ctx.Value(restoreKey).(int64)
+	return number
+}
+
+// Revision - cópia completa de um documento após uma alteração
+type Revision struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	Category   string             `bson:"category" json:"category"`
+	DocumentID primitive.ObjectID `bson:"document_id" json:"document_id"`
+	// Number - versão do documento registrada nesta revisão
+	Number    int64     `bson:"number" json:"number"`
+	Time      time.Time `bson:"time" json:"time"`
+	Actor     string    `bson:"actor" json:"actor"`
+	RequestID string    `bson:"request_id,omitempty" json:"request_id,omitempty"`
+	// Action - ChangeCreate, ChangeUpdate ou ChangeRestore
+	Action string `bson:"action" json:"action"`
+	// RestoredFrom - revisão restaurada por esta alteração, quando houver
+	RestoredFrom int64 `bson:"restored_from,omitempty" json:"restored_from,omitempty"`
+	// Snapshot - documento completo; omitido nas listagens
+	Snapshot *Plant `bson:"snapshot,omitempty" json:"snapshot,omitempty"`
+}
+
+// RevisionStore - revisões dos documentos de todas as categorias
+type RevisionStore interface {
+	Save(ctx context.Context, rev *Revision) error
+	// List - revisões do documento sem o Snapshot, das mais recentes às mais antigas
+	List(ctx context.Context, category string, id primitive.ObjectID) ([]Revision, error)
+	// Find - revisão completa; ErrNotFound quando não existir
+	Find(ctx context.Context, category string, id primitive.ObjectID, number int64) (*Revision, error)
+}
+
+// Limites da gravação das revisões, os mesmos do histórico de auditoria
+const (
+	// revisionTimeout - prazo de cada gravação, independente do cancelamento da requisição
+	revisionTimeout = auditTimeout
+	// maxRevisionPending - revisões guardadas para nova tentativa; as seguintes são descartadas
+	maxRevisionPending = maxAuditPending
+)
+
+// Revisioner - ChangeListener que guarda uma revisão a cada criação e atualização
+//
+// As revisões de documentos removidos são mantidas, junto com o histórico de auditoria.
+// Como no Auditor, a alteração já foi feita quando a revisão é gravada: a que falhar
+// fica pendente até Retry conseguir gravá-la, e Status mostra quantas aguardam.
+type Revisioner struct {
+	Store RevisionStore
+
+	mu       sync.Mutex
+	pending  []*Revision
+	failures int64
+	dropped  int64
+}
+
+// NewRevisioner - cria o listener que grava no armazenamento de revisões informado
+func NewRevisioner(s RevisionStore) *Revisioner {
+	return &Revisioner{Store: s}
+}
+
+// Changed - grava a revisão; na falha, a revisão fica pendente (ver Retry)
+func (rv *Revisioner) Changed(ctx context.Context, change Change) {
+	if change.Plant == nil {
+		return
+	}
+
+	rev := &Revision{
+		Category:     change.Category,
+		DocumentID:   change.ID,
+		Number:       change.Plant.Version,
+		Time:         time.Now().UTC(),
+		Actor:        Actor(ctx),
+		RequestID:    RequestID(ctx),
+		Action:       change.Action,
+		RestoredFrom: restoredFrom(ctx),
+		Snapshot:     change.Plant,
+	}
+
+	// a requisição pode terminar antes da gravação, então o prazo é próprio
+	ctx, cancel := context.WithTimeout(context.Background(), revisionTimeout)
+	defer cancel()
+
+	if err := rv.Store.Save(ctx, rev); err != nil {
+		rv.failed(rev, err)
+	}
+}
+
+// failed - guarda a revisão que não foi gravada para nova tentativa
+func (rv *Revisioner) failed(rev *Revision, err error) {
+	rv.mu.Lock()
+	defer rv.mu.Unlock()
+
+	rv.failures++
+
+	if len(rv.pending) >= maxRevisionPending {
+		rv.dropped++
+		log.Printf("ALERTA: revisão %d de %s/%s descartada, com %d pendentes: %v", rev.Number, rev.Category, rev.DocumentID.Hex(), len(rv.pending), err)
+		return
+	}
+
+	rv.pending = append(rv.pending, rev)
+	log.Printf("ALERTA: revisão %d de %s/%s não gravada, %d pendentes: %v", rev.Number, rev.Category, rev.DocumentID.Hex(), len(rv.pending), err)
+}
+
+// Retry - grava as revisões pendentes na ordem em que falharam, parando na primeira falha;
+// uma revisão que já existe (ErrConflict) foi gravada por uma tentativa anterior e é descartada
+func (rv *Revisioner) Retry(ctx context.Context) (int, error) {
+	rv.mu.Lock()
+	pending := rv.pending
+	rv.pending = nil
+	rv.mu.Unlock()
+
+	for i, rev := range pending {
+		if err := rv.Store.Save(ctx, rev); err != nil && !errors.Is(err, ErrConflict) {
+			rv.mu.Lock()
+			rv.failures++
+			rv.pending = append(append([]*Revision(nil), pending[i:]...), rv.pending...)
+			rv.mu.Unlock()
+			return i, err
+		}
+	}
+
+	return len(pending), nil
+}
+
+// Status - revisões pendentes e falhas da gravação
+func (rv *Revisioner) Status() AuditStatus {
+	rv.mu.Lock()
+	defer rv.mu.Unlock()
+
+	return AuditStatus{Pending: len(rv.pending), Failures: rv.failures, Dropped: rv.dropped}
+}
+
+// MongoRevisionStore - revisões em uma coleção do MongoDB
+type MongoRevisionStore struct {
+	Collection *mongo.Collection
+}
+
+// NewMongoRevisionStore - cria o armazenamento e o índice único por documento e número
+func NewMongoRevisionStore(ctx context.Context, coll *mongo.Collection) (*MongoRevisionStore, error) {
+	_, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "category", Value: 1}, {Key: "document_id", Value: 1}, {Key: "number", Value: -1}},
+		Options: options.Index().SetName("revision_number").SetUnique(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &MongoRevisionStore{Collection: coll}, nil
+}
+
+func (s *MongoRevisionStore) Save(ctx context.Context, rev *Revision) error {
+	rev.ID = primitive.NewObjectID()
+
+	_, err := s.Collection.InsertOne(ctx, rev)
+	return mongoError(err)
+}
+
+func (s *MongoRevisionStore) List(ctx context.Context, category string, id primitive.ObjectID) ([]Revision, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "number", Value: -1}}).
+		SetProjection(bson.M{"snapshot": 0})
+
+	cur, err := s.Collection.Find(ctx, bson.M{"category": category, "document_id": id}, opts)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	defer func(cur *mongo.Cursor, ctx context.Context) {
+		err := cur.Close(ctx)
+		if err != nil {
+			log.Println(err)
+		}
+	}(cur, ctx)
+
+	revs := []Revision{}
+	if err := cur.All(ctx, &revs); err != nil {
+		return nil, err
+	}
+	return revs, nil
+}
+
+func (s *MongoRevisionStore) Find(ctx context.Context, category string, id primitive.ObjectID, number int64) (*Revision, error) {
+	var rev Revision
+
+	err := s.Collection.FindOne(ctx, bson.M{"category": category, "document_id": id, "number": number}).Decode(&rev)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	return &rev, nil
+}
+
+// MemoryRevisionStore - revisões em memória, para desenvolvimento sem MongoDB
+type MemoryRevisionStore struct {
+	mu   sync.RWMutex
+	revs map[string][]Revision
+}
+
+// NewMemoryRevisionStore - cria o armazenamento vazio
+func NewMemoryRevisionStore() *MemoryRevisionStore {
+	return &MemoryRevisionStore{revs: map[string][]Revision{}}
+}
+
+// revisionKey - chave das revisões de um documento
+func revisionKey(category string, id primitive.ObjectID) string {
+	return category + "/" + id.Hex()
+}
+
+func (s *MemoryRevisionStore) Save(_ context.Context, rev *Revision) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := revisionKey(rev.Category, rev.DocumentID)
+	for _, existing := range s.revs[key] {
+		if existing.Number == rev.Number {
+			return ErrConflict
+		}
+	}
+
+	rev.ID = primitive.NewObjectID()
+	s.revs[key] = append(s.revs[key], *rev)
+	return nil
+}
+
+func (s *MemoryRevisionStore) List(_ context.Context, category string, id primitive.ObjectID) ([]Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	revs := make([]Revision, 0, len(s.revs[revisionKey(category, id)]))
+	for _, rev := range s.revs[revisionKey(category, id)] {
+		rev.Snapshot = nil
+		revs = append(revs, rev)
+	}
+
+	sort.Slice(revs, func(i, j int) bool { return revs[i].Number > revs[j].Number })
+	return revs, nil
+}
+
+func (s *MemoryRevisionStore) Find(_ context.Context, category string, id primitive.ObjectID, number int64) (*Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, rev := range s.revs[revisionKey(category, id)] {
+		if rev.Number == number {
+			return &rev, nil
+		}
+	}
+	return nil, ErrNotFound
+}
@@ -0,0 +1,123 @@
+package crud
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRevisioner(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryRevisionStore()
+	repo := NewNotifyingRepository[Fruit](NewMemoryRepository[Fruit](), "fruits", NewRevisioner(store))
+
+	doc := newFruit("Pitanga")
+	if err := repo.Create(ctx, doc); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Update(WithRestore(ctx, 1), doc.ID, newFruit("Pitanga-roxa"), AnyVersion); err != nil {
+		t.Fatal(err)
+	}
+
+	// a remoção não gera revisão, e as anteriores são mantidas
+	if err := repo.Delete(ctx, doc.ID, AnyVersion); err != nil {
+		t.Fatal(err)
+	}
+
+	revs, err := store.List(ctx, "fruits", doc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 2 || revs[0].Number != 2 || revs[1].Number != 1 {
+		t.Fatalf("revisões = %+v", revs)
+	}
+	if revs[0].Snapshot != nil {
+		t.Error("List não deveria devolver o Snapshot")
+	}
+	if revs[0].Action != ChangeUpdate || revs[0].RestoredFrom != 1 || revs[1].Action != ChangeCreate {
+		t.Errorf("revisões = %+v", revs)
+	}
+
+	rev, err := store.Find(ctx, "fruits", doc.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev.Snapshot == nil || rev.Snapshot.Name != "Pitanga" {
+		t.Errorf("Find = %+v", rev)
+	}
+
+	if _, err := store.Find(ctx, "fruits", doc.ID, 3); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Find de revisão inexistente = %v, esperado ErrNotFound", err)
+	}
+	if err := store.Save(ctx, &Revision{Category: "fruits", DocumentID: doc.ID, Number: 1}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Save de número repetido = %v, esperado ErrConflict", err)
+	}
+}
+
+// failingStore - RevisionStore que falha enquanto down for verdadeiro ou com o contexto cancelado
+type failingStore struct {
+	*MemoryRevisionStore
+	down bool
+}
+
+func (s *failingStore) Save(ctx context.Context, rev *Revision) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.down {
+		return errors.New("fora do ar")
+	}
+	return s.MemoryRevisionStore.Save(ctx, rev)
+}
+
+func TestRevisionerRetry(t *testing.T) {
+	store := &failingStore{MemoryRevisionStore: NewMemoryRevisionStore()}
+	revisioner := NewRevisioner(store)
+	repo := NewNotifyingRepository[Fruit](NewMemoryRepository[Fruit](), "fruits", revisioner)
+
+	// a requisição cancelada depois da alteração não impede a gravação da revisão
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	doc := newFruit("Pitanga")
+	if err := repo.Create(ctx, doc); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Find(context.Background(), "fruits", doc.ID, 1); err != nil {
+		t.Fatalf("revisão da requisição cancelada: %v", err)
+	}
+
+	store.down = true
+
+	for _, name := range []string{"Pitanga-roxa", "Pitanga-branca"} {
+		if _, err := repo.Update(ctx, doc.ID, newFruit(name), AnyVersion); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if status := revisioner.Status(); status.Pending != 2 || status.Failures != 2 {
+		t.Fatalf("Status = %+v", status)
+	}
+	if n, err := revisioner.Retry(context.Background()); n != 0 || err == nil {
+		t.Errorf("Retry com o armazenamento fora do ar = %d, %v", n, err)
+	}
+
+	store.down = false
+
+	// a revisão já gravada por outra tentativa é descartada sem interromper as demais
+	if err := store.Save(context.Background(), &Revision{Category: "fruits", DocumentID: doc.ID, Number: 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := revisioner.Retry(context.Background()); n != 2 || err != nil {
+		t.Fatalf("Retry = %d, %v", n, err)
+	}
+	if status := revisioner.Status(); status.Pending != 0 || status.Failures != 3 {
+		t.Errorf("Status depois do Retry = %+v", status)
+	}
+
+	rev, err := store.Find(context.Background(), "fruits", doc.ID, 3)
+	if err != nil || rev.Snapshot.Name != "Pitanga-branca" {
+		t.Errorf("Find = %+v, %v", rev, err)
+	}
+}
@@ -0,0 +1,163 @@
+package crud
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"log"
+	"sort"
+)
+
+// SearchResult - documento encontrado na busca textual e sua relevância
+type SearchResult[T any] struct {
+	Doc   T
+	Score float64
+}
+
+// searchWeights - campos da busca textual e seus pesos na relevância
+var searchWeights = map[string]int{
+	"name":        10,
+	"description": 3,
+	"extra_info":  1,
+}
+
+// textIndexName - nome do índice de texto das coleções de plantas
+const textIndexName = "plant_text"
+
+// stopwords - palavras ignoradas na busca, como faz o índice de texto em português do MongoDB
+var stopwords = map[string]bool{
+	"a": true, "o": true, "as": true, "os": true, "e": true, "de": true, "da": true, "do": true,
+	"das": true, "dos": true, "em": true, "no": true, "na": true, "um": true, "uma": true,
+	"com": true, "para": true, "por": true,
+}
+
+// EnsureIndexes - cria o índice de texto usado por Search, em português e sem diferenciar
+// acentos, os índices de nomes usados por FindByName e o índice da lixeira
+func (r *MongoRepository[T, PT]) EnsureIndexes(ctx context.Context) error {
+	fields := make([]string, 0, len(searchWeights))
+	for field := range searchWeights {
+		fields = append(fields, field)
+	}
+	// a ordem das chaves precisa ser estável para que o índice existente seja reconhecido
+	sort.Strings(fields)
+
+	keys := bson.D{}
+	weights := bson.D{}
+	for _, field := range fields {
+		keys = append(keys, bson.E{Key: field, Value: "text"})
+		weights = append(weights, bson.E{Key: field, Value: searchWeights[field]})
+	}
+
+	text := mongo.IndexModel{
+		Keys: keys,
+		Options: options.Index().
+			SetName(textIndexName).
+			SetWeights(weights).
+			SetDefaultLanguage("portuguese").
+			SetTextVersion(3),
+	}
+
+	_, err := r.Collection.Indexes().CreateMany(ctx, append([]mongo.IndexModel{text, trashIndex}, nameIndexes()...))
+	return mongoError(err)
+}
+
+// Search - busca textual nos campos de searchWeights, ordenada pela relevância
+func (r *MongoRepository[T, PT]) Search(ctx context.Context, text string, filter []Condition, limit int64) ([]SearchResult[T], error) {
+	score := bson.M{"score": bson.M{"$meta": "textScore"}}
+	findOptions := options.Find().SetProjection(score).SetSort(score).SetLimit(limit)
+
+	query := liveFilter(filter, false)
+	query["$text"] = bson.M{"$search": text}
+
+	cur, err := r.Collection.Find(ctx, query, findOptions)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	defer func(cur *mongo.Cursor, ctx context.Context) {
+		err := cur.Close(ctx)
+		if err != nil {
+			log.Println(err)
+		}
+	}(cur, ctx)
+
+	var results []SearchResult[T]
+	for cur.Next(ctx) {
+		var result SearchResult[T]
+		if err := cur.Decode(&result.Doc); err != nil {
+			return nil, err
+		}
+		result.Score, _ = cur.Current.Lookup("score").DoubleOK()
+		results = append(results, result)
+	}
+
+	if err := cur.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// Search - busca textual nos campos de searchWeights, sem diferenciar acentos e maiúsculas
+func (r *MemoryRepository[T, PT]) Search(ctx context.Context, text string, filter []Condition, limit int64) ([]SearchResult[T], error) {
+	terms := searchTerms(text)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	matched, err := r.match(filter, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []SearchResult[T]
+	for _, e := range matched {
+		score := 0.0
+		for field, weight := range searchWeights {
+			value, _ := lookup(e.m, field).(string)
+			for _, token := range Tokens(value) {
+				if terms[singular(token)] {
+					score += float64(weight)
+				}
+			}
+		}
+		if score == 0 {
+			continue
+		}
+
+		var result SearchResult[T]
+		if err := bson.Unmarshal(e.raw, &result.Doc); err != nil {
+			return nil, err
+		}
+		result.Score = score
+		results = append(results, result)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if limit > 0 && int64(len(results)) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// searchTerms - termos da consulta normalizados, sem stopwords e no singular
+func searchTerms(text string) map[string]bool {
+	terms := map[string]bool{}
+	for _, token := range Tokens(text) {
+		if !stopwords[token] {
+			terms[singular(token)] = true
+		}
+	}
+	return terms
+}
+
+// singular - forma aproximada no singular, para que "abóboras" encontre "abóbora"
+func singular(token string) string {
+	if len(token) > 3 && token[len(token)-1] == 's' {
+		return token[:len(token)-1]
+	}
+	return token
+}
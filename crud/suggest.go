@@ -0,0 +1,176 @@
+package crud
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Suggestion - nome de planta sugerido pelo autocompletar
+type Suggestion struct {
+	Category string `json:"category"`
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	// Distance - número de correções de digitação necessárias para o prefixo corresponder
+	Distance int `json:"distance"`
+}
+
+// nameEntry - nome indexado e suas formas normalizadas
+type nameEntry struct {
+	category string
+	id       primitive.ObjectID
+	name     string
+	// keys - o nome inteiro e cada sufixo que começa em uma palavra, sem acentos
+	keys []string
+}
+
+// NameIndex - índice em memória dos nomes das plantas para o autocompletar
+//
+// Implementa ChangeListener, sendo atualizado a cada alteração feita pelos repositórios.
+type NameIndex struct {
+	mu      sync.RWMutex
+	entries map[string]nameEntry
+}
+
+// NewNameIndex - cria um índice vazio
+func NewNameIndex() *NameIndex {
+	return &NameIndex{entries: map[string]nameEntry{}}
+}
+
+// Put - indexa ou atualiza o nome de um documento
+func (ix *NameIndex) Put(category string, id primitive.ObjectID, name string) {
+	key := category + "/" + id.Hex()
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	if strings.TrimSpace(name) == "" {
+		delete(ix.entries, key)
+		return
+	}
+
+	words := strings.Fields(Fold(name))
+	keys := make([]string, len(words))
+	for i := range words {
+		keys[i] = strings.Join(words[i:], " ")
+	}
+	ix.entries[key] = nameEntry{category: category, id: id, name: name, keys: keys}
+}
+
+// Remove - retira um documento do índice
+func (ix *NameIndex) Remove(category string, id primitive.ObjectID) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	delete(ix.entries, category+"/"+id.Hex())
+}
+
+// Changed - mantém o índice em dia com as alterações dos repositórios, somente com os documentos publicados
+func (ix *NameIndex) Changed(ctx context.Context, change Change) {
+	if change.Plant == nil || !change.Plant.Published() {
+		ix.Remove(change.Category, change.ID)
+		return
+	}
+	ix.Put(change.Category, change.ID, change.Plant.Name)
+}
+
+// Suggest - nomes que começam pelo prefixo, tolerando erros de digitação e acentos
+//
+// São aceitas até uma correção para prefixos de 4 a 6 letras e até duas a partir
+// de 7. Os resultados vêm ordenados pelo número de correções, depois pelo nome.
+func (ix *NameIndex) Suggest(prefix string, limit int) []Suggestion {
+	query := strings.Join(strings.Fields(Fold(prefix)), " ")
+	if query == "" {
+		return nil
+	}
+
+	maxTypos := 0
+	switch n := utf8.RuneCountInString(query); {
+	case n >= 7:
+		maxTypos = 2
+	case n >= 4:
+		maxTypos = 1
+	}
+
+	ix.mu.RLock()
+	var found []Suggestion
+	for _, e := range ix.entries {
+		best := -1
+		for _, key := range e.keys {
+			if d := prefixDistance(query, key, maxTypos); d >= 0 && (best < 0 || d < best) {
+				best = d
+			}
+		}
+		if best >= 0 {
+			found = append(found, Suggestion{Category: e.category, ID: e.id.Hex(), Name: e.name, Distance: best})
+		}
+	}
+	ix.mu.RUnlock()
+
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Distance != found[j].Distance {
+			return found[i].Distance < found[j].Distance
+		}
+		if found[i].Name != found[j].Name {
+			return Fold(found[i].Name) < Fold(found[j].Name)
+		}
+		return found[i].ID < found[j].ID
+	})
+	if limit > 0 && len(found) > limit {
+		found = found[:limit]
+	}
+	return found
+}
+
+// prefixDistance - menor distância de edição entre a consulta e algum prefixo do texto,
+// ou -1 quando passa de max
+func prefixDistance(query, text string, max int) int {
+	q, t := []rune(query), []rune(text)
+
+	// prev[j] - distância entre q[:i] e t[:j]
+	prev := make([]int, len(t)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(q); i++ {
+		cur := make([]int, len(t)+1)
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if q[i-1] == t[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			rowMin = minInt(rowMin, cur[j])
+		}
+		if rowMin > max {
+			return -1
+		}
+		prev = cur
+	}
+
+	best := prev[0]
+	for _, d := range prev {
+		best = minInt(best, d)
+	}
+	if best > max {
+		return -1
+	}
+	return best
+}
+
+// minInt - menor dos valores
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,144 @@
+package crud
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"strings"
+)
+
+// Taxonomy - identificação botânica da planta
+type Taxonomy struct {
+	Family string `bson:"family,omitempty" json:"family,omitempty" validate:"max=100"`
+	Genus  string `bson:"genus,omitempty" json:"genus,omitempty" validate:"max=100"`
+	// Species - epíteto específico, como "esculenta" em Manihot esculenta
+	Species  string `bson:"species,omitempty" json:"species,omitempty" validate:"max=100"`
+	Cultivar string `bson:"cultivar,omitempty" json:"cultivar,omitempty" validate:"max=100"`
+}
+
+// ScientificName - nome científico binomial, vazio quando gênero ou espécie faltam
+func (t *Taxonomy) ScientificName() string {
+	if t == nil || t.Genus == "" || t.Species == "" {
+		return ""
+	}
+	return strings.TrimSpace(t.Genus) + " " + strings.TrimSpace(t.Species)
+}
+
+// SpeciesKey - chave normalizada do nome científico, usada para agrupar duplicatas
+func (t *Taxonomy) SpeciesKey() string {
+	return strings.Join(strings.Fields(Fold(t.ScientificName())), " ")
+}
+
+// CommonName - nome popular da planta e a região onde é usado
+type CommonName struct {
+	Name   string `bson:"name" json:"name" validate:"required,max=100"`
+	Region string `bson:"region,omitempty" json:"region,omitempty" validate:"max=100"`
+}
+
+// MatchName - indica se o nome corresponde ao nome principal ou a um nome popular,
+// sem diferenciar acentos e maiúsculas, retornando o nome encontrado
+func (p *Plant) MatchName(name string) (string, bool) {
+	key := Fold(strings.TrimSpace(name))
+	if key == "" {
+		return "", false
+	}
+	if Fold(p.Name) == key {
+		return p.Name, true
+	}
+	for _, c := range p.CommonNames {
+		if Fold(c.Name) == key {
+			return c.Name, true
+		}
+	}
+	return "", false
+}
+
+// nameCollation - colação que compara nomes sem diferenciar acentos e maiúsculas
+var nameCollation = &options.Collation{Locale: "pt", Strength: 1}
+
+// nameIndexes - índices dos nomes usados por FindByName, com a mesma colação da consulta
+func nameIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{Keys: bson.D{{Key: "name", Value: 1}}, Options: options.Index().SetName("plant_name").SetCollation(nameCollation)},
+		{Keys: bson.D{{Key: "common_names.name", Value: 1}}, Options: options.Index().SetName("plant_common_names").SetCollation(nameCollation)},
+	}
+}
+
+// FindByName - documentos cujo nome principal ou popular é igual ao informado, sem diferenciar acentos e maiúsculas
+func (r *MongoRepository[T, PT]) FindByName(ctx context.Context, name string) ([]T, error) {
+	name = strings.TrimSpace(name)
+	filter := bson.M{"$or": bson.A{
+		bson.M{"name": name},
+		bson.M{"common_names.name": name},
+	}, "deleted_at": nil}
+
+	cur, err := r.Collection.Find(ctx, filter, options.Find().SetCollation(nameCollation).SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	var docs []T
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, mongoError(err)
+	}
+	return docs, nil
+}
+
+// FindByNames - documentos cujo nome principal é algum dos informados, em uma só consulta
+func (r *MongoRepository[T, PT]) FindByNames(ctx context.Context, names []string) ([]T, error) {
+	values := make(bson.A, len(names))
+	for i, name := range names {
+		values[i] = strings.TrimSpace(name)
+	}
+
+	filter := bson.M{"name": bson.M{"$in": values}, "deleted_at": nil}
+
+	cur, err := r.Collection.Find(ctx, filter, options.Find().SetCollation(nameCollation).SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, mongoError(err)
+	}
+
+	var docs []T
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, mongoError(err)
+	}
+	return docs, nil
+}
+
+// FindByName - documentos cujo nome principal ou popular é igual ao informado, sem diferenciar acentos e maiúsculas
+func (r *MemoryRepository[T, PT]) FindByName(ctx context.Context, name string) ([]T, error) {
+	docs, err := r.List(ctx, ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var found []T
+	for i := range docs {
+		if _, ok := PT(&docs[i]).PlantData().MatchName(name); ok {
+			found = append(found, docs[i])
+		}
+	}
+	return found, nil
+}
+
+// FindByNames - documentos cujo nome principal é algum dos informados, sem diferenciar acentos e maiúsculas
+func (r *MemoryRepository[T, PT]) FindByNames(ctx context.Context, names []string) ([]T, error) {
+	keys := map[string]bool{}
+	for _, name := range names {
+		keys[Fold(strings.TrimSpace(name))] = true
+	}
+
+	docs, err := r.List(ctx, ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var found []T
+	for i := range docs {
+		if keys[Fold(PT(&docs[i]).PlantData().Name)] {
+			found = append(found, docs[i])
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,30 @@
+package crud
+
+import (
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"unicode"
+)
+
+// foldAccents - remove os acentos, decompondo os caracteres e descartando as marcas
+func foldAccents(s string) string {
+	folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), s)
+	if err != nil {
+		return s
+	}
+	return folded
+}
+
+// Fold - texto em minúsculas e sem acentos, para comparações como "maracuja" = "Maracujá"
+func Fold(s string) string {
+	return strings.ToLower(foldAccents(s))
+}
+
+// Tokens - palavras do texto normalizadas com Fold
+func Tokens(s string) []string {
+	return strings.FieldsFunc(Fold(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
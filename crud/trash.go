@@ -0,0 +1,146 @@
+package crud
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// System - autor das alterações feitas pela própria API, como a limpeza da lixeira
+const System = "system"
+
+// trashIndex - índice dos documentos da lixeira, usado por Trash e Purge
+var trashIndex = mongo.IndexModel{
+	Keys:    bson.D{{Key: "deleted_at", Value: 1}},
+	Options: options.Index().SetName("plant_deleted_at").SetSparse(true),
+}
+
+// Trash - documentos da lixeira que atendem ao filtro
+func (r *MongoRepository[T, PT]) Trash(ctx context.Context, opts ListOptions) ([]T, error) {
+	return r.find(ctx, true, opts)
+}
+
+// Restore - devolve o documento da lixeira e retorna o documento restaurado
+func (r *MongoRepository[T, PT]) Restore(ctx context.Context, id primitive.ObjectID, version int64) (*T, error) {
+	_, doc, err := r.RestoreWithBefore(ctx, id, version)
+	return doc, err
+}
+
+// RestoreWithBefore - devolve o documento da lixeira e o retorna como estava na lixeira e restaurado
+func (r *MongoRepository[T, PT]) RestoreWithBefore(ctx context.Context, id primitive.ObjectID, version int64) (*T, *T, error) {
+	filter := versionFilter(id, version)
+	filter["deleted_at"] = bson.M{"$ne": nil}
+
+	update := bson.M{
+		"$unset": bson.M{"deleted_at": "", "deleted_by": ""},
+		"$inc":   bson.M{"version": 1},
+	}
+
+	m, err := r.findAndUpdate(ctx, filter, update)
+	if err != nil {
+		return nil, nil, r.missing(ctx, bson.M{"_id": id, "deleted_at": bson.M{"$ne": nil}}, err)
+	}
+
+	before, err := decodeDocument[T](m)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	restore(m)
+
+	after, err := decodeDocument[T](m)
+	if err != nil {
+		return nil, nil, err
+	}
+	return before, after, nil
+}
+
+// Purge - remove definitivamente os documentos que estão na lixeira desde antes do momento informado
+func (r *MongoRepository[T, PT]) Purge(ctx context.Context, before time.Time) ([]T, error) {
+	expired := bson.M{"deleted_at": bson.M{"$lt": before}}
+
+	var docs []T
+	cur, err := r.Collection.Find(ctx, expired)
+	if err != nil {
+		return nil, mongoError(err)
+	}
+	if err := cur.All(ctx, &docs); err != nil {
+		return nil, mongoError(err)
+	}
+
+	// cada remoção repete o filtro, para não apagar um documento restaurado no meio do caminho
+	var purged []T
+	for _, doc := range docs {
+		res, err := r.Collection.DeleteOne(ctx, bson.M{"_id": PT(&doc).PlantData().ID, "deleted_at": bson.M{"$lt": before}})
+		if err != nil {
+			return purged, mongoError(err)
+		}
+		if res.DeletedCount > 0 {
+			purged = append(purged, doc)
+		}
+	}
+
+	return purged, nil
+}
+
+// restore - retira do documento decodificado as marcas da lixeira, como o $unset de Restore
+func restore(m bson.M) {
+	delete(m, "deleted_at")
+	delete(m, "deleted_by")
+	m["version"] = versionOf(m) + 1
+}
+
+// Trash - documentos da lixeira que atendem ao filtro
+func (r *MemoryRepository[T, PT]) Trash(ctx context.Context, opts ListOptions) ([]T, error) {
+	return r.find(true, opts)
+}
+
+// Restore - devolve o documento da lixeira e retorna o documento restaurado
+func (r *MemoryRepository[T, PT]) Restore(ctx context.Context, id primitive.ObjectID, version int64) (*T, error) {
+	_, doc, err := r.RestoreWithBefore(ctx, id, version)
+	return doc, err
+}
+
+// RestoreWithBefore - devolve o documento da lixeira e o retorna como estava na lixeira e restaurado
+func (r *MemoryRepository[T, PT]) RestoreWithBefore(ctx context.Context, id primitive.ObjectID, version int64) (*T, *T, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, err := r.stored(id, version, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	before, err := decodeDocument[T](m)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	restore(m)
+
+	return r.store(id, m, before)
+}
+
+// Purge - remove definitivamente os documentos que estão na lixeira desde antes do momento informado
+func (r *MemoryRepository[T, PT]) Purge(ctx context.Context, before time.Time) ([]T, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged []T
+	for id, raw := range r.docs {
+		var doc T
+		if err := bson.Unmarshal(raw, &doc); err != nil {
+			return purged, err
+		}
+
+		if deleted := PT(&doc).PlantData().DeletedAt; deleted != nil && deleted.Before(before) {
+			delete(r.docs, id)
+			purged = append(purged, doc)
+		}
+	}
+
+	return purged, nil
+}
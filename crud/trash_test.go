@@ -0,0 +1,80 @@
+package crud
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryRepositoryTrash(t *testing.T) {
+	ctx := WithActor(context.Background(), "ana")
+	repo := NewMemoryRepository[Fruit]()
+
+	keep, gone := newFruit("Acerola"), newFruit("Pitanga")
+	for _, doc := range []*Fruit{keep, gone} {
+		if err := repo.Create(ctx, doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := repo.Delete(ctx, gone.ID, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// o documento da lixeira some das leituras e listagens comuns
+	if _, err := repo.Read(ctx, gone.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Read de documento na lixeira = %v, esperado ErrNotFound", err)
+	}
+	if docs, _ := repo.List(ctx, ListOptions{}); !equalStrings(fruitNames(docs), []string{"Acerola"}) {
+		t.Errorf("List = %v", fruitNames(docs))
+	}
+	if n, _ := repo.Count(ctx, nil); n != 1 {
+		t.Errorf("Count = %d, esperado 1", n)
+	}
+
+	trash, err := repo.Trash(ctx, ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash) != 1 || trash[0].Name != "Pitanga" || trash[0].DeletedAt == nil || trash[0].DeletedBy != "ana" || trash[0].Version != 2 {
+		t.Fatalf("Trash = %+v", trash)
+	}
+
+	if _, err := repo.Restore(ctx, keep.ID, AnyVersion); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Restore fora da lixeira = %v, esperado ErrNotFound", err)
+	}
+	if _, err := repo.Restore(ctx, gone.ID, 1); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("Restore com versão antiga = %v, esperado ErrVersionMismatch", err)
+	}
+
+	restored, err := repo.Restore(ctx, gone.ID, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.DeletedAt != nil || restored.DeletedBy != "" || restored.Version != 3 {
+		t.Errorf("Restore = %+v", restored)
+	}
+
+	// Purge só apaga o que foi para a lixeira antes do limite
+	if err := repo.Delete(ctx, gone.ID, AnyVersion); err != nil {
+		t.Fatal(err)
+	}
+	if purged, err := repo.Purge(ctx, time.Now().Add(-time.Hour)); err != nil || len(purged) != 0 {
+		t.Errorf("Purge antes do prazo = %+v, %v", purged, err)
+	}
+
+	purged, err := repo.Purge(ctx, time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(purged) != 1 || purged[0].ID != gone.ID {
+		t.Errorf("Purge = %+v", purged)
+	}
+	if trash, _ := repo.Trash(ctx, ListOptions{}); len(trash) != 0 {
+		t.Errorf("Trash depois do Purge = %+v", trash)
+	}
+	if _, err := repo.Restore(ctx, gone.ID, AnyVersion); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Restore depois do Purge = %v, esperado ErrNotFound", err)
+	}
+}
@@ -0,0 +1,224 @@
+package crud
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validate - verifica as regras declaradas na tag `validate` dos campos do documento
+//
+// Regras suportadas: required, min=N e max=N (tamanho em caracteres para textos,
+// valor para números), oneof=a b c, keys=a b (chaves permitidas em mapas), url, email,
+// gtefield=Campo (maior ou igual a outro campo da mesma struct), unique (itens de
+// lista sem repetição) e dive (as regras seguintes valem para cada item da lista).
+// Structs aninhadas, inclusive como itens de listas e valores de mapas, são
+// verificadas quando presentes. Todas as violações são retornadas de uma vez em um *ValidationError.
+func Validate(doc interface{}) error {
+	var fields []FieldError
+	validateStruct(reflect.Indirect(reflect.ValueOf(doc)), "", &fields)
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// validateStruct - percorre os campos da struct, incluindo as embutidas, acumulando violações
+func validateStruct(v reflect.Value, prefix string, fields *[]FieldError) {
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		fv := v.Field(i)
+		if sf.Anonymous {
+			validateStruct(reflect.Indirect(fv), prefix, fields)
+			continue
+		}
+
+		name := prefix + jsonName(sf)
+		if nested := reflect.Indirect(fv); nested.Kind() == reflect.Struct {
+			validateStruct(nested, name+".", fields)
+		}
+		if fv.Kind() == reflect.Slice {
+			for k := 0; k < fv.Len(); k++ {
+				if item := reflect.Indirect(fv.Index(k)); item.Kind() == reflect.Struct {
+					validateStruct(item, fmt.Sprintf("%s[%d].", name, k), fields)
+				}
+			}
+		}
+		if fv.Kind() == reflect.Map && fv.Type().Key().Kind() == reflect.String {
+			iter := fv.MapRange()
+			for iter.Next() {
+				validateStruct(reflect.Indirect(iter.Value()), name+"."+iter.Key().String()+".", fields)
+			}
+		}
+
+		tag := sf.Tag.Get("validate")
+		if tag == "" {
+			continue
+		}
+
+		rules := strings.Split(tag, ",")
+		for j, rule := range rules {
+			if rule == "dive" {
+				if fv.Kind() == reflect.Slice {
+					for k := 0; k < fv.Len(); k++ {
+						checkRules(fmt.Sprintf("%s[%d]", name, k), rules[j+1:], fv.Index(k), v, fields)
+					}
+				}
+				break
+			}
+			checkRules(name, []string{rule}, fv, v, fields)
+		}
+	}
+}
+
+// checkRules - aplica as regras ao valor do campo, acumulando violações
+func checkRules(name string, rules []string, v, parent reflect.Value, fields *[]FieldError) {
+	for _, rule := range rules {
+		if fe, ok := checkRule(name, rule, v, parent); !ok {
+			*fields = append(*fields, fe)
+		}
+	}
+}
+
+// checkRule - aplica uma regra ao valor do campo
+func checkRule(name, rule string, v, parent reflect.Value) (FieldError, bool) {
+	key, arg, _ := strings.Cut(rule, "=")
+
+	switch key {
+	case "required":
+		if v.IsZero() || (v.Kind() == reflect.String && strings.TrimSpace(v.String()) == "") {
+			return FieldError{Field: name, Code: "required", Message: "campo obrigatório"}, false
+		}
+	case "min", "max":
+		limit, _ := strconv.ParseFloat(arg, 64)
+		if v.Kind() == reflect.String {
+			n := float64(utf8.RuneCountInString(v.String()))
+			if key == "max" && n > limit {
+				return FieldError{Field: name, Code: "max_length", Message: fmt.Sprintf("deve ter no máximo %s caracteres", arg)}, false
+			}
+			if key == "min" && n < limit {
+				return FieldError{Field: name, Code: "min_length", Message: fmt.Sprintf("deve ter no mínimo %s caracteres", arg)}, false
+			}
+			break
+		}
+		n, ok := number(v)
+		if !ok {
+			break
+		}
+		if key == "max" && n > limit {
+			return FieldError{Field: name, Code: "max", Message: "deve ser no máximo " + arg}, false
+		}
+		if key == "min" && n < limit {
+			return FieldError{Field: name, Code: "min", Message: "deve ser no mínimo " + arg}, false
+		}
+	case "gtefield":
+		sf, ok := parent.Type().FieldByName(arg)
+		if !ok {
+			break
+		}
+		n, ok1 := number(v)
+		other, ok2 := number(parent.FieldByIndex(sf.Index))
+		if ok1 && ok2 && n < other {
+			return FieldError{Field: name, Code: "gte_field", Message: "deve ser maior ou igual a " + jsonName(sf)}, false
+		}
+	case "keys":
+		if v.Kind() != reflect.Map {
+			break
+		}
+		allowed := strings.Fields(arg)
+		iter := v.MapRange()
+		for iter.Next() {
+			if !contains(allowed, iter.Key().String()) {
+				return FieldError{Field: name + "." + iter.Key().String(), Code: "one_of", Message: "chave não permitida; use: " + strings.Join(allowed, ", ")}, false
+			}
+		}
+	case "unique":
+		if v.Kind() != reflect.Slice {
+			break
+		}
+		seen := map[interface{}]bool{}
+		for i := 0; i < v.Len(); i++ {
+			item := v.Index(i).Interface()
+			if seen[item] {
+				return FieldError{Field: name, Code: "unique", Message: "não pode conter itens repetidos"}, false
+			}
+			seen[item] = true
+		}
+	case "oneof":
+		if v.Kind() != reflect.String || v.String() == "" {
+			break
+		}
+		allowed := strings.Fields(arg)
+		for _, a := range allowed {
+			if v.String() == a {
+				return FieldError{}, true
+			}
+		}
+		return FieldError{Field: name, Code: "one_of", Message: "deve ser um dos valores: " + strings.Join(allowed, ", ")}, false
+	case "url":
+		if v.Kind() != reflect.String || v.String() == "" {
+			break
+		}
+		u, err := url.Parse(v.String())
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return FieldError{Field: name, Code: "url", Message: "deve ser uma URL http(s) válida"}, false
+		}
+	case "email":
+		if v.Kind() != reflect.String || v.String() == "" {
+			break
+		}
+		addr, err := mail.ParseAddress(v.String())
+		if err != nil || addr.Address != v.String() {
+			return FieldError{Field: name, Code: "email", Message: "deve ser um e-mail válido"}, false
+		}
+	}
+
+	return FieldError{}, true
+}
+
+// contains - indica se o valor está na lista
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// number - valor numérico do campo, quando for inteiro ou de ponto flutuante
+func number(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+// jsonName - nome do campo no JSON, usado para identificar a violação
+func jsonName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return sf.Name
+	}
+	return name
+}
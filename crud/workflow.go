@@ -0,0 +1,107 @@
+package crud
+
+import (
+	"time"
+)
+
+// Situações editoriais (Plant.Status); StatusScheduled é o documento aprovado que aguarda a publicação em PublishAt
+const (
+	StatusDraft     = "draft"
+	StatusInReview  = "in_review"
+	StatusScheduled = "scheduled"
+	StatusPublished = "published"
+	StatusArchived  = "archived"
+)
+
+// Decisões da revisão editorial (Review.Decision)
+const (
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+)
+
+// Statuses - todas as situações editoriais
+var Statuses = []string{StatusDraft, StatusInReview, StatusScheduled, StatusPublished, StatusArchived}
+
+// Transitions - situações que podem suceder cada situação pelo endpoint de status;
+// a passagem de StatusInReview para StatusScheduled ou StatusPublished só acontece pela aprovação
+var Transitions = map[string][]string{
+	StatusDraft:     {StatusInReview, StatusArchived},
+	StatusInReview:  {StatusDraft},
+	StatusScheduled: {StatusInReview, StatusDraft},
+	StatusPublished: {StatusDraft, StatusArchived},
+	StatusArchived:  {StatusDraft},
+}
+
+// CanTransition - indica se o documento pode passar da situação from para to
+func CanTransition(from, to string) bool {
+	return contains(Transitions[from], to)
+}
+
+// Review - parecer do revisor sobre um documento em revisão
+type Review struct {
+	Decision string    `bson:"decision" json:"decision"`
+	Comment  string    `bson:"comment,omitempty" json:"comment,omitempty" validate:"max=2000"`
+	Reviewer string    `bson:"reviewer" json:"reviewer"`
+	At       time.Time `bson:"at" json:"at"`
+}
+
+// PublishedValues - valores de status visíveis ao público; documentos anteriores ao fluxo editorial não têm o campo
+var PublishedValues = []interface{}{StatusPublished, nil}
+
+// EditorialStatus - situação do documento, considerando publicados os anteriores ao fluxo editorial
+func (p *Plant) EditorialStatus() string {
+	if p.Status == "" {
+		return StatusPublished
+	}
+	return p.Status
+}
+
+// Published - indica se o documento é visível ao público
+func (p *Plant) Published() bool {
+	return p.EditorialStatus() == StatusPublished
+}
+
+// StatusPatch - Patch que leva o documento à situação informada
+//
+// A data de publicação agendada só permanece enquanto o documento aprovado aguarda
+// em StatusScheduled; na publicação ela registra o momento em que o documento ficou visível.
+func StatusPatch(status string, publishAt *time.Time) Patch {
+	patch := Patch{Set: map[string]interface{}{"status": status}}
+
+	if publishAt != nil {
+		patch.Set["publish_at"] = publishAt.UTC()
+	} else {
+		patch.Unset = append(patch.Unset, "publish_at")
+	}
+
+	return patch
+}
+
+// ReviewPatch - Patch que registra o parecer junto à nova situação do documento
+func ReviewPatch(reviews []Review, review Review, status string, publishAt *time.Time) Patch {
+	patch := StatusPatch(status, publishAt)
+	patch.Set["reviews"] = append(append([]Review(nil), reviews...), review)
+	return patch
+}
+
+// ContentPatch - Patch de uma alteração de conteúdo do documento atual
+//
+// O documento agendado volta para revisão e perde o agendamento, para que a
+// publicação não leve alterações feitas depois da aprovação.
+func ContentPatch(patch Patch, current *Plant) Patch {
+	if current.EditorialStatus() != StatusScheduled {
+		return patch
+	}
+
+	reset := StatusPatch(StatusInReview, nil)
+
+	merged := Patch{Set: map[string]interface{}{}, Unset: append(append([]string(nil), patch.Unset...), reset.Unset...)}
+	for field, value := range patch.Set {
+		merged.Set[field] = value
+	}
+	for field, value := range reset.Set {
+		merged.Set[field] = value
+	}
+
+	return merged
+}
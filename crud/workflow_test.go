@@ -0,0 +1,93 @@
+package crud
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{StatusDraft, StatusInReview, true},
+		{StatusDraft, StatusPublished, false},
+		{StatusInReview, StatusDraft, true},
+		// a publicação de um documento em revisão só acontece pela aprovação
+		{StatusInReview, StatusPublished, false},
+		{StatusPublished, StatusArchived, true},
+		{StatusArchived, StatusPublished, false},
+		{StatusScheduled, StatusInReview, true},
+		{StatusScheduled, StatusPublished, false},
+		{"desconhecida", StatusDraft, false},
+	}
+
+	for _, tt := range tests {
+		if got := CanTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransition(%s, %s) = %v, esperado %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestEditorialStatus(t *testing.T) {
+	// documentos anteriores ao fluxo editorial não têm status e continuam públicos
+	if p := (&Plant{}); p.EditorialStatus() != StatusPublished || !p.Published() {
+		t.Errorf("documento sem status = %q", p.EditorialStatus())
+	}
+	if p := (&Plant{Status: StatusDraft}); p.Published() {
+		t.Error("rascunho não deveria ser público")
+	}
+}
+
+func TestReviewPatch(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryRepository[Fruit]()
+
+	doc := &Fruit{Plant: Plant{Name: "Pitanga", Status: StatusInReview}}
+	if err := repo.Create(ctx, doc); err != nil {
+		t.Fatal(err)
+	}
+
+	at := time.Now().Add(time.Hour).UTC().Truncate(time.Millisecond)
+	first := Review{Decision: ReviewApproved, Reviewer: "rui", At: at}
+
+	updated, err := repo.Patch(ctx, doc.ID, ReviewPatch(nil, first, StatusScheduled, &at), AnyVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.PublishAt == nil || !updated.PublishAt.Equal(at) || len(updated.Reviews) != 1 {
+		t.Fatalf("aprovação agendada = %+v", updated)
+	}
+
+	// o parecer novo se soma aos anteriores e a publicação sem data remove o agendamento
+	second := Review{Decision: ReviewApproved, Reviewer: "rui", At: at}
+	updated, err = repo.Patch(ctx, doc.ID, ReviewPatch(updated.Reviews, second, StatusPublished, nil), AnyVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status != StatusPublished || updated.PublishAt != nil || len(updated.Reviews) != 2 {
+		t.Errorf("publicação = %+v", updated)
+	}
+}
+
+func TestContentPatch(t *testing.T) {
+	patch := Patch{Set: map[string]interface{}{"description": "doce"}, Unset: []string{"planting"}}
+
+	if got := ContentPatch(patch, &Plant{Status: StatusDraft}); len(got.Set) != 1 || len(got.Unset) != 1 {
+		t.Errorf("ContentPatch do rascunho = %+v, esperado o Patch original", got)
+	}
+
+	// o documento agendado volta para revisão sem o agendamento
+	at := time.Now()
+	got := ContentPatch(patch, &Plant{Status: StatusScheduled, PublishAt: &at})
+	if got.Set["status"] != StatusInReview || got.Set["description"] != "doce" {
+		t.Errorf("Set = %v", got.Set)
+	}
+	if !equalStrings(got.Unset, []string{"planting", "publish_at"}) {
+		t.Errorf("Unset = %v", got.Unset)
+	}
+	if len(patch.Unset) != 1 {
+		t.Errorf("o Patch original foi alterado: %+v", patch)
+	}
+}
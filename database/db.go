@@ -3,8 +3,6 @@ package database
 import (
 	"context"
 	"fmt"
-	"github.com/joho/godotenv"
-	_ "github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"log"
@@ -16,13 +14,6 @@ type Database struct {
 }
 
 func Connect() (*Database, error) {
-	// carrega as variáveis de ambiente do arquivo .env
-	err := godotenv.Load()
-
-	if err != nil {
-		log.Fatalf("Erro ao carregar as variáveis de ambiente: %v", err)
-	}
-
 	ctx := context.TODO()
 
 	clientOptions := options.Client().ApplyURI(os.Getenv("MONGO_URI"))
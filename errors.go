@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"rastros-da-mata/crud"
+)
+
+// Códigos estáveis dos erros da API, para que os clientes possam localizar as mensagens
+const (
+	codeInvalidID         = "invalid_id"
+	codeInvalidBody       = "invalid_body"
+	codeInvalidParameter  = "invalid_parameter"
+	codeInvalidPatch      = "invalid_patch"
+	codeUnsupportedMedia  = "unsupported_media_type"
+	codePayloadTooLarge   = "payload_too_large"
+	codeReadOnly          = "read_only"
+	codeUnauthorized      = "unauthorized"
+	codeForbidden         = "forbidden"
+	codeNotFound          = "not_found"
+	codeMethodNotAllowed  = "method_not_allowed"
+	codeConflict          = "conflict"
+	codeInvalidTransition = "invalid_transition"
+	codePrecondition      = "precondition_failed"
+	codeValidationFailed  = "validation_failed"
+	codeInternal          = "internal_error"
+	codeUnavailable       = "service_unavailable"
+)
+
+// problemTitles - títulos fixos de cada código de erro
+var problemTitles = map[string]string{
+	codeInvalidID:         "ID inválido",
+	codeInvalidBody:       "Corpo da requisição inválido",
+	codeInvalidParameter:  "Parâmetro inválido",
+	codeInvalidPatch:      "Patch inválido",
+	codeUnsupportedMedia:  "Tipo de mídia não suportado",
+	codePayloadTooLarge:   "Conteúdo muito grande",
+	codeUnauthorized:      "Não autenticado",
+	codeForbidden:         "Acesso negado",
+	codeNotFound:          "Recurso não encontrado",
+	codeMethodNotAllowed:  "Método não permitido",
+	codeConflict:          "Conflito",
+	codeInvalidTransition: "Transição de status inválida",
+	codePrecondition:      "Versão desatualizada",
+	codeValidationFailed:  "Falha de validação",
+	codeInternal:          "Erro interno",
+	codeUnavailable:       "Serviço indisponível",
+}
+
+// Problem - corpo de erro no formato RFC 7807 (application/problem+json)
+type Problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Code     string            `json:"code"`
+	Errors   []crud.FieldError `json:"errors,omitempty"`
+}
+
+// newProblem - cria um Problem para o código e status informados
+func newProblem(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:   "urn:rastros-da-mata:problem:" + code,
+		Title:  problemTitles[code],
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// writeProblem - escreve o Problem como application/problem+json
+func writeProblem(w http.ResponseWriter, r *http.Request, p *Problem) {
+	if p.Instance == "" {
+		p.Instance = r.URL.Path
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		log.Println(err)
+	}
+}
+
+// errorProblem - traduz os erros do pacote crud para o Problem correspondente
+//
+// Erros desconhecidos viram 500 sem detalhes, para não expor mensagens do driver.
+func errorProblem(err error) *Problem {
+	var verr *crud.ValidationError
+
+	switch {
+	case errors.As(err, &verr):
+		p := newProblem(http.StatusUnprocessableEntity, codeValidationFailed, crud.ErrValidation.Error())
+		p.Errors = verr.Fields
+		return p
+	case errors.Is(err, crud.ErrValidation):
+		return newProblem(http.StatusUnprocessableEntity, codeValidationFailed, crud.ErrValidation.Error())
+	case errors.Is(err, crud.ErrNotFound):
+		return newProblem(http.StatusNotFound, codeNotFound, crud.ErrNotFound.Error())
+	case errors.Is(err, crud.ErrVersionMismatch):
+		return newProblem(http.StatusPreconditionFailed, codePrecondition, "O documento foi alterado; leia a versão atual e tente novamente")
+	case errors.Is(err, crud.ErrConflict):
+		return newProblem(http.StatusConflict, codeConflict, crud.ErrConflict.Error())
+	case errors.Is(err, errPasswordBusy):
+		return newProblem(http.StatusServiceUnavailable, codeUnavailable, "Muitas senhas sendo processadas ao mesmo tempo; tente novamente em instantes")
+	default:
+		log.Println(err)
+		return newProblem(http.StatusInternalServerError, codeInternal, "")
+	}
+}
+
+// writeError - responde com o Problem correspondente ao erro
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	writeProblem(w, r, errorProblem(err))
+}
+
+// writeInvalidID - responde que o ID da rota não é um ObjectID válido
+func writeInvalidID(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, newProblem(http.StatusBadRequest, codeInvalidID, "O ID deve ser um ObjectID hexadecimal de 24 caracteres"))
+}
+
+// writeInvalidBody - responde que o corpo da requisição não pôde ser decodificado
+func writeInvalidBody(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, newProblem(http.StatusBadRequest, codeInvalidBody, "O corpo da requisição não é um JSON válido para este recurso"))
+}
+
+// writeBodyError - responde ao erro da leitura do corpo: 413 quando ele passou do limite
+// do http.MaxBytesReader e, nos demais casos, o mesmo que writeInvalidBody
+func writeBodyError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+
+	if errors.As(err, &tooLarge) {
+		writeProblem(w, r, newProblem(http.StatusRequestEntityTooLarge, codePayloadTooLarge, fmt.Sprintf("O corpo da requisição deve ter no máximo %d MB", tooLarge.Limit>>20)))
+		return
+	}
+
+	writeInvalidBody(w, r)
+}
+
+// writeInvalidParameter - responde que um parâmetro de consulta é inválido
+func writeInvalidParameter(w http.ResponseWriter, r *http.Request, name, detail string) {
+	p := newProblem(http.StatusBadRequest, codeInvalidParameter, detail)
+	p.Errors = []crud.FieldError{{Field: name, Code: codeInvalidParameter, Message: detail}}
+	writeProblem(w, r, p)
+}
+
+// notFoundHandler - responde às rotas inexistentes no formato padrão de erro
+func notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, newProblem(http.StatusNotFound, codeNotFound, "Rota não encontrada"))
+}
+
+// methodNotAllowedHandler - responde aos métodos não suportados no formato padrão de erro
+func methodNotAllowedHandler(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, newProblem(http.StatusMethodNotAllowed, codeMethodNotAllowed, "Método não suportado por esta rota"))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"rastros-da-mata/crud"
+	"strconv"
+	"strings"
+)
+
+// etag - ETag forte que identifica a versão de um documento e a variação do corpo
+//
+// Sem variação, identifica o corpo completo devolvido nas alterações. As leituras
+// informam o idioma e, na visão pública, "public" (ver publicView), como em
+// "7.en.public", para que caches e If-None-Match não troquem um corpo por outro.
+func etag(version int64, variant ...string) string {
+	return `"` + strings.Join(append([]string{strconv.FormatInt(version, 10)}, variant...), ".") + `"`
+}
+
+// etagVersion - versão do documento na ETag forte, com ou sem variação
+func etagVersion(tag string) (int64, bool) {
+	if !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) || len(tag) < 2 {
+		return 0, false
+	}
+
+	version, _, _ := strings.Cut(tag[1:len(tag)-1], ".")
+	v, err := strconv.ParseInt(version, 10, 64)
+	return v, err == nil
+}
+
+// parseETags - separa uma lista de entity tags de If-Match/If-None-Match
+func parseETags(header string) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// ifMatchVersion - versão exigida pelo cabeçalho If-Match
+//
+// Sem o cabeçalho, ou com "*", retorna crud.AnyVersion. A comparação é forte
+// (RFC 9110), então ETags fracas nunca correspondem; a variação do corpo é
+// ignorada, pois a pré-condição é sobre a versão gravada. Com várias ETags, a versão
+// atual é consultada para escolher a que corresponde; ok é false quando nenhuma
+// corresponde.
+func ifMatchVersion(r *http.Request, current func() (int64, error)) (version int64, ok bool, err error) {
+	tags := parseETags(r.Header.Get("If-Match"))
+
+	if len(tags) == 0 {
+		return crud.AnyVersion, true, nil
+	}
+
+	var versions []int64
+	for _, tag := range tags {
+		if tag == "*" {
+			return crud.AnyVersion, true, nil
+		}
+		if v, ok := etagVersion(tag); ok {
+			versions = append(versions, v)
+		}
+	}
+
+	switch len(versions) {
+	case 0:
+		return 0, false, nil
+	case 1:
+		return versions[0], true, nil
+	}
+
+	v, err := current()
+	if err != nil {
+		return 0, false, err
+	}
+	for _, candidate := range versions {
+		if candidate == v {
+			return v, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// notModified - indica se If-None-Match corresponde à ETag atual (comparação fraca)
+func notModified(r *http.Request, current string) bool {
+	for _, tag := range parseETags(r.Header.Get("If-None-Match")) {
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == current {
+			return true
+		}
+	}
+	return false
+}
+
+// writePreconditionFailed - responde que a versão informada em If-Match não é a atual
+func writePreconditionFailed(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, crud.ErrVersionMismatch)
+}
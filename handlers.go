@@ -1,527 +1,643 @@
 package main
 
 import (
-	"context"
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"io"
+	"log"
+	"mime"
 	"net/http"
+	"rastros-da-mata/auth"
 	"rastros-da-mata/crud"
-	"strconv"
+	"rastros-da-mata/database"
+	"rastros-da-mata/storage"
+	"reflect"
+	"strings"
+	"time"
 )
 
+// maxBodySize - tamanho máximo aceito para o corpo das requisições JSON
+const maxBodySize = 1 << 20
+
 type App struct {
-	DB     map[string]*mongo.Collection
+	// DB - banco de dados das coleções; quando nil, os dados ficam em memória
+	DB     *database.Database
 	Router *mux.Router
+
+	// categories - categorias registradas com registerResource
+	categories []category
+	// names - índice dos nomes para o autocompletar, atualizado pelos repositórios
+	names *crud.NameIndex
+	// blobs - armazenamento das imagens enviadas
+	blobs storage.BlobStore
+	// auth - autenticação das rotas; nil somente com AUTH=disabled
+	auth *authConfig
+	// accounts - contas de usuário; nil quando não há JWT_SECRET para emitir tokens
+	accounts *accounts
+	// audit - histórico das alterações feitas pelos repositórios
+	audit crud.AuditLog
+	// auditor - grava no histórico as alterações de todas as categorias, guardando as que falharem
+	auditor *crud.Auditor
+	// revisions - cópias dos documentos a cada alteração
+	revisions crud.RevisionStore
+	// revisioner - grava as revisões de todas as categorias, guardando as que falharem
+	revisioner *crud.Revisioner
+	// trashRetention - tempo na lixeira antes da remoção definitiva; zero desliga a limpeza
+	trashRetention time.Duration
+}
+
+// resource - manipuladores HTTP genéricos para uma categoria de plantas
+type resource[T any, PT crud.DocumentPtr[T]] struct {
+	category  string
+	store     crud.PlantRepository[T]
+	blobs     storage.BlobStore
+	auth      *authConfig
+	revisions crud.RevisionStore
 }
 
-// createFruitHandler - cria uma nova fruta
-func (app *App) createFruitHandler(w http.ResponseWriter, r *http.Request) {
-	var fruit crud.Fruit
+// create - cria um novo documento na categoria
+func (res *resource[T, PT]) create(w http.ResponseWriter, r *http.Request) {
+	if !res.auth.allow(w, r, auth.ActionCreate, res.category) {
+		return
+	}
 
-	err := json.NewDecoder(r.Body).Decode(&fruit)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeBodyError(w, r, err)
 		return
 	}
 
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			log.Println(err)
 		}
 	}(r.Body)
 
-	if err := fruit.Create(context.Background(), app.DB["fruits"]); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	err = json.NewEncoder(w).Encode(fruit)
+	var doc T
 
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := json.Unmarshal(body, &doc); err != nil {
+		writeInvalidBody(w, r)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-}
-
-// readFruitHandler - lê uma fruta específica usando o ID fornecido
-func (app *App) readFruitHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-
-	id, err := primitive.ObjectIDFromHex(vars["id"])
-
-	if err != nil {
-		http.Error(w, "ID inválido", http.StatusBadRequest)
+	// o ID, a versão, as imagens e a situação editorial são definidos pela API
+	if field, err := changedReadOnly(body, nil); err != nil {
+		writeError(w, r, err)
+		return
+	} else if field != "" {
+		writeReadOnly(w, r, field, readOnlyMessage(field))
 		return
 	}
 
-	var fruit crud.Fruit
-
-	if err := fruit.Read(context.Background(), app.DB["fruits"], id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := crud.Validate(&doc); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	err = json.NewEncoder(w).Encode(fruit)
+	// todo documento começa como rascunho do autor (ver setStatus)
+	PT(&doc).PlantData().Status = crud.StatusDraft
+	PT(&doc).PlantData().CreatedBy = crud.Actor(r.Context())
 
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := res.store.Create(r.Context(), &doc); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	res.writeDocument(w, http.StatusCreated, &doc)
 }
 
-// updateFruitHandler - atualiza uma fruta usando o ID fornecido e os dados do corpo da requisição
-func (app *App) updateFruitHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-
-	id, err := primitive.ObjectIDFromHex(vars["id"])
-
-	if err != nil {
-		http.Error(w, "ID inválido", http.StatusBadRequest)
+// read - lê um documento específico usando o ID fornecido
+func (res *resource[T, PT]) read(w http.ResponseWriter, r *http.Request) {
+	if !res.auth.allow(w, r, auth.ActionRead, res.category) {
 		return
 	}
 
-	var fruit crud.Fruit
-
-	err = json.NewDecoder(r.Body).Decode(&fruit)
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeInvalidID(w, r)
 		return
 	}
 
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-	}(r.Body)
+	// os documentos não publicados só existem para quem pode vê-los
+	doc, ok := res.readVisible(w, r, id)
 
-	fruit.ID = id
-
-	if err := fruit.Update(context.Background(), app.DB["fruits"], id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if !ok {
 		return
 	}
 
-	err = json.NewEncoder(w).Encode(fruit)
+	editor := res.editor(r)
 
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	lang, qerr := requestLanguage(r)
+
+	if qerr != nil {
+		writeInvalidParameter(w, r, qerr.param, qerr.detail)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
-}
+	writeLanguage(w, lang)
 
-// deleteFruitHandler - exclui uma fruta usando o ID fornecido
-func (app *App) deleteFruitHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
+	// a visão pública ou editorial depende das credenciais
+	w.Header().Add("Vary", "Authorization, X-API-Key")
 
-	id, err := primitive.ObjectIDFromHex(vars["id"])
+	variant := []string{lang}
 
-	if err != nil {
-		http.Error(w, "ID inválido", http.StatusBadRequest)
-		return
+	if !editor {
+		variant = append(variant, "public")
 	}
 
-	var fruit crud.Fruit
-	fruit.ID = id
+	tag := etag(PT(doc).PlantData().Version, variant...)
+	w.Header().Set("ETag", tag)
 
-	if err := fruit.Delete(app.DB["fruits"], id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if notModified(r, tag) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
-}
+	PT(doc).PlantData().Localize(lang)
 
-// GetAllFruitsHandler retorna todas as frutas, limitando e pulando resultados com base em parâmetros de consulta
-func (app *App) GetAllFruitsHandler(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query()
+	if !editor {
+		publicView(PT(doc).PlantData())
+	}
 
-	limitParam := query.Get("limit")
-	offsetParam := query.Get("offset")
+	writeJSON(w, http.StatusOK, doc)
+}
 
-	if limitParam == "" || offsetParam == "" {
-		http.Error(w, "Os parâmetros 'limit' e 'offset' são obrigatórios", http.StatusBadRequest)
+// update - atualiza um documento usando o ID fornecido e os dados do corpo da requisição
+func (res *resource[T, PT]) update(w http.ResponseWriter, r *http.Request) {
+	if !res.auth.allow(w, r, auth.ActionUpdate, res.category) {
 		return
 	}
 
-	limit, err := strconv.Atoi(limitParam)
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
 
 	if err != nil {
-		http.Error(w, "Valor inválido para o parâmetro 'limit'", http.StatusBadRequest)
+		writeInvalidID(w, r)
 		return
 	}
 
-	offset, err := strconv.Atoi(offsetParam)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
 
 	if err != nil {
-		http.Error(w, "Valor inválido para o parâmetro 'offset'", http.StatusBadRequest)
+		writeBodyError(w, r, err)
 		return
 	}
 
-	fruits, err := crud.GetAllFruits(app.DB["fruits"], int64(limit), int64(offset))
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			log.Println(err)
+		}
+	}(r.Body)
+
+	var doc T
 
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := json.Unmarshal(body, &doc); err != nil {
+		writeInvalidBody(w, r)
 		return
 	}
 
-	err = json.NewEncoder(w).Encode(fruits)
+	if err := crud.Validate(&doc); err != nil {
+		writeError(w, r, err)
+		return
+	}
 
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	current, ok := res.readVisible(w, r, id)
+
+	if !ok || !res.canEdit(w, r, PT(current).PlantData()) {
+		return
+	}
+
+	// o corpo lido com GET pode repetir os campos mantidos pela API, mas não alterá-los
+	if field, err := changedReadOnly(body, current); err != nil {
+		writeError(w, r, err)
+		return
+	} else if field != "" {
+		writeReadOnly(w, r, field, readOnlyMessage(field))
 		return
 	}
-}
 
-// createVegetableHandler - cria um novo vegetal
-func (app *App) createVegetableHandler(w http.ResponseWriter, r *http.Request) {
-	var vegetable crud.Vegetable
+	currentVersion := PT(current).PlantData().Version
 
-	err := json.NewDecoder(r.Body).Decode(&vegetable)
+	version, ok, err := ifMatchVersion(r, func() (int64, error) { return currentVersion, nil })
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
 
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-	}(r.Body)
-
-	if err := vegetable.Create(context.Background(), app.DB["vegetables"]); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if !ok || (version != crud.AnyVersion && version != currentVersion) {
+		writePreconditionFailed(w, r)
 		return
 	}
 
-	err = json.NewEncoder(w).Encode(vegetable)
+	patch, err := crud.ReplacePatch(&doc)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
+	res.writeContent(w, r, id, crud.ContentPatch(patch, PT(current).PlantData()), currentVersion, version)
 }
 
-// readVegetableHandler - lê um vegetal específico usando o ID fornecido
-func (app *App) readVegetableHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
+// writeContent - grava a alteração de conteúdo na versão lida e escreve o documento atualizado
+//
+// Sem If-Match (version igual a crud.AnyVersion), a versão lida garante que nenhuma
+// alteração concorrente seja sobrescrita; a divergência é então um conflito.
+func (res *resource[T, PT]) writeContent(w http.ResponseWriter, r *http.Request, id primitive.ObjectID, patch crud.Patch, currentVersion, version int64) {
+	updated, err := res.store.Patch(r.Context(), id, patch, currentVersion)
 
-	id, err := primitive.ObjectIDFromHex(vars["id"])
+	if errors.Is(err, crud.ErrVersionMismatch) && version == crud.AnyVersion {
+		err = fmt.Errorf("%w: %v", crud.ErrConflict, err)
+	}
 
 	if err != nil {
-		http.Error(w, "ID inválido", http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
 
-	var vegetable crud.Vegetable
+	res.writeDocument(w, http.StatusOK, updated)
+}
 
-	if err := vegetable.Read(context.Background(), app.DB["vegetables"], id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+// patch - atualiza parcialmente um documento com JSON Merge Patch ou JSON Patch
+func (res *resource[T, PT]) patch(w http.ResponseWriter, r *http.Request) {
+	if !res.auth.allow(w, r, auth.ActionUpdate, res.category) {
 		return
 	}
 
-	err = json.NewEncoder(w).Encode(vegetable)
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeInvalidID(w, r)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-}
+	media, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
 
-// updateVegetableHandler - atualiza um vegetal usando o ID fornecido e os dados do corpo da requisição
-func (app *App) updateVegetableHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
+	if media != mediaMergePatch && media != mediaJSONPatch {
+		w.Header().Set("Accept-Patch", mediaMergePatch+", "+mediaJSONPatch)
+		writeProblem(w, r, newProblem(http.StatusUnsupportedMediaType, codeUnsupportedMedia, "Use "+mediaMergePatch+" ou "+mediaJSONPatch))
+		return
+	}
 
-	id, err := primitive.ObjectIDFromHex(vars["id"])
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
 
 	if err != nil {
-		http.Error(w, "ID inválido", http.StatusBadRequest)
+		writeBodyError(w, r, err)
+		return
+	}
+
+	current, ok := res.readVisible(w, r, id)
+
+	if !ok || !res.canEdit(w, r, PT(current).PlantData()) {
 		return
 	}
 
-	var vegetable crud.Vegetable
+	currentVersion := PT(current).PlantData().Version
 
-	err = json.NewDecoder(r.Body).Decode(&vegetable)
+	version, ok, err := ifMatchVersion(r, func() (int64, error) { return currentVersion, nil })
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-	}(r.Body)
 
-	vegetable.ID = id
-
-	if err := vegetable.Update(context.Background(), app.DB["vegetables"], id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if !ok || (version != crud.AnyVersion && version != currentVersion) {
+		writePreconditionFailed(w, r)
 		return
 	}
 
-	err = json.NewEncoder(w).Encode(vegetable)
+	fields, err := toJSONMap(current)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
-}
+	original := map[string]interface{}{}
+	for _, field := range crud.ServerManaged {
+		original[field] = fields[field]
+	}
 
-// deleteVegetableHandler - exclui um vegetal usando o ID fornecido
-func (app *App) deleteVegetableHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
+	var touched []string
 
-	id, err := primitive.ObjectIDFromHex(vars["id"])
+	if media == mediaMergePatch {
+		touched, err = mergePatch(fields, body)
+	} else {
+		touched, err = jsonPatch(fields, body)
+	}
 
 	if err != nil {
-		http.Error(w, "ID inválido", http.StatusBadRequest)
+		writeProblem(w, r, newProblem(http.StatusBadRequest, codeInvalidPatch, err.Error()))
 		return
 	}
 
-	var vegetable crud.Vegetable
-	vegetable.ID = id
-
-	if err := vegetable.Delete(app.DB["vegetables"], id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	for _, field := range crud.ServerManaged {
+		if !reflect.DeepEqual(fields[field], original[field]) {
+			writeReadOnly(w, r, field, readOnlyMessage(field))
+			return
+		}
 	}
 
-	w.WriteHeader(http.StatusNoContent)
-}
+	var doc T
 
-// GetAllVegetablesHandler retorna todos os vegetais, limitando e pulando resultados com base em parâmetros de consulta
-func (app *App) GetAllVegetablesHandler(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query()
-
-	limitParam := query.Get("limit")
-	offsetParam := query.Get("offset")
-
-	if limitParam == "" || offsetParam == "" {
-		http.Error(w, "Os parâmetros 'limit' e 'offset' são obrigatórios", http.StatusBadRequest)
+	if err := fromJSONMap(fields, &doc); err != nil {
+		writeProblem(w, r, newProblem(http.StatusBadRequest, codeInvalidPatch, "O resultado do patch não é um documento válido para este recurso"))
 		return
 	}
 
-	limit, err := strconv.Atoi(limitParam)
-
-	if err != nil {
-		http.Error(w, "Valor inválido para o parâmetro 'limit'", http.StatusBadRequest)
+	if err := crud.Validate(&doc); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	offset, err := strconv.Atoi(offsetParam)
+	patch, err := crud.NewPatch(&doc, touched)
 
 	if err != nil {
-		http.Error(w, "Valor inválido para o parâmetro 'offset'", http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
 
-	vegetables, err := crud.GetAllVegetables(app.DB["vegetables"], int64(limit), int64(offset))
+	res.writeContent(w, r, id, crud.ContentPatch(patch, PT(current).PlantData()), currentVersion, version)
+}
 
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+// delete - move para a lixeira o documento com o ID fornecido
+func (res *resource[T, PT]) delete(w http.ResponseWriter, r *http.Request) {
+	if !res.auth.allow(w, r, auth.ActionDelete, res.category) {
 		return
 	}
 
-	err = json.NewEncoder(w).Encode(vegetables)
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeInvalidID(w, r)
 		return
 	}
-}
-
-// createGreenHandler - cria um novo vegetal
-func (app *App) createGreenHandler(w http.ResponseWriter, r *http.Request) {
-	var green crud.Green
 
-	err := json.NewDecoder(r.Body).Decode(&green)
+	version, ok, err := ifMatchVersion(r, res.currentVersion(r, id))
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
 
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-	}(r.Body)
+	if !ok {
+		writePreconditionFailed(w, r)
+		return
+	}
 
-	if err := green.Create(context.Background(), app.DB["greens"]); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// as imagens ficam no armazenamento até a limpeza da lixeira (ver purge)
+	if err := res.store.Delete(r.Context(), id, version); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	err = json.NewEncoder(w).Encode(green)
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+// list - retorna uma página dos documentos filtrados, ordenados e projetados com base em parâmetros de consulta
+func (res *resource[T, PT]) list(w http.ResponseWriter, r *http.Request) {
+	if !res.auth.allow(w, r, auth.ActionRead, res.category) {
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-}
+	query := r.URL.Query()
 
-// readGreenHandler - lê um vegetal específico usando o ID fornecido
-func (app *App) readGreenHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
+	opts, qerr := parseListQuery(query)
 
-	id, err := primitive.ObjectIDFromHex(vars["id"])
+	if qerr == nil {
+		opts.Limit, qerr = pageSize(query)
+	}
 
-	if err != nil {
-		http.Error(w, "ID inválido", http.StatusBadRequest)
-		return
+	if qerr == nil {
+		opts.Offset, qerr = pageOffset(query)
 	}
 
-	var green crud.Green
+	var lang string
+
+	if qerr == nil {
+		lang, qerr = requestLanguage(r)
+	}
 
-	if err := green.Read(context.Background(), app.DB["greens"], id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if qerr != nil {
+		writeInvalidParameter(w, r, qerr.param, qerr.detail)
 		return
 	}
 
-	err = json.NewEncoder(w).Encode(green)
+	editor := res.editor(r)
+	opts.Filter = res.visibleFilter(r, opts.Filter)
 
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	limit := opts.Limit
+	sortSpec := strings.Join(splitValues(query["sort"]), ",")
+	order := crud.SortOrder(opts.Sort)
+
+	var cur cursor
+
+	if value := query.Get("cursor"); value != "" {
+		var err error
+		cur, err = decodeCursor(value, sortSpec, order)
+
+		if err != nil {
+			writeInvalidParameter(w, r, "cursor", "Cursor inválido ou gerado para outra ordenação")
+			return
+		}
+
+		if cur.Before {
+			opts.Before = cur.Key
+		} else {
+			opts.After = cur.Key
+		}
 	}
 
-	w.WriteHeader(http.StatusOK)
-}
+	// os campos da ordenação são necessários para montar os cursores, e as traduções para Localize
+	if len(opts.Fields) > 0 {
+		for _, f := range order {
+			opts.Fields = append(opts.Fields, f.Field)
+		}
+		opts.Fields = append(opts.Fields, "translations")
+	}
 
-// updateGreenHandler - atualiza um vegetal usando o ID fornecido e os dados do corpo da requisição
-func (app *App) updateGreenHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
+	// um item a mais indica se existe outra página na mesma direção
+	opts.Limit = limit + 1
 
-	id, err := primitive.ObjectIDFromHex(vars["id"])
+	docs, err := res.store.List(r.Context(), opts)
 
 	if err != nil {
-		http.Error(w, "ID inválido", http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
 
-	var green crud.Green
+	more := int64(len(docs)) > limit
 
-	err = json.NewDecoder(r.Body).Decode(&green)
+	if more && cur.Before {
+		docs = docs[1:]
+	} else if more {
+		docs = docs[:limit]
+	}
+
+	total, err := res.store.Count(r.Context(), opts.Filter)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, r, err)
 		return
 	}
 
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
+	result := page{Total: total, Limit: limit}
+
+	if len(docs) > 0 {
+		hasPrev := (cur.Before && more) || (!cur.Before && (cur.Key != nil || opts.Offset > 0))
+		hasNext := (!cur.Before && more) || cur.Before
+
+		if hasPrev {
+			result.PrevCursor, err = pageCursor(&docs[0], sortSpec, order, true)
+		}
+
+		if err == nil && hasNext {
+			result.NextCursor, err = pageCursor(&docs[len(docs)-1], sortSpec, order, false)
+		}
+
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, r, err)
 			return
 		}
-	}(r.Body)
+	}
 
-	green.ID = id
+	if docs == nil {
+		docs = []T{}
+	}
 
-	if err := green.Update(context.Background(), app.DB["greens"], id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	for i := range docs {
+		PT(&docs[i]).PlantData().Localize(lang)
+
+		if !editor {
+			publicView(PT(&docs[i]).PlantData())
+		}
 	}
 
-	err = json.NewEncoder(w).Encode(green)
+	result.Data, err = projectFields(docs, query)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	writeLinks(w, r, result)
+	writeLanguage(w, lang)
+	writeJSON(w, http.StatusOK, result)
 }
 
-// deleteGreenHandler - exclui um vegetal usando o ID fornecido
-func (app *App) deleteGreenHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-
-	id, err := primitive.ObjectIDFromHex(vars["id"])
-
+// pageCursor - cria o cursor que tem o documento como âncora
+func pageCursor(doc interface{}, sortSpec string, order []crud.SortField, before bool) (string, error) {
+	key, err := crud.SortKey(doc, order)
 	if err != nil {
-		http.Error(w, "ID inválido", http.StatusBadRequest)
-		return
+		return "", err
 	}
+	return encodeCursor(cursor{Sort: sortSpec, Key: key, Before: before})
+}
 
-	var green crud.Green
-	green.ID = id
-
-	if err := green.Delete(app.DB["greens"], id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+// currentVersion - consulta a versão atual do documento, usada quando If-Match lista várias ETags
+func (res *resource[T, PT]) currentVersion(r *http.Request, id primitive.ObjectID) func() (int64, error) {
+	return func() (int64, error) {
+		doc, err := res.store.Read(r.Context(), id)
+		if err != nil {
+			return 0, err
+		}
+		return PT(doc).PlantData().Version, nil
 	}
+}
 
-	w.WriteHeader(http.StatusNoContent)
+// imagePathReadOnly - violação de quem tenta definir a capa pelo corpo do documento
+const imagePathReadOnly = "campo somente leitura; envie a imagem para POST /{id}/images e escolha a capa em PUT /{id}/images/{image}/cover"
+
+// readOnlyMessage - mensagem da violação do campo mantido pela API
+func readOnlyMessage(field string) string {
+	if field == "image_path" {
+		return imagePathReadOnly
+	}
+	return "campo somente leitura"
 }
 
-// GetAllGreensHandler retorna todos os vegetais, limitando e pulando resultados com base em parâmetros de consulta
-func (app *App) GetAllGreensHandler(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query()
+// changedReadOnly - primeiro campo de crud.ServerManaged que o corpo define com um valor
+// diferente do documento atual; na criação, com current nil, qualquer um deles
+func changedReadOnly(body []byte, current interface{}) (string, error) {
+	var fields map[string]interface{}
 
-	limitParam := query.Get("limit")
-	offsetParam := query.Get("offset")
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return "", err
+	}
 
-	if limitParam == "" || offsetParam == "" {
-		http.Error(w, "Os parâmetros 'limit' e 'offset' são obrigatórios", http.StatusBadRequest)
-		return
+	original := map[string]interface{}{}
+
+	if current != nil {
+		var err error
+		if original, err = toJSONMap(current); err != nil {
+			return "", err
+		}
 	}
 
-	limit, err := strconv.Atoi(limitParam)
+	for _, field := range crud.ServerManaged {
+		value, ok := fields[field]
 
-	if err != nil {
-		http.Error(w, "Valor inválido para o parâmetro 'limit'", http.StatusBadRequest)
-		return
+		if ok && (current == nil || !reflect.DeepEqual(value, original[field])) {
+			return field, nil
+		}
 	}
 
-	offset, err := strconv.Atoi(offsetParam)
+	return "", nil
+}
+
+// writeReadOnly - responde 422 com a violação do campo mantido pela API
+func writeReadOnly(w http.ResponseWriter, r *http.Request, field, message string) {
+	p := errorProblem(crud.ErrValidation)
+	p.Errors = []crud.FieldError{{Field: field, Code: codeReadOnly, Message: message}}
+	writeProblem(w, r, p)
+}
+
+// writeDocument - escreve o documento com a ETag da sua versão
+func (res *resource[T, PT]) writeDocument(w http.ResponseWriter, status int, doc *T) {
+	w.Header().Set("ETag", etag(PT(doc).PlantData().Version))
+	writeJSON(w, status, doc)
+}
 
+// toJSONMap - converte o documento para sua representação JSON genérica
+func toJSONMap(doc interface{}) (map[string]interface{}, error) {
+	raw, err := json.Marshal(doc)
 	if err != nil {
-		http.Error(w, "Valor inválido para o parâmetro 'offset'", http.StatusBadRequest)
-		return
+		return nil, err
 	}
 
-	greens, err := crud.GetAllGreens(app.DB["greens"], int64(limit), int64(offset))
+	var fields map[string]interface{}
+	err = json.Unmarshal(raw, &fields)
+	return fields, err
+}
 
+// fromJSONMap - decodifica a representação JSON genérica no documento, rejeitando campos desconhecidos
+func fromJSONMap(fields map[string]interface{}, doc interface{}) error {
+	raw, err := json.Marshal(fields)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		return err
 	}
 
-	err = json.NewEncoder(w).Encode(greens)
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	return dec.Decode(doc)
+}
+
+// writeJSON - escreve o status e o corpo JSON da resposta
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(body)
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		log.Println(err)
 	}
 }
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"net/http"
+	"rastros-da-mata/crud"
+	"strings"
+	"testing"
+)
+
+func TestBodyTooLarge(t *testing.T) {
+	s := newTestServer(t)
+
+	reviewer := s.token("rui", "reviewer")
+	id := s.create(reviewer, map[string]interface{}{"name": "Pitanga"})
+
+	body := []byte(`{"name":"Pitanga","description":"` + strings.Repeat("a", maxBodySize) + `"}`)
+
+	for _, tt := range []struct {
+		method, path, contentType string
+	}{
+		{"POST", "/api/fruits", "application/json"},
+		{"PUT", "/api/fruits/" + id, "application/json"},
+		{"PATCH", "/api/fruits/" + id, mediaMergePatch},
+		{"POST", "/api/fruits/" + id + "/reject", "application/json"},
+	} {
+		resp, raw := s.do(tt.method, tt.path, reviewer, tt.contentType, body)
+		expectStatus(t, tt.method+" "+tt.path, resp.StatusCode, http.StatusRequestEntityTooLarge, string(raw))
+
+		if !strings.Contains(string(raw), codePayloadTooLarge) {
+			t.Errorf("%s %s: %s, esperado %s", tt.method, tt.path, raw, codePayloadTooLarge)
+		}
+	}
+}
+
+func TestReadOnlyFields(t *testing.T) {
+	s := newTestServer(t)
+
+	reviewer := s.token("rui", "reviewer")
+
+	for field, value := range map[string]interface{}{
+		"id":         "0123456789abcdef01234567",
+		"version":    7,
+		"status":     crud.StatusPublished,
+		"created_by": "ana",
+		"image_path": "/x.png",
+	} {
+		status, body := s.json("POST", "/api/fruits", reviewer, map[string]interface{}{"name": "Pitanga", field: value})
+		expectStatus(t, "POST com "+field, status, http.StatusUnprocessableEntity, body)
+
+		if errs, _ := body["errors"].([]interface{}); len(errs) != 1 || errs[0].(map[string]interface{})["field"] != field || errs[0].(map[string]interface{})["code"] != codeReadOnly {
+			t.Errorf("POST com %s: erros = %v", field, body["errors"])
+		}
+	}
+
+	id := s.create(reviewer, map[string]interface{}{"name": "Pitanga"})
+	path := "/api/fruits/" + id
+
+	// o corpo lido com GET pode ser enviado de volta com os campos mantidos pela API
+	status, doc := s.json("GET", path, reviewer, nil)
+	expectStatus(t, "GET", status, http.StatusOK, doc)
+
+	doc["description"] = "doce"
+	status, body := s.json("PUT", path, reviewer, doc)
+	expectStatus(t, "PUT do documento lido", status, http.StatusOK, body)
+
+	body["status"] = crud.StatusPublished
+	status, body = s.json("PUT", path, reviewer, body)
+	expectStatus(t, "PUT que altera o status", status, http.StatusUnprocessableEntity, body)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/text/language"
+	"net/http"
+	"rastros-da-mata/auth"
+	"rastros-da-mata/crud"
+	"strings"
+)
+
+// languageMatcher - escolhe entre os idiomas suportados a partir das preferências do cliente
+var languageMatcher = newLanguageMatcher()
+
+func newLanguageMatcher() language.Matcher {
+	tags := make([]language.Tag, len(crud.Languages))
+	for i, lang := range crud.Languages {
+		tags[i] = language.MustParse(lang)
+	}
+	return language.NewMatcher(tags)
+}
+
+// requestLanguage - idioma da resposta: ?lang= tem prioridade sobre Accept-Language
+func requestLanguage(r *http.Request) (string, *queryError) {
+	if value := r.URL.Query().Get("lang"); value != "" {
+		for _, lang := range crud.Languages {
+			if strings.EqualFold(value, lang) {
+				return lang, nil
+			}
+		}
+		return "", &queryError{"lang", "Idiomas suportados: " + strings.Join(crud.Languages, ", ")}
+	}
+
+	tags, _, err := language.ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	if err != nil || len(tags) == 0 {
+		return crud.DefaultLanguage, nil
+	}
+
+	_, i, confidence := languageMatcher.Match(tags...)
+	if confidence == language.No {
+		return crud.DefaultLanguage, nil
+	}
+	return crud.Languages[i], nil
+}
+
+// writeLanguage - informa o idioma da resposta e que ela varia com Accept-Language
+func writeLanguage(w http.ResponseWriter, lang string) {
+	w.Header().Set("Content-Language", lang)
+	w.Header().Add("Vary", "Accept-Language")
+}
+
+// translationStatus - traduções de um documento e os campos que ainda faltam traduzir
+type translationStatus struct {
+	ID           string                      `json:"id"`
+	Name         string                      `json:"name"`
+	Translations map[string]crud.Translation `json:"translations,omitempty"`
+	Missing      map[string][]string         `json:"missing"`
+}
+
+// newTranslationStatus - situação das traduções da planta
+func newTranslationStatus(plant *crud.Plant) translationStatus {
+	return translationStatus{
+		ID:           plant.ID.Hex(),
+		Name:         plant.Name,
+		Translations: plant.Translations,
+		Missing:      plant.MissingTranslations(),
+	}
+}
+
+// translations - mostra as traduções de um documento e os campos sem tradução
+func (res *resource[T, PT]) translations(w http.ResponseWriter, r *http.Request) {
+	if !res.auth.allow(w, r, auth.ActionRead, res.category) {
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+
+	if err != nil {
+		writeInvalidID(w, r)
+		return
+	}
+
+	doc, ok := res.readVisible(w, r, id)
+
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newTranslationStatus(PT(doc).PlantData()))
+}
+
+// missingTranslations - lista os documentos da categoria com traduções faltando, opcionalmente de um só idioma (?lang=)
+func (res *resource[T, PT]) missingTranslations(w http.ResponseWriter, r *http.Request) {
+	if !res.auth.allow(w, r, auth.ActionRead, res.category) {
+		return
+	}
+
+	lang := r.URL.Query().Get("lang")
+
+	if lang != "" && (lang == crud.DefaultLanguage || !contains(crud.Languages, lang)) {
+		writeInvalidParameter(w, r, "lang", "Idiomas de tradução: "+strings.Join(crud.Languages[1:], ", "))
+		return
+	}
+
+	pending := []translationStatus{}
+
+	err := res.each(r.Context(), res.visibleFilter(r, nil), nil, func(doc *T) error {
+		status := newTranslationStatus(PT(doc).PlantData())
+		status.Translations = nil
+
+		if lang != "" {
+			status.Missing = map[string][]string{lang: status.Missing[lang]}
+			if len(status.Missing[lang]) == 0 {
+				return nil
+			}
+		}
+
+		if len(status.Missing) > 0 {
+			pending = append(pending, status)
+		}
+		return nil
+	})
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"category": res.category,
+		"data":     pending,
+	})
+}
+
+// each - percorre os documentos da categoria que atendem ao filtro, em ordem de ID, página a página
+func (res *resource[T, PT]) each(ctx context.Context, filter []crud.Condition, fields []string, fn func(doc *T) error) error {
+	opts := crud.ListOptions{Filter: filter, Limit: maxPageSize, Fields: fields}
+
+	for {
+		docs, err := res.store.List(ctx, opts)
+		if err != nil {
+			return err
+		}
+
+		for i := range docs {
+			if err := fn(&docs[i]); err != nil {
+				return err
+			}
+		}
+
+		if int64(len(docs)) < opts.Limit {
+			return nil
+		}
+
+		opts.After = []interface{}{PT(&docs[len(docs)-1]).PlantData().ID}
+	}
+}
@@ -0,0 +1,465 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"rastros-da-mata/auth"
+	"rastros-da-mata/crud"
+	"rastros-da-mata/storage"
+)
+
+// maxImageSize - tamanho máximo dos arquivos de imagem enviados
+const maxImageSize = 10 << 20
+
+// maxFormValue - tamanho máximo dos demais campos do formulário de upload
+const maxFormValue = 4 << 10
+
+// imageField - campo do formulário multipart com o arquivo da imagem
+const imageField = "image"
+
+var (
+	errNoImage       = errors.New("campo de imagem ausente")
+	errImageTooLarge = errors.New("imagem maior que o permitido")
+)
+
+// uploadImage - recebe uma imagem da planta em multipart/form-data, grava o original e as
+// miniaturas no armazenamento e a acrescenta ao fim das imagens do documento
+//
+// Além do arquivo, o formulário aceita os campos de crud.ImageMetadata.
+func (res *resource[T, PT]) uploadImage(w http.ResponseWriter, r *http.Request) {
+	if !res.auth.allow(w, r, auth.ActionUpdate, res.category) {
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+
+	if err != nil {
+		writeInvalidID(w, r)
+		return
+	}
+
+	data, image, err := readUpload(w, r)
+
+	switch {
+	case errors.Is(err, errImageTooLarge):
+		writeProblem(w, r, newProblem(http.StatusRequestEntityTooLarge, codePayloadTooLarge, fmt.Sprintf("A imagem deve ter no máximo %d MB", maxImageSize>>20)))
+		return
+	case err != nil:
+		writeProblem(w, r, newProblem(http.StatusBadRequest, codeInvalidBody, "Envie a imagem no campo '"+imageField+"' de um formulário multipart/form-data"))
+		return
+	}
+
+	if err := crud.Validate(image); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	original, thumbnails, err := storage.ProcessImage(data)
+
+	switch {
+	case errors.Is(err, storage.ErrUnsupportedImage):
+		writeProblem(w, r, newProblem(http.StatusUnsupportedMediaType, codeUnsupportedMedia, "Envie uma imagem JPEG, PNG ou GIF"))
+		return
+	case errors.Is(err, storage.ErrInvalidImage):
+		writeError(w, r, &crud.ValidationError{Fields: []crud.FieldError{{
+			Field:   imageField,
+			Code:    "invalid_image",
+			Message: fmt.Sprintf("imagem corrompida ou com mais de %d megapixels", storage.MaxPixels/1_000_000),
+		}}})
+		return
+	case err != nil:
+		writeError(w, r, err)
+		return
+	}
+
+	// o documento e a permissão são conferidos antes de gravar os arquivos
+	current, ok := res.readVisible(w, r, id)
+
+	if !ok || !res.canEdit(w, r, PT(current).PlantData()) {
+		return
+	}
+
+	if err := res.storeImage(r.Context(), id, image, original, thumbnails); err != nil {
+		res.deleteImages(r.Context(), image.Key)
+		writeError(w, r, err)
+		return
+	}
+
+	updated, ok := res.editImages(w, r, id, func(images []crud.Image) ([]crud.Image, error) {
+		return append(images, *image), nil
+	})
+
+	if !ok {
+		res.deleteImages(r.Context(), image.Key)
+		return
+	}
+
+	w.Header().Set("Location", r.URL.Path+"/"+image.ID.Hex())
+	res.writeDocument(w, http.StatusCreated, updated)
+}
+
+// updateImage - altera os campos editáveis de uma imagem com JSON Merge Patch
+func (res *resource[T, PT]) updateImage(w http.ResponseWriter, r *http.Request) {
+	if !res.auth.allow(w, r, auth.ActionUpdate, res.category) {
+		return
+	}
+
+	id, imageID, ok := imageIDs(w, r)
+	if !ok {
+		return
+	}
+
+	media, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if media != mediaMergePatch && media != "application/json" {
+		w.Header().Set("Accept-Patch", mediaMergePatch)
+		writeProblem(w, r, newProblem(http.StatusUnsupportedMediaType, codeUnsupportedMedia, "Use "+mediaMergePatch))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+
+	if err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+
+	updated, ok := res.editImages(w, r, id, func(images []crud.Image) ([]crud.Image, error) {
+		i := crud.FindImage(images, imageID)
+		if i < 0 {
+			return nil, fmt.Errorf("%w: imagem %s", crud.ErrNotFound, imageID.Hex())
+		}
+
+		fields, err := toJSONMap(&images[i])
+		if err != nil {
+			return nil, err
+		}
+
+		touched, err := mergePatch(fields, body)
+		if err != nil {
+			return nil, errPatch
+		}
+
+		for _, field := range touched {
+			if !contains(crud.ImageMetadata, field) {
+				return nil, &crud.ValidationError{Fields: []crud.FieldError{{Field: field, Code: codeReadOnly, Message: "campo somente leitura"}}}
+			}
+		}
+
+		var image crud.Image
+		if err := fromJSONMap(fields, &image); err != nil {
+			return nil, errPatch
+		}
+
+		if err := crud.Validate(&image); err != nil {
+			return nil, err
+		}
+
+		// os campos não editáveis, como a chave no armazenamento, não passam pelo JSON
+		images[i].Caption, images[i].Stage = image.Caption, image.Stage
+		images[i].Credit, images[i].License = image.Credit, image.License
+		return images, nil
+	})
+
+	if ok {
+		res.writeDocument(w, http.StatusOK, updated)
+	}
+}
+
+// reorderImages - define a ordem de exibição com a lista completa dos IDs das imagens
+func (res *resource[T, PT]) reorderImages(w http.ResponseWriter, r *http.Request) {
+	if !res.auth.allow(w, r, auth.ActionUpdate, res.category) {
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+
+	if err != nil {
+		writeInvalidID(w, r)
+		return
+	}
+
+	var body struct {
+		Order []primitive.ObjectID `json:"order"`
+	}
+
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&body); err != nil {
+		writeBodyError(w, r, err)
+		return
+	}
+
+	updated, ok := res.editImages(w, r, id, func(images []crud.Image) ([]crud.Image, error) {
+		invalid := &crud.ValidationError{Fields: []crud.FieldError{{Field: "order", Code: "permutation", Message: "deve conter cada ID das imagens exatamente uma vez"}}}
+
+		if len(body.Order) != len(images) {
+			return nil, invalid
+		}
+
+		ordered := make([]crud.Image, 0, len(images))
+		for _, imageID := range body.Order {
+			i := crud.FindImage(images, imageID)
+			if i < 0 || crud.FindImage(ordered, imageID) >= 0 {
+				return nil, invalid
+			}
+			ordered = append(ordered, images[i])
+		}
+		return ordered, nil
+	})
+
+	if ok {
+		res.writeDocument(w, http.StatusOK, updated)
+	}
+}
+
+// setCover - define a imagem de capa, cujo endereço também fica em image_path
+func (res *resource[T, PT]) setCover(w http.ResponseWriter, r *http.Request) {
+	if !res.auth.allow(w, r, auth.ActionUpdate, res.category) {
+		return
+	}
+
+	id, imageID, ok := imageIDs(w, r)
+	if !ok {
+		return
+	}
+
+	updated, ok := res.editImages(w, r, id, func(images []crud.Image) ([]crud.Image, error) {
+		i := crud.FindImage(images, imageID)
+		if i < 0 {
+			return nil, fmt.Errorf("%w: imagem %s", crud.ErrNotFound, imageID.Hex())
+		}
+
+		for j := range images {
+			images[j].Cover = j == i
+		}
+		return images, nil
+	})
+
+	if ok {
+		res.writeDocument(w, http.StatusOK, updated)
+	}
+}
+
+// deleteImage - remove uma imagem do documento e os seus arquivos
+func (res *resource[T, PT]) deleteImage(w http.ResponseWriter, r *http.Request) {
+	if !res.auth.allow(w, r, auth.ActionUpdate, res.category) {
+		return
+	}
+
+	id, imageID, ok := imageIDs(w, r)
+	if !ok {
+		return
+	}
+
+	var removed crud.Image
+
+	updated, ok := res.editImages(w, r, id, func(images []crud.Image) ([]crud.Image, error) {
+		i := crud.FindImage(images, imageID)
+		if i < 0 {
+			return nil, fmt.Errorf("%w: imagem %s", crud.ErrNotFound, imageID.Hex())
+		}
+
+		removed = images[i]
+		return append(images[:i], images[i+1:]...), nil
+	})
+
+	if !ok {
+		return
+	}
+
+	res.deleteImages(r.Context(), removed.Key)
+	res.writeDocument(w, http.StatusOK, updated)
+}
+
+// editImages - aplica edit a uma cópia das imagens do documento e grava o resultado
+//
+// Confere If-Match como o patch do documento; sem o cabeçalho, a versão lida
+// garante que nenhuma alteração concorrente seja sobrescrita. Quando ok é
+// false, a resposta de erro já foi escrita.
+func (res *resource[T, PT]) editImages(w http.ResponseWriter, r *http.Request, id primitive.ObjectID, edit func(images []crud.Image) ([]crud.Image, error)) (updated *T, ok bool) {
+	current, ok := res.readVisible(w, r, id)
+
+	if !ok {
+		return nil, false
+	}
+
+	plant := PT(current).PlantData()
+
+	if !res.canEdit(w, r, plant) {
+		return nil, false
+	}
+
+	version, ok, err := ifMatchVersion(r, func() (int64, error) { return plant.Version, nil })
+
+	if err != nil {
+		writeError(w, r, err)
+		return nil, false
+	}
+
+	if !ok || (version != crud.AnyVersion && version != plant.Version) {
+		writePreconditionFailed(w, r)
+		return nil, false
+	}
+
+	images, err := edit(append([]crud.Image(nil), plant.Images...))
+
+	if errors.Is(err, errPatch) {
+		writeProblem(w, r, newProblem(http.StatusBadRequest, codeInvalidPatch, "O corpo deve ser um JSON Merge Patch com os campos da imagem"))
+		return nil, false
+	}
+
+	if err != nil {
+		writeError(w, r, err)
+		return nil, false
+	}
+
+	updated, err = res.store.Patch(r.Context(), id, crud.ContentPatch(crud.ImagesPatch(images), plant), plant.Version)
+
+	if errors.Is(err, crud.ErrVersionMismatch) && version == crud.AnyVersion {
+		err = fmt.Errorf("%w: %v", crud.ErrConflict, err)
+	}
+
+	if err != nil {
+		writeError(w, r, err)
+		return nil, false
+	}
+
+	return updated, true
+}
+
+// imageIDs - IDs do documento e da imagem informados no caminho
+func imageIDs(w http.ResponseWriter, r *http.Request) (id, imageID primitive.ObjectID, ok bool) {
+	vars := mux.Vars(r)
+
+	id, err := primitive.ObjectIDFromHex(vars["id"])
+
+	if err == nil {
+		imageID, err = primitive.ObjectIDFromHex(vars["image"])
+	}
+
+	if err != nil {
+		writeInvalidID(w, r)
+		return id, imageID, false
+	}
+
+	return id, imageID, true
+}
+
+// readUpload - lê o arquivo do campo imageField e os campos de metadados, sem gravar
+// partes temporárias em disco
+func readUpload(w http.ResponseWriter, r *http.Request) ([]byte, *crud.Image, error) {
+	// folga para os cabeçalhos e demais campos do formulário
+	r.Body = http.MaxBytesReader(w, r.Body, maxImageSize+1<<20)
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	image := &crud.Image{}
+	metadata := map[string]*string{
+		"caption": &image.Caption,
+		"stage":   &image.Stage,
+		"credit":  &image.Credit,
+		"license": &image.License,
+	}
+
+	var data []byte
+	var maxErr *http.MaxBytesError
+
+	for {
+		part, err := reader.NextPart()
+
+		if err == io.EOF {
+			break
+		}
+
+		if errors.As(err, &maxErr) {
+			return nil, nil, errImageTooLarge
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if part.FormName() == imageField {
+			data, err = io.ReadAll(io.LimitReader(part, maxImageSize+1))
+
+			if errors.As(err, &maxErr) || len(data) > maxImageSize {
+				return nil, nil, errImageTooLarge
+			}
+		} else if field, ok := metadata[part.FormName()]; ok {
+			var value []byte
+			value, err = io.ReadAll(io.LimitReader(part, maxFormValue))
+			*field = string(value)
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if data == nil {
+		return nil, nil, errNoImage
+	}
+
+	return data, image, nil
+}
+
+// storeImage - grava o original e as miniaturas em um diretório novo do documento,
+// preenchendo os campos da imagem
+func (res *resource[T, PT]) storeImage(ctx context.Context, id primitive.ObjectID, image *crud.Image, original storage.Rendition, thumbnails map[string]storage.Rendition) error {
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+
+	image.ID = primitive.NewObjectID()
+	image.Key = res.imageDir(id) + "/" + hex.EncodeToString(suffix)
+	image.ContentType = original.ContentType
+	image.Size = int64(len(original.Data))
+	image.Width = original.Width
+	image.Height = original.Height
+	image.Thumbnails = map[string]crud.Thumbnail{}
+
+	key := image.Key + "/original." + original.Ext
+	if err := res.blobs.Put(ctx, key, original.Data, original.ContentType); err != nil {
+		return err
+	}
+	image.URL = res.blobs.URL(key)
+
+	for name, thumb := range thumbnails {
+		key := image.Key + "/" + name + "." + thumb.Ext
+		if err := res.blobs.Put(ctx, key, thumb.Data, thumb.ContentType); err != nil {
+			return err
+		}
+		image.Thumbnails[name] = crud.Thumbnail{URL: res.blobs.URL(key), Width: thumb.Width, Height: thumb.Height}
+	}
+
+	return nil
+}
+
+// imageDir - diretório do armazenamento com todas as imagens do documento
+func (res *resource[T, PT]) imageDir(id primitive.ObjectID) string {
+	return res.category + "/" + id.Hex()
+}
+
+// deleteImages - remove os arquivos do diretório; falhas só são registradas, pois o documento já foi alterado
+func (res *resource[T, PT]) deleteImages(ctx context.Context, dir string) {
+	if dir == "" {
+		return
+	}
+
+	if err := res.blobs.DeleteAll(ctx, dir); err != nil {
+		log.Printf("Erro ao remover as imagens em %s: %v", dir, err)
+	}
+}
@@ -0,0 +1,790 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"rastros-da-mata/auth"
+	"rastros-da-mata/crud"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limites da importação em lote
+const (
+	maxImportSize   = 10 << 20
+	maxImportRows   = 10_000
+	importBatchSize = 500
+)
+
+// Tipos de mídia aceitos na importação
+const (
+	mediaCSV       = "text/csv"
+	mediaJSONLines = "application/x-ndjson"
+	mediaJSONL     = "application/jsonl"
+)
+
+// importParams - parâmetros aceitos na importação
+var importParams = map[string]bool{"dry_run": true, "upsert": true, "map": true, "delimiter": true}
+
+// importRow - linha do arquivo já no formato JSON do documento
+type importRow struct {
+	line   int
+	fields map[string]interface{}
+	errors []crud.FieldError
+}
+
+// rowError - violações de uma linha do arquivo
+type rowError struct {
+	Line   int               `json:"line"`
+	Errors []crud.FieldError `json:"errors"`
+}
+
+// importReport - resultado da importação; no dry_run, Created e Updated são o que seria feito
+type importReport struct {
+	DryRun  bool       `json:"dry_run"`
+	Rows    int        `json:"rows"`
+	Created int        `json:"created"`
+	Updated int        `json:"updated"`
+	Failed  int        `json:"failed"`
+	Errors  []rowError `json:"errors"`
+}
+
+// importDocuments - importa documentos de um arquivo CSV ou JSON Lines
+//
+// Parâmetros: map=coluna:campo (repetível; campos aninhados com ".", "-" ignora a
+// coluna), delimiter (CSV; padrão detectado entre "," e ";"), upsert=name (atualiza
+// somente os campos presentes nos documentos de mesmo nome) e dry_run=true (valida
+// sem gravar). As linhas válidas são gravadas mesmo quando outras falham; o
+// relatório traz as violações de cada linha.
+func (res *resource[T, PT]) importDocuments(w http.ResponseWriter, r *http.Request) {
+	if !res.auth.allow(w, r, auth.ActionCreate, res.category) {
+		return
+	}
+
+	query := r.URL.Query()
+
+	for name := range query {
+		if !importParams[name] {
+			writeInvalidParameter(w, r, name, "Parâmetro desconhecido")
+			return
+		}
+	}
+
+	dryRun, err := strconv.ParseBool(query.Get("dry_run"))
+
+	if query.Get("dry_run") == "" {
+		dryRun, err = false, nil
+	}
+
+	if err != nil {
+		writeInvalidParameter(w, r, "dry_run", "Use dry_run=true ou dry_run=false")
+		return
+	}
+
+	upsert := query.Get("upsert")
+
+	if upsert != "" && upsert != "name" {
+		writeInvalidParameter(w, r, "upsert", "O único critério de upsert é 'name'")
+		return
+	}
+
+	if upsert != "" && !res.auth.allow(w, r, auth.ActionUpdate, res.category) {
+		return
+	}
+
+	mapping, qerr := importMapping(query["map"])
+
+	if qerr == nil {
+		qerr = res.checkMapping(mapping)
+	}
+
+	if qerr != nil {
+		writeInvalidParameter(w, r, qerr.param, qerr.detail)
+		return
+	}
+
+	media, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if media != mediaCSV && media != mediaJSONLines && media != mediaJSONL {
+		writeProblem(w, r, newProblem(http.StatusUnsupportedMediaType, codeUnsupportedMedia, "Envie "+mediaCSV+" ou "+mediaJSONLines))
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportSize))
+
+	var tooLarge *http.MaxBytesError
+
+	if errors.As(err, &tooLarge) {
+		writeProblem(w, r, newProblem(http.StatusRequestEntityTooLarge, codePayloadTooLarge, fmt.Sprintf("O arquivo deve ter no máximo %d MB", maxImportSize>>20)))
+		return
+	}
+
+	if err != nil {
+		writeInvalidBody(w, r)
+		return
+	}
+
+	var rows []importRow
+
+	if media == mediaCSV {
+		delimiter, qerr := csvDelimiter(query.Get("delimiter"), data)
+
+		if qerr != nil {
+			writeInvalidParameter(w, r, qerr.param, qerr.detail)
+			return
+		}
+
+		rows, err = res.readCSV(data, delimiter, mapping)
+	} else {
+		rows, err = readJSONLines(data, mapping)
+	}
+
+	if err != nil {
+		writeProblem(w, r, newProblem(http.StatusBadRequest, codeInvalidBody, err.Error()))
+		return
+	}
+
+	if len(rows) > maxImportRows {
+		writeProblem(w, r, newProblem(http.StatusRequestEntityTooLarge, codePayloadTooLarge, fmt.Sprintf("O arquivo deve ter no máximo %d linhas", maxImportRows)))
+		return
+	}
+
+	report, err := res.importRows(r, rows, upsert != "", dryRun)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// importRows - valida as linhas e grava as válidas em lotes de importBatchSize
+func (res *resource[T, PT]) importRows(r *http.Request, rows []importRow, upsert, dryRun bool) (*importReport, error) {
+	report := &importReport{DryRun: dryRun, Rows: len(rows), Errors: []rowError{}}
+
+	// nomes já vistos no arquivo, para que o upsert não inclua o mesmo documento duas vezes
+	seen := map[string]int{}
+
+	for start := 0; start < len(rows); start += importBatchSize {
+		end := start + importBatchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		ops, lines, err := res.batchOps(r, rows[start:end], upsert, seen, report)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if dryRun {
+			for _, op := range ops {
+				report.count(op.Doc != nil)
+			}
+			continue
+		}
+
+		results, err := res.store.Bulk(r.Context(), ops)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for i, result := range results {
+			if result.Err != nil {
+				report.fail(lines[i], bulkFailure(result.Err))
+				continue
+			}
+			report.count(ops[i].Doc != nil)
+		}
+	}
+
+	sort.SliceStable(report.Errors, func(i, j int) bool { return report.Errors[i].Line < report.Errors[j].Line })
+	return report, nil
+}
+
+// batchOps - operações das linhas válidas do lote e a linha de cada uma
+//
+// No upsert, os documentos de mesmo nome de todo o lote são lidos em uma só
+// consulta, e a linha é validada já combinada com o documento existente.
+func (res *resource[T, PT]) batchOps(r *http.Request, rows []importRow, upsert bool, seen map[string]int, report *importReport) ([]crud.BulkOp[T], []int, error) {
+	ctx := r.Context()
+	docs := make([]*T, len(rows))
+	var names []string
+
+	for i, row := range rows {
+		doc, errs := rowDocument[T](row)
+
+		if len(errs) == 0 && upsert {
+			errs = uniqueName(PT(doc).PlantData().Name, row.line, seen)
+		}
+
+		if len(errs) > 0 {
+			report.fail(row.line, errs)
+			continue
+		}
+
+		docs[i] = doc
+
+		if upsert {
+			names = append(names, PT(doc).PlantData().Name)
+		}
+	}
+
+	existing := map[string][]*T{}
+
+	if len(names) > 0 {
+		found, err := res.store.FindByNames(ctx, names)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for i := range found {
+			key := crud.Fold(PT(&found[i]).PlantData().Name)
+			existing[key] = append(existing[key], &found[i])
+		}
+	}
+
+	var ops []crud.BulkOp[T]
+	var lines []int
+
+	for i, row := range rows {
+		doc := docs[i]
+
+		if doc == nil {
+			continue
+		}
+
+		plant := PT(doc).PlantData()
+		matches := existing[crud.Fold(plant.Name)]
+
+		if len(matches) > 1 {
+			report.fail(row.line, []crud.FieldError{{Field: "name", Code: "ambiguous", Message: "há mais de um documento com este nome"}})
+			continue
+		}
+
+		if len(matches) == 1 {
+			op, errs, err := res.updateOp(r, matches[0], row)
+
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if len(errs) > 0 {
+				report.fail(row.line, errs)
+				continue
+			}
+
+			ops = append(ops, op)
+			lines = append(lines, row.line)
+			continue
+		}
+
+		if errs, err := validationErrors(crud.Validate(doc)); err != nil {
+			return nil, nil, err
+		} else if len(errs) > 0 {
+			report.fail(row.line, errs)
+			continue
+		}
+
+		// os documentos novos começam como rascunho de quem importou (ver create)
+		plant.Status = crud.StatusDraft
+		plant.CreatedBy = crud.Actor(ctx)
+
+		ops = append(ops, crud.BulkOp[T]{Doc: doc})
+		lines = append(lines, row.line)
+	}
+
+	return ops, lines, nil
+}
+
+// updateOp - alteração do documento existente com os campos presentes na linha
+//
+// Somente os subcampos informados mudam: uma coluna ideal_temperature.min_celsius
+// preserva os demais campos de ideal_temperature.
+func (res *resource[T, PT]) updateOp(r *http.Request, current *T, row importRow) (crud.BulkOp[T], []crud.FieldError, error) {
+	plant := PT(current).PlantData()
+
+	// as mesmas regras do PUT e do PATCH (ver canEdit)
+	if reason := res.editDenied(r, plant); reason != "" {
+		return crud.BulkOp[T]{}, []crud.FieldError{{Field: "name", Code: codeForbidden, Message: reason}}, nil
+	}
+
+	fields, err := toJSONMap(current)
+
+	if err != nil {
+		return crud.BulkOp[T]{}, nil, err
+	}
+
+	mergeFields(fields, row.fields)
+
+	var merged T
+
+	if err := fromJSONMap(fields, &merged); err != nil {
+		return crud.BulkOp[T]{}, []crud.FieldError{decodeFailure(err)}, nil
+	}
+
+	errs, err := validationErrors(crud.Validate(&merged))
+
+	if err != nil || len(errs) > 0 {
+		return crud.BulkOp[T]{}, errs, err
+	}
+
+	patch, err := crud.NewPatch(&merged, leafPaths(row.fields, ""))
+
+	if err != nil {
+		return crud.BulkOp[T]{}, nil, err
+	}
+
+	return crud.BulkOp[T]{ID: plant.ID, Patch: crud.ContentPatch(patch, plant), Version: plant.Version}, nil, nil
+}
+
+// fail - contabiliza a linha rejeitada com as suas violações
+func (rep *importReport) fail(line int, errs []crud.FieldError) {
+	rep.Failed++
+	rep.Errors = append(rep.Errors, rowError{Line: line, Errors: errs})
+}
+
+// count - contabiliza a operação bem-sucedida
+func (rep *importReport) count(insert bool) {
+	if insert {
+		rep.Created++
+	} else {
+		rep.Updated++
+	}
+}
+
+// rowDocument - decodifica o documento da linha, sem validá-lo
+func rowDocument[T any](row importRow) (*T, []crud.FieldError) {
+	if len(row.errors) > 0 {
+		return nil, row.errors
+	}
+
+	var errs []crud.FieldError
+
+	for _, field := range crud.ServerManaged {
+		if _, ok := row.fields[field]; ok {
+			errs = append(errs, crud.FieldError{Field: field, Code: codeReadOnly, Message: "campo mantido pela API"})
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	var doc T
+
+	if err := fromJSONMap(row.fields, &doc); err != nil {
+		return nil, []crud.FieldError{decodeFailure(err)}
+	}
+
+	return &doc, nil
+}
+
+// uniqueName - violação do upsert quando o nome falta ou já apareceu no arquivo
+func uniqueName(name string, line int, seen map[string]int) []crud.FieldError {
+	key := crud.Fold(strings.TrimSpace(name))
+
+	if key == "" {
+		return []crud.FieldError{{Field: "name", Code: "required", Message: "campo obrigatório"}}
+	}
+
+	if first, ok := seen[key]; ok {
+		return []crud.FieldError{{Field: "name", Code: "duplicate", Message: fmt.Sprintf("nome repetido no arquivo (linha %d)", first)}}
+	}
+
+	seen[key] = line
+	return nil
+}
+
+// validationErrors - violações do erro de validação; outros erros são retornados em err
+func validationErrors(err error) ([]crud.FieldError, error) {
+	var verr *crud.ValidationError
+
+	if errors.As(err, &verr) {
+		return verr.Fields, nil
+	}
+	return nil, err
+}
+
+// mergeFields - aplica os campos da linha sobre o documento, preservando os subcampos não informados
+func mergeFields(dst, src map[string]interface{}) {
+	for key, value := range src {
+		if sub, ok := value.(map[string]interface{}); ok {
+			if target, ok := dst[key].(map[string]interface{}); ok {
+				mergeFields(target, sub)
+				continue
+			}
+		}
+		dst[key] = value
+	}
+}
+
+// leafPaths - caminhos "a.b" dos valores informados na linha; listas e objetos vazios são valores inteiros
+func leafPaths(fields map[string]interface{}, prefix string) []string {
+	var paths []string
+
+	for key, value := range fields {
+		if sub, ok := value.(map[string]interface{}); ok && len(sub) > 0 {
+			paths = append(paths, leafPaths(sub, prefix+key+".")...)
+			continue
+		}
+		paths = append(paths, prefix+key)
+	}
+
+	return paths
+}
+
+// bulkFailure - violação da linha cuja gravação falhou
+func bulkFailure(err error) []crud.FieldError {
+	var verr *crud.ValidationError
+
+	switch {
+	case errors.As(err, &verr):
+		return verr.Fields
+	case errors.Is(err, crud.ErrVersionMismatch), errors.Is(err, crud.ErrNotFound):
+		return []crud.FieldError{{Code: codeConflict, Message: "o documento foi alterado ou removido durante a importação"}}
+	case errors.Is(err, crud.ErrConflict):
+		return []crud.FieldError{{Code: codeConflict, Message: crud.ErrConflict.Error()}}
+	default:
+		log.Println(err)
+		return []crud.FieldError{{Code: codeInternal, Message: "erro ao gravar a linha"}}
+	}
+}
+
+// decodeFailure - violação correspondente ao erro de decodificação do JSON da linha
+func decodeFailure(err error) crud.FieldError {
+	var typeErr *json.UnmarshalTypeError
+
+	if errors.As(err, &typeErr) {
+		return crud.FieldError{Field: typeErr.Field, Code: "invalid_type", Message: "deve ser do tipo " + typeErr.Type.String()}
+	}
+
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return crud.FieldError{Field: strings.Trim(field, `"`), Code: "unknown_field", Message: "campo desconhecido"}
+	}
+
+	return crud.FieldError{Code: "invalid_value", Message: err.Error()}
+}
+
+// importMapping - lê os pares coluna:campo de ?map=
+func importMapping(values []string) (map[string]string, *queryError) {
+	mapping := map[string]string{}
+
+	for _, value := range values {
+		i := strings.LastIndex(value, ":")
+
+		if i <= 0 || i == len(value)-1 {
+			return nil, &queryError{"map", "Use map=coluna:campo, como map=Nome:name"}
+		}
+
+		mapping[strings.TrimSpace(value[:i])] = strings.TrimSpace(value[i+1:])
+	}
+
+	return mapping, nil
+}
+
+// checkMapping - confere se os campos de destino existem e podem ser importados
+func (res *resource[T, PT]) checkMapping(mapping map[string]string) *queryError {
+	for column, path := range mapping {
+		if path == "-" {
+			continue
+		}
+
+		if _, err := res.fieldType(path); err != nil {
+			return &queryError{"map", fmt.Sprintf("Coluna %q: %v", column, err)}
+		}
+	}
+
+	return nil
+}
+
+// fieldType - tipo do campo no caminho JSON ("a.b"), percorrendo structs e mapas de texto
+func (res *resource[T, PT]) fieldType(path string) (reflect.Type, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	parts := strings.Split(path, ".")
+
+	if contains(crud.ServerManaged, parts[0]) {
+		return nil, fmt.Errorf("o campo %s é mantido pela API", parts[0])
+	}
+
+	for _, part := range parts {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+
+		switch t.Kind() {
+		case reflect.Struct:
+			sf, ok := jsonField(t, part)
+			if !ok {
+				return nil, fmt.Errorf("campo desconhecido: %s", path)
+			}
+			t = sf.Type
+		case reflect.Map:
+			if t.Key().Kind() != reflect.String {
+				return nil, fmt.Errorf("campo desconhecido: %s", path)
+			}
+			t = t.Elem()
+		default:
+			return nil, fmt.Errorf("campo desconhecido: %s", path)
+		}
+	}
+
+	return t, nil
+}
+
+// jsonField - campo da struct, inclusive das embutidas, com o nome JSON informado
+func jsonField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			if inner, ok := jsonField(sf.Type, name); ok {
+				return inner, true
+			}
+			continue
+		}
+
+		tag, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if sf.IsExported() && tag == name {
+			return sf, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+// csvDelimiter - separador informado em ?delimiter= ou detectado no cabeçalho
+func csvDelimiter(value string, data []byte) (rune, *queryError) {
+	if value != "" {
+		r, size := utf8.DecodeRuneInString(value)
+		if size != len(value) || r == '"' || r == '\n' || r == '\r' {
+			return 0, &queryError{"delimiter", "Informe um único caractere, como ',' ou ';'"}
+		}
+		return r, nil
+	}
+
+	header, _, _ := bytes.Cut(data, []byte("\n"))
+
+	// planilhas em português costumam usar ";", pois a vírgula separa os decimais
+	if bytes.Count(header, []byte(";")) > bytes.Count(header, []byte(",")) {
+		return ';', nil
+	}
+	return ',', nil
+}
+
+// readCSV - converte as linhas do CSV, cuja primeira linha traz os nomes das colunas
+//
+// Células vazias são ignoradas. Listas de valores simples são separadas por "|".
+func (res *resource[T, PT]) readCSV(data []byte, delimiter rune, mapping map[string]string) ([]importRow, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.Comma = delimiter
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+
+	if err == io.EOF {
+		return nil, errors.New("O arquivo está vazio")
+	}
+
+	if err != nil {
+		return nil, csvError(err)
+	}
+
+	paths := make([]string, len(header))
+	types := make([]reflect.Type, len(header))
+
+	for i, column := range header {
+		column = strings.TrimSpace(column)
+		path, ok := mapping[column]
+
+		if !ok {
+			path = column
+		}
+
+		if path == "-" || path == "" {
+			continue
+		}
+
+		t, err := res.fieldType(path)
+
+		if err != nil {
+			return nil, fmt.Errorf("Coluna %q: %v; use map=coluna:campo ou map=coluna:- para ignorá-la", column, err)
+		}
+
+		paths[i], types[i] = path, t
+	}
+
+	var rows []importRow
+
+	for {
+		record, err := reader.Read()
+
+		if err == io.EOF {
+			break
+		}
+
+		// FieldPos só vale para o registro lido com sucesso
+		if err != nil {
+			return nil, csvError(err)
+		}
+
+		line, _ := reader.FieldPos(0)
+
+		row := importRow{line: line, fields: map[string]interface{}{}}
+
+		for i, cell := range record {
+			cell = strings.TrimSpace(cell)
+
+			if i >= len(paths) || paths[i] == "" || cell == "" {
+				continue
+			}
+
+			value, err := cellValue(types[i], cell)
+
+			if err != nil {
+				row.errors = append(row.errors, crud.FieldError{Field: paths[i], Code: "invalid_type", Message: err.Error()})
+				continue
+			}
+
+			setJSONPath(row.fields, paths[i], value)
+		}
+
+		if len(row.fields) > 0 || len(row.errors) > 0 {
+			rows = append(rows, row)
+		}
+	}
+
+	return rows, nil
+}
+
+// csvError - erro de leitura do CSV, com a linha e a coluna quando o problema é de formato
+func csvError(err error) error {
+	var perr *csv.ParseError
+
+	if errors.As(err, &perr) {
+		return fmt.Errorf("CSV inválido na linha %d, coluna %d: %v", perr.Line, perr.Column, perr.Err)
+	}
+
+	return fmt.Errorf("CSV inválido: %v", err)
+}
+
+// cellValue - converte o texto da célula para o tipo do campo
+func cellValue(t reflect.Type, cell string) (interface{}, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return cell, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(cell, 10, 64)
+		if err != nil {
+			return nil, errors.New("deve ser um número inteiro")
+		}
+		return n, nil
+	case reflect.Float32, reflect.Float64:
+		// aceita a vírgula decimal das planilhas em português
+		n, err := strconv.ParseFloat(strings.Replace(cell, ",", ".", 1), 64)
+		if err != nil {
+			return nil, errors.New("deve ser um número")
+		}
+		return n, nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(cell)
+		if err != nil {
+			return nil, errors.New("deve ser true ou false")
+		}
+		return b, nil
+	case reflect.Slice:
+		var items []interface{}
+		for _, part := range strings.Split(cell, "|") {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+			item, err := cellValue(t.Elem(), part)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	default:
+		return nil, errors.New("não pode ser informado em uma célula; use os subcampos ou JSON Lines")
+	}
+}
+
+// readJSONLines - decodifica um objeto JSON por linha, renomeando os campos de primeiro nível conforme o mapeamento
+func readJSONLines(data []byte, mapping map[string]string) ([]importRow, error) {
+	var rows []importRow
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64<<10), maxImportSize)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+
+		if len(text) == 0 {
+			continue
+		}
+
+		var object map[string]interface{}
+
+		if err := json.Unmarshal(text, &object); err != nil {
+			rows = append(rows, importRow{line: line, errors: []crud.FieldError{{Code: codeInvalidBody, Message: "a linha não é um objeto JSON válido"}}})
+			continue
+		}
+
+		row := importRow{line: line, fields: map[string]interface{}{}}
+
+		for key, value := range object {
+			path, ok := mapping[key]
+
+			if !ok {
+				path = key
+			}
+
+			if path != "-" {
+				setJSONPath(row.fields, path, value)
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("JSON Lines inválido: %v", err)
+	}
+
+	return rows, nil
+}
+
+// setJSONPath - atribui o valor no caminho "a.b", criando os objetos intermediários
+func setJSONPath(fields map[string]interface{}, path string, value interface{}) {
+	keys := strings.Split(path, ".")
+
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := fields[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			fields[key] = next
+		}
+		fields = next
+	}
+
+	fields[keys[len(keys)-1]] = value
+}
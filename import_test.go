@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// importFile - envia o arquivo para a importação e decodifica o relatório
+func (s *testServer) importFile(token, query, contentType, data string) (int, importReport) {
+	s.t.Helper()
+
+	resp, raw := s.do("POST", "/api/fruits/import"+query, token, contentType, []byte(data))
+
+	var report importReport
+	if resp.StatusCode == http.StatusOK {
+		if err := json.Unmarshal(raw, &report); err != nil {
+			s.t.Fatal(err)
+		}
+	} else {
+		s.t.Logf("POST /api/fruits/import%s = %d: %s", query, resp.StatusCode, raw)
+	}
+	return resp.StatusCode, report
+}
+
+// fruitNames - nomes das frutas que o token lista
+func (s *testServer) fruitNames(token string) []string {
+	s.t.Helper()
+
+	status, body := s.json("GET", "/api/fruits?sort=name", token, nil)
+	expectStatus(s.t, "GET /api/fruits", status, http.StatusOK, body)
+
+	var names []string
+	for _, doc := range body["data"].([]interface{}) {
+		names = append(names, doc.(map[string]interface{})["name"].(string))
+	}
+	return names
+}
+
+func TestImportDryRun(t *testing.T) {
+	s := newTestServer(t)
+
+	reviewer := s.token("rui", "reviewer")
+
+	csv := "name;description\nPitanga;doce\n;sem nome\nAcerola;azeda\n"
+
+	status, report := s.importFile(reviewer, "?dry_run=true", mediaCSV, csv)
+	expectStatus(t, "importação dry_run", status, http.StatusOK, report)
+
+	if !report.DryRun || report.Rows != 3 || report.Created != 2 || report.Failed != 1 {
+		t.Errorf("relatório = %+v", report)
+	}
+	if len(report.Errors) != 1 || report.Errors[0].Line != 3 || report.Errors[0].Errors[0].Field != "name" {
+		t.Errorf("erros = %+v, esperado name na linha 3", report.Errors)
+	}
+
+	if names := s.fruitNames(reviewer); len(names) != 0 {
+		t.Errorf("o dry_run gravou %v", names)
+	}
+
+	status, report = s.importFile(reviewer, "", mediaCSV, csv)
+	expectStatus(t, "importação", status, http.StatusOK, report)
+
+	if names := s.fruitNames(reviewer); len(names) != 2 {
+		t.Errorf("frutas = %v, esperado Acerola e Pitanga", names)
+	}
+}
+
+func TestImportUpsertNested(t *testing.T) {
+	s := newTestServer(t)
+
+	reviewer := s.token("rui", "reviewer")
+
+	id := s.create(reviewer, map[string]interface{}{
+		"name":              "Pitanga",
+		"description":       "azeda",
+		"ideal_temperature": map[string]interface{}{"min_celsius": 18, "max_celsius": 30},
+	})
+
+	csv := "name,ideal_temperature.max_celsius\nPITANGA,32\nAcerola,35\n"
+
+	status, report := s.importFile(reviewer, "?upsert=name", mediaCSV, csv)
+	expectStatus(t, "importação com upsert", status, http.StatusOK, report)
+
+	if report.Created != 1 || report.Updated != 1 || report.Failed != 0 {
+		t.Fatalf("relatório = %+v", report)
+	}
+
+	status, body := s.json("GET", "/api/fruits/"+id, reviewer, nil)
+	expectStatus(t, "GET do documento atualizado", status, http.StatusOK, body)
+
+	temperature, _ := body["ideal_temperature"].(map[string]interface{})
+
+	// o upsert altera somente a coluna enviada, mantendo os demais subcampos
+	if temperature["min_celsius"] != 18.0 || temperature["max_celsius"] != 32.0 || body["description"] != "azeda" {
+		t.Errorf("documento = %v", body)
+	}
+}
+
+func TestImportUpsertDuplicates(t *testing.T) {
+	s := newTestServer(t)
+
+	reviewer := s.token("rui", "reviewer")
+
+	lines := `{"name":"Pitanga","description":"doce"}
+{"name":"pitanga","description":"azeda"}
+{"name":"Acerola","image_path":"/x.png"}
+`
+
+	status, report := s.importFile(reviewer, "?upsert=name", mediaJSONLines, lines)
+	expectStatus(t, "importação com nomes repetidos", status, http.StatusOK, report)
+
+	if report.Created != 1 || report.Failed != 2 {
+		t.Fatalf("relatório = %+v", report)
+	}
+
+	if report.Errors[0].Line != 2 || report.Errors[0].Errors[0].Field != "name" {
+		t.Errorf("erro da linha repetida = %+v", report.Errors[0])
+	}
+	if report.Errors[1].Line != 3 || report.Errors[1].Errors[0].Field != "image_path" {
+		t.Errorf("erro do campo gerenciado pela API = %+v", report.Errors[1])
+	}
+}
+
+func TestImportPermissions(t *testing.T) {
+	s := newTestServer(t)
+
+	status, _ := s.importFile("", "", mediaCSV, "name\nPitanga\n")
+	expectStatus(t, "importação anônima", status, http.StatusUnauthorized, nil)
+
+	status, _ = s.importFile(s.token("ana", "editor"), "?upsert=id", mediaCSV, "name\nPitanga\n")
+	expectStatus(t, "upsert por outro critério", status, http.StatusBadRequest, nil)
+
+	status, _ = s.importFile(s.token("ana", "editor"), "", "application/json", `{"name":"Pitanga"}`)
+	expectStatus(t, "tipo de mídia não aceito", status, http.StatusUnsupportedMediaType, nil)
+}
+
+func TestImportMalformedCSV(t *testing.T) {
+	s := newTestServer(t)
+
+	resp, raw := s.do("POST", "/api/fruits/import", s.token("rui", "reviewer"), mediaCSV, []byte("name,description\na\"b,c\n"))
+	expectStatus(t, "importação de CSV malformado", resp.StatusCode, http.StatusBadRequest, string(raw))
+
+	var problem map[string]interface{}
+	if err := json.Unmarshal(raw, &problem); err != nil {
+		t.Fatal(err)
+	}
+	if detail, _ := problem["detail"].(string); !strings.Contains(detail, "linha 2") {
+		t.Errorf("detail = %q, esperado a linha 2", detail)
+	}
+}
@@ -2,66 +2,181 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
-	"go.mongodb.org/mongo-driver/mongo"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"rastros-da-mata/auth"
+	"rastros-da-mata/crud"
 	"rastros-da-mata/database"
+	"rastros-da-mata/mail"
+	"rastros-da-mata/storage"
+	"strings"
 	"syscall"
 	"time"
 )
 
 func main() {
 
-	// carrega as variáveis de ambiente do arquivo .env
+	// carrega as variáveis de ambiente do arquivo .env, quando existir
 	err := godotenv.Load()
 	if err != nil {
-		log.Fatalf("Erro ao carregar as variáveis de ambiente: %v", err)
+		log.Printf("Arquivo .env não carregado: %v", err)
 	}
 
-	db, err := database.Connect()
+	// STORAGE=memory executa a API sem MongoDB
+	var db *database.Database
+
+	if os.Getenv("STORAGE") != "memory" {
+		db, err = database.Connect()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		defer db.Close()
+	}
+
+	// "migrate" e "grant-admin" só usam o banco e encerram antes de a API ser montada
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			if err := migrate(db); err != nil {
+				log.Fatal(err)
+			}
+			return
+		case "grant-admin":
+			if err := grantAdmin(db, os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
+	// Inicializando roteador
+	router := mux.NewRouter()
+	router.NotFoundHandler = http.HandlerFunc(notFoundHandler)
+	router.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowedHandler)
+
+	blobs, err := storage.NewFromEnv()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	defer db.Close()
+	authentication, err := newAuthConfig(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var audit crud.AuditLog = crud.NewMemoryAuditLog()
+
+	if db != nil {
+		audit, err = crud.NewMongoAuditLog(context.Background(), db.Collection("audit"))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var revisions crud.RevisionStore = crud.NewMemoryRevisionStore()
+
+	if db != nil {
+		revisions, err = crud.NewMongoRevisionStore(context.Background(), db.Collection("revisions"))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	retention, interval, err := trashConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	// Inicializando roteador
 	app := &App{
-		DB: map[string]*mongo.Collection{
-			"fruits":     db.Collection("fruits"),
-			"vegetables": db.Collection("vegetables"),
-			"greens":     db.Collection("greens"),
-		},
+		DB:         db,
+		Router:     router,
+		names:      crud.NewNameIndex(),
+		blobs:      blobs,
+		auth:       authentication,
+		audit:      audit,
+		auditor:    crud.NewAuditor(audit),
+		revisions:  revisions,
+		revisioner: crud.NewRevisioner(revisions),
+
+		trashRetention: retention,
 	}
 
-	router := mux.NewRouter()
+	router.Use(requestID, app.authenticate)
+
+	// o armazenamento local é servido pela própria API
+	if local, ok := blobs.(*storage.LocalStore); ok {
+		router.PathPrefix(local.Prefix()).Handler(http.StripPrefix(local.Prefix(), local)).Methods("GET", "HEAD")
+	}
 
 	// Criando rotas
-	router.HandleFunc("/api/fruits", app.createFruitHandler).Methods("POST")
-	router.HandleFunc("/api/fruits/{id}", app.readFruitHandler).Methods("GET")
-	router.HandleFunc("/api/fruits/{id}", app.updateFruitHandler).Methods("PUT")
-	router.HandleFunc("/api/fruits/{id}", app.deleteFruitHandler).Methods("DELETE")
-	router.HandleFunc("/api/fruits", app.GetAllFruitsHandler).Methods("GET")
-
-	router.HandleFunc("/api/vegetables", app.createVegetableHandler).Methods("POST")
-	router.HandleFunc("/api/vegetables/{id}", app.readVegetableHandler).Methods("GET")
-	router.HandleFunc("/api/vegetables/{id}", app.updateVegetableHandler).Methods("PUT")
-	router.HandleFunc("/api/vegetables/{id}", app.deleteVegetableHandler).Methods("DELETE")
-	router.HandleFunc("/api/vegetables", app.GetAllVegetablesHandler).Methods("GET")
-
-	router.HandleFunc("/api/greens", app.createGreenHandler).Methods("POST")
-	router.HandleFunc("/api/greens/{id}", app.readGreenHandler).Methods("GET")
-	router.HandleFunc("/api/greens/{id}", app.updateGreenHandler).Methods("PUT")
-	router.HandleFunc("/api/greens/{id}", app.deleteGreenHandler).Methods("DELETE")
-	router.HandleFunc("/api/greens", app.GetAllGreensHandler).Methods("GET")
+	registerResource[crud.Fruit](app, "fruits")
+	registerResource[crud.Vegetable](app, "vegetables")
+	registerResource[crud.Green](app, "greens")
+
+	router.HandleFunc("/api/me", app.me).Methods("GET")
+	router.HandleFunc("/api/audit", app.auditLog).Methods("GET")
+	router.HandleFunc("/api/trash", app.listTrash).Methods("GET")
+
+	// as chaves de API só existem com a autenticação ligada
+	if app.auth != nil {
+		router.HandleFunc("/api/keys", app.createKey).Methods("POST")
+		router.HandleFunc("/api/keys", app.listKeys).Methods("GET")
+		router.HandleFunc("/api/keys/{id}", app.revokeKey).Methods("DELETE")
+	}
+
+	// as contas de usuário exigem JWT_SECRET para emitir os tokens de acesso
+	if app.auth != nil && app.auth.verifier.CanSign() {
+		app.accounts, err = newAccounts(db)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		router.HandleFunc("/api/auth/register", app.register).Methods("POST")
+		router.HandleFunc("/api/auth/login", app.login).Methods("POST")
+		router.HandleFunc("/api/auth/refresh", app.refresh).Methods("POST")
+		router.HandleFunc("/api/auth/logout", app.logout).Methods("POST")
+		router.HandleFunc("/api/auth/password/forgot", app.forgotPassword).Methods("POST")
+		router.HandleFunc("/api/auth/password/reset", app.resetPassword).Methods("POST")
+		router.HandleFunc("/api/users", app.listUsers).Methods("GET")
+		router.HandleFunc("/api/users/{id}/roles", app.setRoles).Methods("PUT")
+	}
+
+	router.HandleFunc("/api/search", app.search).Methods("GET")
+	router.HandleFunc("/api/autocomplete", app.autocomplete).Methods("GET")
+	router.HandleFunc("/api/lookup", app.lookupName).Methods("GET")
+	router.HandleFunc("/api/taxonomy/duplicates", app.duplicates).Methods("GET")
+
+	for _, c := range app.categories {
+		if err := c.indexNames(context.Background(), app.names); err != nil {
+			log.Printf("Erro ao indexar os nomes de %s: %v", c.name(), err)
+		}
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	if app.trashRetention > 0 {
+		every(ctx, interval, app.purgeTrash)
+	}
+
+	every(ctx, publishInterval, app.publishScheduled)
+	every(ctx, auditRetryInterval, app.retryAudit)
+	every(ctx, revisionRetryInterval, app.retryRevisions)
 
 	srv := &http.Server{
-		Handler:      handlers.CORS()(router),
+		Handler: handlers.CORS(
+			handlers.AllowedMethods([]string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}),
+			handlers.AllowedHeaders([]string{"Content-Type", "If-Match", "If-None-Match", "Authorization", apiKeyHeader, requestIDHeader}),
+			handlers.ExposedHeaders([]string{"ETag", "Link", "WWW-Authenticate", requestIDHeader}),
+		)(router),
 		Addr:         ":" + os.Getenv("PORT"),
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
@@ -91,4 +206,235 @@ func main() {
 
 	log.Println("Server stopped.")
 
-}
\ No newline at end of file
+}
+
+// newAuthConfig - configura a autenticação JWT a partir das variáveis de ambiente
+//
+// AUTH=disabled desliga a autenticação (somente para desenvolvimento);
+// AUTH_PUBLIC_READS=false exige o token também nas leituras; JWT_WRITE_SCOPE
+// define o escopo exigido nas escritas.
+func newAuthConfig(db *database.Database) (*authConfig, error) {
+	if os.Getenv("AUTH") == "disabled" {
+		log.Println("Autenticação desligada (AUTH=disabled)")
+		return nil, nil
+	}
+
+	verifier, err := auth.NewVerifier(auth.ConfigFromEnv())
+	if err != nil {
+		return nil, err
+	}
+
+	var keys auth.KeyStore = auth.NewMemoryKeyStore()
+
+	if db != nil {
+		keys, err = auth.NewMongoKeyStore(context.Background(), db.Collection("api_keys"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &authConfig{
+		verifier:    verifier,
+		publicReads: os.Getenv("AUTH_PUBLIC_READS") != "false",
+		writeScope:  os.Getenv("JWT_WRITE_SCOPE"),
+		policy:      auth.DefaultPolicy(),
+		keys:        keys,
+	}, nil
+}
+
+// publishInterval - frequência da verificação das publicações agendadas
+const publishInterval = time.Minute
+
+// every - executa fn agora e a cada intervalo, em segundo plano, até o contexto ser cancelado
+func every(ctx context.Context, interval time.Duration, fn func(ctx context.Context)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			fn(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// trashConfig - retenção da lixeira e intervalo da limpeza, no formato de time.ParseDuration
+//
+// TRASH_RETENTION (padrão 720h, 30 dias) define por quanto tempo os documentos
+// removidos podem ser restaurados; 0 mantém a lixeira sem limpeza.
+// TRASH_PURGE_INTERVAL (padrão 1h) define a frequência da limpeza.
+func trashConfig() (retention, interval time.Duration, err error) {
+	retention, interval = 30*24*time.Hour, time.Hour
+
+	if value := os.Getenv("TRASH_RETENTION"); value != "" {
+		retention, err = time.ParseDuration(value)
+		if err != nil || retention < 0 {
+			return 0, 0, fmt.Errorf("TRASH_RETENTION inválido: %q", value)
+		}
+	}
+
+	if value := os.Getenv("TRASH_PURGE_INTERVAL"); value != "" {
+		interval, err = time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			return 0, 0, fmt.Errorf("TRASH_PURGE_INTERVAL inválido: %q", value)
+		}
+	}
+
+	return retention, interval, nil
+}
+
+// newAccounts - cria o armazenamento dos usuários e o envio de e-mails
+func newAccounts(db *database.Database) (*accounts, error) {
+	mailer, err := mail.NewFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	var users auth.UserStore = auth.NewMemoryUserStore()
+
+	if db != nil {
+		users, err = auth.NewMongoUserStore(context.Background(), db.Collection("users"), db.Collection("tokens"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &accounts{
+		users:    users,
+		mailer:   mailer,
+		resetURL: os.Getenv("PASSWORD_RESET_URL"),
+	}, nil
+}
+
+// registerResource - registra as rotas de CRUD de uma categoria de plantas
+func registerResource[T any, PT crud.DocumentPtr[T]](app *App, category string) {
+	res := &resource[T, PT]{
+		category:  category,
+		store:     newRepository[T, PT](app, category),
+		blobs:     app.blobs,
+		auth:      app.auth,
+		revisions: app.revisions,
+	}
+
+	app.categories = append(app.categories, res)
+
+	path := "/api/" + category
+
+	app.Router.HandleFunc(path, res.create).Methods("POST")
+	app.Router.HandleFunc(path+"/{id}", res.read).Methods("GET")
+	app.Router.HandleFunc(path+"/{id}", res.update).Methods("PUT")
+	app.Router.HandleFunc(path+"/{id}", res.patch).Methods("PATCH")
+	app.Router.HandleFunc(path+"/{id}", res.delete).Methods("DELETE")
+	app.Router.HandleFunc(path, res.list).Methods("GET")
+	app.Router.HandleFunc(path+"/import", res.importDocuments).Methods("POST")
+	app.Router.HandleFunc(path+"/translations/missing", res.missingTranslations).Methods("GET")
+	app.Router.HandleFunc(path+"/{id}/translations", res.translations).Methods("GET")
+	app.Router.HandleFunc(path+"/{id}/restore", res.restore).Methods("POST")
+	app.Router.HandleFunc(path+"/{id}/status", res.setStatus).Methods("PUT")
+	app.Router.HandleFunc(path+"/{id}/approve", res.approve).Methods("POST")
+	app.Router.HandleFunc(path+"/{id}/reject", res.reject).Methods("POST")
+	app.Router.HandleFunc(path+"/{id}/revisions", res.listRevisions).Methods("GET")
+	app.Router.HandleFunc(path+"/{id}/revisions/diff", res.diffRevisions).Methods("GET")
+	app.Router.HandleFunc(path+"/{id}/revisions/{rev:[0-9]+}", res.readRevision).Methods("GET")
+	app.Router.HandleFunc(path+"/{id}/revisions/{rev:[0-9]+}/restore", res.restoreRevision).Methods("POST")
+	app.Router.HandleFunc(path+"/{id}/images", res.uploadImage).Methods("POST")
+	app.Router.HandleFunc(path+"/{id}/images/order", res.reorderImages).Methods("PUT")
+	app.Router.HandleFunc(path+"/{id}/images/{image}", res.updateImage).Methods("PATCH")
+	app.Router.HandleFunc(path+"/{id}/images/{image}", res.deleteImage).Methods("DELETE")
+	app.Router.HandleFunc(path+"/{id}/images/{image}/cover", res.setCover).Methods("PUT")
+}
+
+// plantCategories - coleções das categorias registradas em main, usadas pela migração
+var plantCategories = []string{"fruits", "vegetables", "greens"}
+
+// migrate - converte os campos agronômicos legados e as imagens de todas as categorias
+func migrate(db *database.Database) error {
+	if db == nil {
+		return errors.New("A migração exige o MongoDB; remova STORAGE=memory")
+	}
+
+	ctx := context.Background()
+
+	for _, name := range plantCategories {
+		report, err := crud.MigrateAgronomy(ctx, db.Collection(name))
+		if err != nil {
+			return fmt.Errorf("Erro ao migrar %s: %w", name, err)
+		}
+
+		log.Printf("%s: %d documentos migrados, %d com textos para revisão", name, report.Migrated, len(report.Flagged))
+		for id, fields := range report.Flagged {
+			log.Printf("  %s: %s", id, strings.Join(fields, ", "))
+		}
+
+		images, err := crud.MigrateImages(ctx, db.Collection(name))
+		if err != nil {
+			return fmt.Errorf("Erro ao migrar as imagens de %s: %w", name, err)
+		}
+
+		log.Printf("%s: %d documentos com imagens migradas", name, images)
+	}
+
+	return nil
+}
+
+// grantAdmin - acrescenta o papel de administrador ao usuário do e-mail informado
+//
+// É o único meio de criar o primeiro administrador: quem tem acesso ao servidor e
+// ao banco decide, e não quem se cadastrar primeiro com um determinado e-mail.
+func grantAdmin(db *database.Database, args []string) error {
+	if len(args) != 1 {
+		return errors.New("Uso: grant-admin <e-mail>")
+	}
+
+	if db == nil {
+		return errors.New("O comando exige o MongoDB; remova STORAGE=memory")
+	}
+
+	ctx := context.Background()
+
+	users, err := auth.NewMongoUserStore(ctx, db.Collection("users"), db.Collection("tokens"))
+	if err != nil {
+		return fmt.Errorf("Erro ao abrir o cadastro de usuários: %w", err)
+	}
+
+	user, err := users.UserByEmail(ctx, args[0])
+	if err != nil {
+		return fmt.Errorf("Erro ao buscar o usuário %s: %w", args[0], err)
+	}
+
+	if contains(user.Roles, auth.RoleAdmin) {
+		log.Printf("%s já é administrador", user.Email)
+		return nil
+	}
+
+	if _, err := users.SetRoles(ctx, user.ID, append(user.Roles, auth.RoleAdmin)); err != nil {
+		return fmt.Errorf("Erro ao definir os papéis de %s: %w", user.Email, err)
+	}
+
+	log.Printf("%s agora é administrador; o papel vale a partir da próxima renovação do token", user.Email)
+	return nil
+}
+
+// newRepository - cria o repositório da categoria no MongoDB ou em memória, avisando das alterações o índice de nomes, o histórico de auditoria e as revisões
+func newRepository[T any, PT crud.DocumentPtr[T]](app *App, category string) crud.PlantRepository[T] {
+	var repo crud.PreImageRepository[T]
+
+	if app.DB == nil {
+		repo = crud.NewMemoryRepository[T, PT]()
+	} else {
+		mongoRepo := crud.NewMongoRepository[T, PT](app.DB.Collection(category))
+
+		if err := mongoRepo.EnsureIndexes(context.Background()); err != nil {
+			log.Printf("Erro ao criar os índices de %s: %v", category, err)
+		}
+
+		repo = mongoRepo
+	}
+
+	return crud.NewNotifyingRepository[T, PT](repo, category, app.names, app.auditor, app.revisioner)
+}
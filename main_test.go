@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"rastros-da-mata/auth"
+	"rastros-da-mata/crud"
+	"testing"
+)
+
+// testServer - API completa em memória, com a autenticação HS256 ligada
+type testServer struct {
+	t   *testing.T
+	app *App
+	srv *httptest.Server
+}
+
+// newTestServer - sobe a API com os repositórios em memória, como STORAGE=memory
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	verifier, err := auth.NewVerifier(auth.Config{Secret: "s3cret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := mux.NewRouter()
+	router.NotFoundHandler = http.HandlerFunc(notFoundHandler)
+	router.MethodNotAllowedHandler = http.HandlerFunc(methodNotAllowedHandler)
+
+	audit := crud.NewMemoryAuditLog()
+	revisions := crud.NewMemoryRevisionStore()
+
+	app := &App{
+		Router: router,
+		names:  crud.NewNameIndex(),
+		auth: &authConfig{
+			verifier:    verifier,
+			publicReads: true,
+			policy:      auth.DefaultPolicy(),
+			keys:        auth.NewMemoryKeyStore(),
+		},
+		audit:      audit,
+		auditor:    crud.NewAuditor(audit),
+		revisions:  revisions,
+		revisioner: crud.NewRevisioner(revisions),
+	}
+
+	router.Use(requestID, app.authenticate)
+	registerResource[crud.Fruit](app, "fruits")
+
+	s := &testServer{t: t, app: app, srv: httptest.NewServer(router)}
+	t.Cleanup(s.srv.Close)
+	return s
+}
+
+// token - token de acesso do usuário com os papéis informados
+func (s *testServer) token(subject string, roles ...string) string {
+	s.t.Helper()
+
+	token, err := s.app.auth.verifier.Sign(auth.Claims{Subject: subject, Roles: roles})
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return token
+}
+
+// do - envia a requisição, com o token quando informado, e devolve a resposta com o corpo lido
+func (s *testServer) do(method, path, token, contentType string, body []byte, headers ...string) (*http.Response, []byte) {
+	s.t.Helper()
+
+	req, err := http.NewRequest(method, s.srv.URL+path, bytes.NewReader(body))
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	resp, err := s.srv.Client().Do(req)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		s.t.Fatal(err)
+	}
+	return resp, buf.Bytes()
+}
+
+// json - envia o corpo como JSON e decodifica a resposta em um mapa
+func (s *testServer) json(method, path, token string, body interface{}, headers ...string) (int, map[string]interface{}) {
+	s.t.Helper()
+
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			s.t.Fatal(err)
+		}
+	}
+
+	resp, raw := s.do(method, path, token, "application/json", data, headers...)
+
+	var out map[string]interface{}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &out); err != nil {
+			s.t.Fatalf("%s %s: resposta %d não é JSON: %s", method, path, resp.StatusCode, raw)
+		}
+	}
+	return resp.StatusCode, out
+}
+
+// create - cadastra a fruta como o usuário do token e devolve o ID
+func (s *testServer) create(token string, doc map[string]interface{}) string {
+	s.t.Helper()
+
+	status, body := s.json("POST", "/api/fruits", token, doc)
+	if status != http.StatusCreated {
+		s.t.Fatalf("POST /api/fruits = %d: %v", status, body)
+	}
+	return body["id"].(string)
+}
+
+// expectStatus - falha o teste quando o status da resposta não é o esperado
+func expectStatus(t *testing.T, what string, got, want int, body interface{}) {
+	t.Helper()
+
+	if got != want {
+		t.Fatalf("%s = %d, esperado %d: %v", what, got, want, body)
+	}
+}
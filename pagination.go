@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"net/url"
+	"rastros-da-mata/crud"
+	"strconv"
+	"strings"
+)
+
+// Tamanhos de página da listagem
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// errInvalidCursor - cursor malformado ou gerado para outra ordenação
+var errInvalidCursor = errors.New("cursor inválido")
+
+// cursor - posição de paginação enviada ao cliente como texto opaco
+type cursor struct {
+	// Sort - ordenação pedida quando o cursor foi gerado
+	Sort string `json:"s"`
+	// Key - valores da ordenação no item âncora, terminando pelo _id
+	Key []interface{} `json:"k"`
+	// Before - o cursor aponta para a página anterior à âncora
+	Before bool `json:"b,omitempty"`
+}
+
+// page - envelope das respostas paginadas
+type page struct {
+	Data       interface{} `json:"data"`
+	Total      int64       `json:"total"`
+	Limit      int64       `json:"limit"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	PrevCursor string      `json:"prev_cursor,omitempty"`
+}
+
+// encodeCursor - serializa o cursor em base64 URL-safe
+func encodeCursor(c cursor) (string, error) {
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// decodeCursor - lê o cursor, conferindo a ordenação e restaurando os ObjectIDs da âncora
+func decodeCursor(s, sort string, order []crud.SortField) (cursor, error) {
+	var c cursor
+
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, errInvalidCursor
+	}
+	if err := json.Unmarshal(raw, &c); err != nil || c.Sort != sort || len(c.Key) != len(order) {
+		return c, errInvalidCursor
+	}
+
+	for i, f := range order {
+		if f.Field != "_id" {
+			continue
+		}
+		hex, _ := c.Key[i].(string)
+		id, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			return c, errInvalidCursor
+		}
+		c.Key[i] = id
+	}
+	return c, nil
+}
+
+// pageSize - lê ?limit=, aplicando o padrão e o máximo
+func pageSize(query url.Values) (int64, *queryError) {
+	value := query.Get("limit")
+	if value == "" {
+		return defaultPageSize, nil
+	}
+
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || limit < 1 || limit > maxPageSize {
+		return 0, &queryError{"limit", "O parâmetro 'limit' deve ser um inteiro entre 1 e " + strconv.Itoa(maxPageSize)}
+	}
+	return limit, nil
+}
+
+// pageOffset - lê ?offset=, mantido para clientes que ainda paginam por posição
+func pageOffset(query url.Values) (int64, *queryError) {
+	value := query.Get("offset")
+	if value == "" {
+		return 0, nil
+	}
+	if query.Get("cursor") != "" {
+		return 0, &queryError{"offset", "Os parâmetros 'offset' e 'cursor' não podem ser usados juntos"}
+	}
+
+	offset, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, &queryError{"offset", "Valor inválido para o parâmetro 'offset'"}
+	}
+	return offset, nil
+}
+
+// writeLinks - escreve o cabeçalho Link (RFC 8288) com as páginas first, prev e next
+func writeLinks(w http.ResponseWriter, r *http.Request, p page) {
+	link := func(rel, cursor string) string {
+		query := r.URL.Query()
+		query.Del("offset")
+		query.Del("cursor")
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+		u := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+		return "<" + u.String() + `>; rel="` + rel + `"`
+	}
+
+	links := []string{link("first", "")}
+	if p.PrevCursor != "" {
+		links = append(links, link("prev", p.PrevCursor))
+	}
+	if p.NextCursor != "" {
+		links = append(links, link("next", p.NextCursor))
+	}
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"net/url"
+	"rastros-da-mata/crud"
+	"reflect"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	id := primitive.NewObjectID()
+	order := []crud.SortField{{Field: "name"}, {Field: "_id"}}
+
+	encoded, err := encodeCursor(cursor{Sort: "name", Key: []interface{}{"Pitanga", id.Hex()}, Before: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := decodeCursor(encoded, "name", order)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []interface{}{"Pitanga", id}; !reflect.DeepEqual(c.Key, want) || !c.Before {
+		t.Errorf("cursor = %+v, esperado a chave %v antes da âncora", c, want)
+	}
+}
+
+func TestCursorInvalid(t *testing.T) {
+	order := []crud.SortField{{Field: "name"}, {Field: "_id"}}
+
+	valid := func(c cursor) string {
+		encoded, err := encodeCursor(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return encoded
+	}
+
+	tests := map[string]string{
+		"base64 inválido":   "!!",
+		"JSON inválido":     "bm90LWpzb24",
+		"outra ordenação":   valid(cursor{Sort: "-name", Key: []interface{}{"Pitanga", primitive.NewObjectID().Hex()}}),
+		"chave incompleta":  valid(cursor{Sort: "name", Key: []interface{}{"Pitanga"}}),
+		"ObjectID inválido": valid(cursor{Sort: "name", Key: []interface{}{"Pitanga", "123"}}),
+	}
+
+	for name, encoded := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := decodeCursor(encoded, "name", order); !errors.Is(err, errInvalidCursor) {
+				t.Errorf("decodeCursor = %v, esperado errInvalidCursor", err)
+			}
+		})
+	}
+}
+
+func TestListCursorPages(t *testing.T) {
+	s := newTestServer(t)
+
+	reviewer := s.token("rui", "reviewer")
+
+	names := []string{"Acerola", "Banana", "Caju", "Goiaba", "Pitanga"}
+	for _, name := range []string{"Goiaba", "Acerola", "Pitanga", "Caju", "Banana"} {
+		s.create(reviewer, map[string]interface{}{"name": name})
+	}
+
+	// listPage - nomes da página e os cursores da anterior e da seguinte
+	listPage := func(cursor string) (page []string, prev, next string) {
+		query := url.Values{"sort": {"name"}, "limit": {"2"}}
+		if cursor != "" {
+			query.Set("cursor", cursor)
+		}
+
+		status, body := s.json("GET", "/api/fruits?"+query.Encode(), reviewer, nil)
+		expectStatus(t, "GET /api/fruits", status, http.StatusOK, body)
+
+		for _, doc := range body["data"].([]interface{}) {
+			page = append(page, doc.(map[string]interface{})["name"].(string))
+		}
+		prev, _ = body["prev_cursor"].(string)
+		next, _ = body["next_cursor"].(string)
+		return page, prev, next
+	}
+
+	var seen []string
+	var prevs []string
+
+	page, prev, next := listPage("")
+	seen = append(seen, page...)
+
+	if prev != "" {
+		t.Errorf("a primeira página não deveria ter prev_cursor: %q", prev)
+	}
+
+	for next != "" {
+		page, prev, next = listPage(next)
+		seen = append(seen, page...)
+		prevs = append(prevs, prev)
+	}
+
+	if !reflect.DeepEqual(seen, names) {
+		t.Fatalf("páginas = %v, esperado %v", seen, names)
+	}
+
+	// o prev_cursor da última página volta para a anterior
+	page, _, _ = listPage(prevs[len(prevs)-1])
+
+	if want := []string{"Caju", "Goiaba"}; !reflect.DeepEqual(page, want) {
+		t.Errorf("página anterior = %v, esperado %v", page, want)
+	}
+
+	status, body := s.json("GET", "/api/fruits?sort=-name&cursor="+prevs[0], reviewer, nil)
+	expectStatus(t, "cursor de outra ordenação", status, http.StatusBadRequest, body)
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Tipos de mídia aceitos pelo PATCH
+const (
+	mediaMergePatch = "application/merge-patch+json"
+	mediaJSONPatch  = "application/json-patch+json"
+)
+
+// errPatch - o documento de patch é inválido ou não pode ser aplicado
+var errPatch = errors.New("patch inválido")
+
+// mergePatch - aplica um JSON Merge Patch (RFC 7396) e retorna os campos de primeiro nível alterados
+func mergePatch(doc map[string]interface{}, body []byte) ([]string, error) {
+	var patch interface{}
+	if err := json.Unmarshal(body, &patch); err != nil {
+		return nil, fmt.Errorf("%w: %v", errPatch, err)
+	}
+
+	obj, ok := patch.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: o merge patch deve ser um objeto JSON", errPatch)
+	}
+
+	touched := make([]string, 0, len(obj))
+	for key, value := range obj {
+		touched = append(touched, key)
+		if value == nil {
+			delete(doc, key)
+			continue
+		}
+		doc[key] = mergeValue(doc[key], value)
+	}
+
+	return touched, nil
+}
+
+// mergeValue - combina recursivamente o valor atual com o valor do patch
+func mergeValue(target, patch interface{}) interface{} {
+	obj, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	current, ok := target.(map[string]interface{})
+	if !ok {
+		current = map[string]interface{}{}
+	}
+
+	for key, value := range obj {
+		if value == nil {
+			delete(current, key)
+			continue
+		}
+		current[key] = mergeValue(current[key], value)
+	}
+
+	return current
+}
+
+// jsonPatchOp - operação de um JSON Patch (RFC 6902); Value fica vazio quando o membro
+// falta, enquanto o null do JSON chega como o texto "null"
+type jsonPatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// jsonPatch - aplica um JSON Patch (RFC 6902) e retorna os campos de primeiro nível alterados
+func jsonPatch(doc map[string]interface{}, body []byte) ([]string, error) {
+	var ops []jsonPatchOp
+	if err := json.Unmarshal(body, &ops); err != nil {
+		return nil, fmt.Errorf("%w: %v", errPatch, err)
+	}
+
+	var root interface{} = doc
+	var touched []string
+
+	for i, op := range ops {
+		path, err := parsePointer(op.Path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: operação %d: %v", errPatch, i, err)
+		}
+		if len(path) == 0 {
+			return nil, fmt.Errorf("%w: operação %d: o documento inteiro não pode ser substituído", errPatch, i)
+		}
+
+		var value interface{}
+		if op.Value != nil {
+			if err := json.Unmarshal(op.Value, &value); err != nil {
+				return nil, fmt.Errorf("%w: operação %d: %v", errPatch, i, err)
+			}
+		}
+
+		// add, replace e test exigem o membro value (RFC 6902, seções 4.1, 4.3 e 4.6)
+		if op.Value == nil && (op.Op == "add" || op.Op == "replace" || op.Op == "test") {
+			return nil, fmt.Errorf("%w: operação %d: 'value' é obrigatório", errPatch, i)
+		}
+
+		switch op.Op {
+		case "add":
+			root, err = pointerAdd(root, path, value)
+		case "remove":
+			root, _, err = pointerRemove(root, path)
+		case "replace":
+			if root, _, err = pointerRemove(root, path); err == nil {
+				root, err = pointerAdd(root, path, value)
+			}
+		case "move", "copy":
+			var from []string
+			if from, err = parsePointer(op.From); err != nil {
+				break
+			}
+			// move e copy exigem o membro from (RFC 6902, seções 4.4 e 4.5)
+			if len(from) == 0 {
+				err = errors.New("'from' é obrigatório e não pode ser o documento inteiro")
+				break
+			}
+			// um valor não pode ser movido para dentro de si mesmo (RFC 6902, seção 4.4)
+			if op.Op == "move" && len(path) > len(from) && reflect.DeepEqual(path[:len(from)], from) {
+				err = fmt.Errorf("%q não pode ser movido para dentro de si mesmo", op.From)
+				break
+			}
+			var moved interface{}
+			if op.Op == "move" {
+				root, moved, err = pointerRemove(root, from)
+				if len(from) > 0 {
+					touched = append(touched, from[0])
+				}
+			} else {
+				moved, err = pointerGet(root, from)
+				moved = cloneJSON(moved)
+			}
+			if err == nil {
+				root, err = pointerAdd(root, path, moved)
+			}
+		case "test":
+			var current interface{}
+			if current, err = pointerGet(root, path); err == nil && !reflect.DeepEqual(current, value) {
+				err = fmt.Errorf("o valor em %q não corresponde ao esperado", op.Path)
+			}
+		default:
+			err = fmt.Errorf("operação desconhecida %q", op.Op)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: operação %d: %v", errPatch, i, err)
+		}
+		if op.Op != "test" {
+			touched = append(touched, path[0])
+		}
+	}
+
+	return touched, nil
+}
+
+// parsePointer - decodifica um JSON Pointer (RFC 6901) em seus segmentos
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("caminho %q deve começar com '/'", pointer)
+	}
+
+	parts := strings.Split(pointer[1:], "/")
+	for i, part := range parts {
+		parts[i] = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+	}
+	return parts, nil
+}
+
+// pointerGet - retorna o valor no caminho
+func pointerGet(node interface{}, path []string) (interface{}, error) {
+	for _, key := range path {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			v, ok := n[key]
+			if !ok {
+				return nil, fmt.Errorf("caminho %q não existe", key)
+			}
+			node = v
+		case []interface{}:
+			i, err := arrayIndex(key, len(n)-1)
+			if err != nil {
+				return nil, err
+			}
+			node = n[i]
+		default:
+			return nil, fmt.Errorf("caminho %q não existe", key)
+		}
+	}
+	return node, nil
+}
+
+// pointerAdd - insere o valor no caminho e retorna o nó atualizado
+func pointerAdd(node interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	key := path[0]
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			n[key] = value
+			return n, nil
+		}
+		child, ok := n[key]
+		if !ok {
+			return nil, fmt.Errorf("caminho %q não existe", key)
+		}
+		updated, err := pointerAdd(child, path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		n[key] = updated
+		return n, nil
+	case []interface{}:
+		if len(path) == 1 {
+			if key == "-" {
+				return append(n, value), nil
+			}
+			i, err := arrayIndex(key, len(n))
+			if err != nil {
+				return nil, err
+			}
+			n = append(n, nil)
+			copy(n[i+1:], n[i:])
+			n[i] = value
+			return n, nil
+		}
+		i, err := arrayIndex(key, len(n)-1)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := pointerAdd(n[i], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		n[i] = updated
+		return n, nil
+	default:
+		return nil, fmt.Errorf("caminho %q não existe", key)
+	}
+}
+
+// pointerRemove - remove o valor no caminho e retorna o nó atualizado e o valor removido
+func pointerRemove(node interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, errors.New("o documento inteiro não pode ser removido")
+	}
+
+	key := path[0]
+	switch n := node.(type) {
+	case map[string]interface{}:
+		child, ok := n[key]
+		if !ok {
+			return nil, nil, fmt.Errorf("caminho %q não existe", key)
+		}
+		if len(path) == 1 {
+			delete(n, key)
+			return n, child, nil
+		}
+		updated, removed, err := pointerRemove(child, path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		n[key] = updated
+		return n, removed, nil
+	case []interface{}:
+		i, err := arrayIndex(key, len(n)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(path) == 1 {
+			removed := n[i]
+			return append(n[:i], n[i+1:]...), removed, nil
+		}
+		updated, removed, err := pointerRemove(n[i], path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+		n[i] = updated
+		return n, removed, nil
+	default:
+		return nil, nil, fmt.Errorf("caminho %q não existe", key)
+	}
+}
+
+// arrayIndex - converte o segmento em índice de array, limitado a max
+func arrayIndex(key string, max int) (int, error) {
+	i, err := strconv.Atoi(key)
+	if err != nil || i < 0 || i > max || (len(key) > 1 && key[0] == '0') {
+		return 0, fmt.Errorf("índice %q inválido", key)
+	}
+	return i, nil
+}
+
+// cloneJSON - copia profundamente um valor decodificado de JSON
+func cloneJSON(v interface{}) interface{} {
+	switch n := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(n))
+		for k, e := range n {
+			c[k] = cloneJSON(e)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(n))
+		for i, e := range n {
+			c[i] = cloneJSON(e)
+		}
+		return c
+	default:
+		return v
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// decodeDoc - documento JSON de teste
+func decodeDoc(t *testing.T, raw string) map[string]interface{} {
+	t.Helper()
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		patch   string
+		want    string
+		touched []string
+	}{
+		{
+			name:    "substitui e acrescenta",
+			doc:     `{"name":"Pitanga","description":"azeda"}`,
+			patch:   `{"description":"doce","planting":"sementes"}`,
+			want:    `{"name":"Pitanga","description":"doce","planting":"sementes"}`,
+			touched: []string{"description", "planting"},
+		},
+		{
+			name:    "null remove",
+			doc:     `{"name":"Pitanga","description":"azeda"}`,
+			patch:   `{"description":null}`,
+			want:    `{"name":"Pitanga"}`,
+			touched: []string{"description"},
+		},
+		{
+			name:    "objetos são combinados",
+			doc:     `{"ideal_temperature":{"min_celsius":18,"max_celsius":30}}`,
+			patch:   `{"ideal_temperature":{"max_celsius":32}}`,
+			want:    `{"ideal_temperature":{"min_celsius":18,"max_celsius":32}}`,
+			touched: []string{"ideal_temperature"},
+		},
+		{
+			name:    "null remove campo aninhado",
+			doc:     `{"taxonomy":{"genus":"Eugenia","species":"uniflora"}}`,
+			patch:   `{"taxonomy":{"species":null}}`,
+			want:    `{"taxonomy":{"genus":"Eugenia"}}`,
+			touched: []string{"taxonomy"},
+		},
+		{
+			name:    "listas são substituídas",
+			doc:     `{"harvest_months":[1,2,3]}`,
+			patch:   `{"harvest_months":[10]}`,
+			want:    `{"harvest_months":[10]}`,
+			touched: []string{"harvest_months"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := decodeDoc(t, tt.doc)
+
+			touched, err := mergePatch(doc, []byte(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want := decodeDoc(t, tt.want); !reflect.DeepEqual(doc, want) {
+				t.Errorf("documento = %v, esperado %v", doc, want)
+			}
+
+			sort.Strings(touched)
+			if !reflect.DeepEqual(touched, tt.touched) {
+				t.Errorf("campos = %v, esperado %v", touched, tt.touched)
+			}
+		})
+	}
+}
+
+func TestMergePatchNotObject(t *testing.T) {
+	for _, patch := range []string{`[]`, `"texto"`, `{`} {
+		if _, err := mergePatch(map[string]interface{}{}, []byte(patch)); !errors.Is(err, errPatch) {
+			t.Errorf("mergePatch(%s) = %v, esperado errPatch", patch, err)
+		}
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		patch   string
+		want    string
+		touched []string
+	}{
+		{
+			name:    "add, replace e remove",
+			doc:     `{"name":"Pitanga","description":"azeda","planting":"x"}`,
+			patch:   `[{"op":"replace","path":"/description","value":"doce"},{"op":"add","path":"/observation","value":"nativa"},{"op":"remove","path":"/planting"}]`,
+			want:    `{"name":"Pitanga","description":"doce","observation":"nativa"}`,
+			touched: []string{"description", "observation", "planting"},
+		},
+		{
+			name:    "add no fim e no meio da lista",
+			doc:     `{"harvest_months":[1,3]}`,
+			patch:   `[{"op":"add","path":"/harvest_months/-","value":4},{"op":"add","path":"/harvest_months/1","value":2}]`,
+			want:    `{"harvest_months":[1,2,3,4]}`,
+			touched: []string{"harvest_months", "harvest_months"},
+		},
+		{
+			name:    "add com null",
+			doc:     `{"name":"Pitanga"}`,
+			patch:   `[{"op":"add","path":"/description","value":null}]`,
+			want:    `{"name":"Pitanga","description":null}`,
+			touched: []string{"description"},
+		},
+		{
+			name:    "move e copy",
+			doc:     `{"description":"doce","taxonomy":{"genus":"Eugenia"}}`,
+			patch:   `[{"op":"move","from":"/description","path":"/observation"},{"op":"copy","from":"/taxonomy/genus","path":"/extra_info"}]`,
+			want:    `{"observation":"doce","extra_info":"Eugenia","taxonomy":{"genus":"Eugenia"}}`,
+			touched: []string{"description", "extra_info", "observation"},
+		},
+		{
+			name:    "test que confere",
+			doc:     `{"name":"Pitanga","taxonomy":{"a/b":1,"c~d":2}}`,
+			patch:   `[{"op":"test","path":"/taxonomy/a~1b","value":1},{"op":"test","path":"/taxonomy/c~0d","value":2}]`,
+			want:    `{"name":"Pitanga","taxonomy":{"a/b":1,"c~d":2}}`,
+			touched: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := decodeDoc(t, tt.doc)
+
+			touched, err := jsonPatch(doc, []byte(tt.patch))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want := decodeDoc(t, tt.want); !reflect.DeepEqual(doc, want) {
+				t.Errorf("documento = %v, esperado %v", doc, want)
+			}
+
+			sort.Strings(touched)
+			if !reflect.DeepEqual(touched, tt.touched) {
+				t.Errorf("campos = %v, esperado %v", touched, tt.touched)
+			}
+		})
+	}
+}
+
+func TestJSONPatchInvalid(t *testing.T) {
+	// sem a exigência de value, o test do campo null observation seria aceito
+	tests := map[string]string{
+		"test sem value":        `[{"op":"test","path":"/observation"}]`,
+		"add sem value":         `[{"op":"add","path":"/description"}]`,
+		"replace sem value":     `[{"op":"replace","path":"/name"}]`,
+		"test que não confere":  `[{"op":"test","path":"/name","value":"Acerola"}]`,
+		"caminho inexistente":   `[{"op":"remove","path":"/description"}]`,
+		"documento inteiro":     `[{"op":"replace","path":"","value":{}}]`,
+		"ponteiro sem barra":    `[{"op":"add","path":"name","value":"x"}]`,
+		"operação desconhecida": `[{"op":"merge","path":"/name","value":"x"}]`,
+		"índice fora da lista":  `[{"op":"add","path":"/harvest_months/5","value":1}]`,
+		"não é lista":           `{"op":"add"}`,
+		"move sem from":         `[{"op":"move","path":"/observation"}]`,
+		"copy sem from":         `[{"op":"copy","path":"/observation"}]`,
+		"move do documento":     `[{"op":"move","from":"","path":"/observation"}]`,
+		"move para dentro":      `[{"op":"move","from":"/taxonomy","path":"/taxonomy/genus"}]`,
+	}
+
+	for name, patch := range tests {
+		t.Run(name, func(t *testing.T) {
+			doc := decodeDoc(t, `{"name":"Pitanga","observation":null,"harvest_months":[1],"taxonomy":{"genus":"Eugenia"}}`)
+
+			if _, err := jsonPatch(doc, []byte(patch)); !errors.Is(err, errPatch) {
+				t.Errorf("jsonPatch = %v, esperado errPatch", err)
+			}
+		})
+	}
+}
+
+func TestPatchHandler(t *testing.T) {
+	s := newTestServer(t)
+
+	ana := s.token("ana", "editor")
+	id := s.create(ana, map[string]interface{}{"name": "Pitanga", "description": "azeda"})
+	path := "/api/fruits/" + id
+
+	status, body := s.json("PATCH", path, ana, []map[string]interface{}{{"op": "test", "path": "/name"}}, "Content-Type", mediaJSONPatch)
+	expectStatus(t, "test sem value", status, http.StatusBadRequest, body)
+
+	status, body = s.json("PATCH", path, ana, []map[string]interface{}{{"op": "replace", "path": "/status", "value": "published"}}, "Content-Type", mediaJSONPatch)
+	expectStatus(t, "replace de status", status, http.StatusUnprocessableEntity, body)
+
+	status, body = s.json("PATCH", path, ana, []map[string]interface{}{
+		{"op": "test", "path": "/name", "value": "Pitanga"},
+		{"op": "replace", "path": "/description", "value": "doce"},
+	}, "Content-Type", mediaJSONPatch)
+	expectStatus(t, "test e replace", status, http.StatusOK, body)
+
+	if body["description"] != "doce" {
+		t.Errorf("description = %v, esperado doce", body["description"])
+	}
+
+	status, body = s.json("PATCH", path, ana, map[string]interface{}{"description": nil}, "Content-Type", mediaMergePatch, "If-Match", `"1"`)
+	expectStatus(t, "merge patch com versão antiga", status, http.StatusPreconditionFailed, body)
+}
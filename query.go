@@ -0,0 +1,244 @@
+package main
+
+import (
+	"net/url"
+	"rastros-da-mata/crud"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Tipos de valor aceitos nos filtros da listagem
+const (
+	paramString = iota
+	paramInt
+	paramFloat
+)
+
+// filterParam - parâmetro de consulta que vira condição sobre um campo do documento
+type filterParam struct {
+	path    string
+	op      string
+	kind    int
+	allowed []string
+}
+
+// listFilters - parâmetros de filtro permitidos na listagem; nenhum outro campo pode ser consultado
+var listFilters = map[string][]filterParam{
+	"name":          {{path: "name", op: crud.OpIn, kind: paramString}},
+	"sunlight":      {{path: "sunlight.exposure", op: crud.OpIn, kind: paramString, allowed: []string{crud.SunlightFull, crud.SunlightPartial, crud.SunlightShade}}},
+	"irrigation":    {{path: "irrigation.frequency", op: crud.OpIn, kind: paramString, allowed: []string{crud.IrrigationDaily, crud.IrrigationAlternateDays, crud.IrrigationTwiceWeekly, crud.IrrigationWeekly, crud.IrrigationBiweekly, crud.IrrigationMonthly}}},
+	"harvest_month": {{path: "harvest_months", op: crud.OpIn, kind: paramInt}},
+	"family":        {{path: "taxonomy.family", op: crud.OpIn, kind: paramString}},
+	"genus":         {{path: "taxonomy.genus", op: crud.OpIn, kind: paramString}},
+	// status - o público vê apenas os publicados, e os editores também os próprios rascunhos
+	"status": {{path: "status", op: crud.OpIn, kind: paramString, allowed: crud.Statuses}},
+	// max_development_days - plantas prontas em até N dias
+	"max_development_days": {{path: "development_days.max_days", op: crud.OpLte, kind: paramInt}},
+	// temperature - plantas cuja faixa ideal inclui a temperatura informada
+	"temperature": {
+		{path: "ideal_temperature.min_celsius", op: crud.OpLte, kind: paramFloat},
+		{path: "ideal_temperature.max_celsius", op: crud.OpGte, kind: paramFloat},
+	},
+}
+
+// listSorts - campos permitidos em ?sort=, com o caminho correspondente no documento
+var listSorts = map[string]string{
+	"id":               "_id",
+	"name":             "name",
+	"development_days": "development_days.min_days",
+	"temperature":      "ideal_temperature.min_celsius",
+}
+
+// listFields - campos permitidos em ?fields=, com o caminho correspondente no documento
+var listFields = map[string]string{
+	"id":                "_id",
+	"version":           "version",
+	"name":              "name",
+	"description":       "description",
+	"development_days":  "development_days",
+	"ideal_temperature": "ideal_temperature",
+	"harvest_months":    "harvest_months",
+	"sunlight":          "sunlight",
+	"irrigation":        "irrigation",
+	"planting":          "planting",
+	"extra_info":        "extra_info",
+	"observation":       "observation",
+	"image_path":        "image_path",
+	"images":            "images",
+	"translations":      "translations",
+	"taxonomy":          "taxonomy",
+	"common_names":      "common_names",
+	"status":            "status",
+	"publish_at":        "publish_at",
+}
+
+// listParams - parâmetros da listagem que não são filtros
+var listParams = map[string]bool{
+	"limit":  true,
+	"offset": true,
+	"cursor": true,
+	"lang":   true,
+	"sort":   true,
+	"fields": true,
+}
+
+// queryError - parâmetro de consulta inválido
+type queryError struct {
+	param  string
+	detail string
+}
+
+// parseListQuery - traduz filtros, ordenação e projeção da query string para crud.ListOptions
+func parseListQuery(query url.Values) (crud.ListOptions, *queryError) {
+	var opts crud.ListOptions
+
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if listParams[name] {
+			continue
+		}
+
+		params, ok := listFilters[name]
+		if !ok {
+			return opts, &queryError{name, "Parâmetro desconhecido; filtros permitidos: " + strings.Join(keys(listFilters), ", ")}
+		}
+
+		values := splitValues(query[name])
+		for _, param := range params {
+			cond, err := param.condition(name, values)
+			if err != nil {
+				return opts, err
+			}
+			opts.Filter = append(opts.Filter, cond)
+		}
+	}
+
+	sorted := map[string]bool{}
+	for _, field := range splitValues(query["sort"]) {
+		desc := strings.HasPrefix(field, "-")
+		path, ok := listSorts[strings.TrimPrefix(field, "-")]
+		if !ok {
+			return opts, &queryError{"sort", "Campo de ordenação inválido; permitidos: " + strings.Join(keys(listSorts), ", ")}
+		}
+		if sorted[path] {
+			return opts, &queryError{"sort", "Campo de ordenação repetido: " + field}
+		}
+		sorted[path] = true
+		opts.Sort = append(opts.Sort, crud.SortField{Field: path, Desc: desc})
+	}
+
+	for _, field := range splitValues(query["fields"]) {
+		path, ok := listFields[field]
+		if !ok {
+			return opts, &queryError{"fields", "Campo inválido; permitidos: " + strings.Join(keys(listFields), ", ")}
+		}
+		opts.Fields = append(opts.Fields, path)
+	}
+
+	return opts, nil
+}
+
+// condition - converte os valores do parâmetro na condição do filtro
+func (p filterParam) condition(name string, values []string) (crud.Condition, *queryError) {
+	if len(values) == 0 {
+		return crud.Condition{}, &queryError{name, "Informe um valor para o parâmetro '" + name + "'"}
+	}
+	if p.op != crud.OpIn && len(values) > 1 {
+		return crud.Condition{}, &queryError{name, "O parâmetro '" + name + "' aceita um único valor"}
+	}
+
+	parsed := make([]interface{}, len(values))
+	for i, v := range values {
+		switch p.kind {
+		case paramInt:
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return crud.Condition{}, &queryError{name, "O parâmetro '" + name + "' deve ser um número inteiro"}
+			}
+			parsed[i] = n
+		case paramFloat:
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return crud.Condition{}, &queryError{name, "O parâmetro '" + name + "' deve ser um número"}
+			}
+			parsed[i] = n
+		default:
+			if len(p.allowed) > 0 && !contains(p.allowed, v) {
+				return crud.Condition{}, &queryError{name, "Valores permitidos para '" + name + "': " + strings.Join(p.allowed, ", ")}
+			}
+			parsed[i] = v
+		}
+	}
+
+	if p.op == crud.OpIn {
+		return crud.Condition{Field: p.path, Op: p.op, Value: parsed}, nil
+	}
+	return crud.Condition{Field: p.path, Op: p.op, Value: parsed[0]}, nil
+}
+
+// projectFields - mantém somente os campos pedidos em ?fields= (além do id e do idioma) em cada documento
+func projectFields[T any](docs []T, query url.Values) (interface{}, error) {
+	fields := splitValues(query["fields"])
+	if len(fields) == 0 {
+		return docs, nil
+	}
+
+	projected := make([]map[string]interface{}, 0, len(docs))
+	for i := range docs {
+		all, err := toJSONMap(&docs[i])
+		if err != nil {
+			return nil, err
+		}
+
+		doc := map[string]interface{}{"id": all["id"]}
+		if lang, ok := all["language"]; ok {
+			doc["language"] = lang
+		}
+		for _, field := range fields {
+			if v, ok := all[field]; ok {
+				doc[field] = v
+			}
+		}
+		projected = append(projected, doc)
+	}
+	return projected, nil
+}
+
+// splitValues - separa valores repetidos ou separados por vírgula
+func splitValues(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
+
+// keys - chaves do mapa em ordem alfabética
+func keys[V any](m map[string]V) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// contains - indica se o valor está na lista
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
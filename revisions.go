@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"log"
+	"net/http"
+	"rastros-da-mata/auth"
+	"rastros-da-mata/crud"
+	"strconv"
+	"time"
+)
+
+// revisionRetryInterval - frequência das novas tentativas de gravar as revisões pendentes
+const revisionRetryInterval = time.Minute
+
+// retryRevisions - grava as revisões que falharam, alertando enquanto houver pendentes
+func (app *App) retryRevisions(ctx context.Context) {
+	if app.revisioner.Status().Pending == 0 {
+		return
+	}
+
+	n, err := app.revisioner.Retry(ctx)
+
+	if n > 0 {
+		log.Printf("%d revisões pendentes gravadas", n)
+	}
+
+	if err != nil {
+		log.Printf("ALERTA: %d revisões continuam pendentes: %v", app.revisioner.Status().Pending, err)
+	}
+}
+
+// listRevisions - revisões do documento, das mais recentes às mais antigas, sem as cópias completas
+//
+// As revisões incluem os rascunhos, por isso só são vistas por quem edita a categoria.
+func (res *resource[T, PT]) listRevisions(w http.ResponseWriter, r *http.Request) {
+	if !res.auth.allow(w, r, auth.ActionUpdate, res.category) {
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+
+	if err != nil {
+		writeInvalidID(w, r)
+		return
+	}
+
+	if !res.revisionsVisible(w, r, id) {
+		return
+	}
+
+	revs, err := res.revisions.List(r.Context(), res.category, id)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if len(revs) == 0 {
+		writeError(w, r, crud.ErrNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": revs})
+}
+
+// readRevision - revisão com a cópia completa do documento
+func (res *resource[T, PT]) readRevision(w http.ResponseWriter, r *http.Request) {
+	if !res.auth.allow(w, r, auth.ActionUpdate, res.category) {
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+
+	if err != nil {
+		writeInvalidID(w, r)
+		return
+	}
+
+	if !res.revisionsVisible(w, r, id) {
+		return
+	}
+
+	rev, ok := res.findRevision(w, r, id, "rev", mux.Vars(r)["rev"])
+
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rev)
+}
+
+// diffRevisions - campos alterados entre as revisões ?from= e ?to=, em qualquer ordem
+func (res *resource[T, PT]) diffRevisions(w http.ResponseWriter, r *http.Request) {
+	if !res.auth.allow(w, r, auth.ActionUpdate, res.category) {
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+
+	if err != nil {
+		writeInvalidID(w, r)
+		return
+	}
+
+	if !res.revisionsVisible(w, r, id) {
+		return
+	}
+
+	query := r.URL.Query()
+
+	from, ok := res.findRevision(w, r, id, "from", query.Get("from"))
+
+	if !ok {
+		return
+	}
+
+	to, ok := res.findRevision(w, r, id, "to", query.Get("to"))
+
+	if !ok {
+		return
+	}
+
+	changes, err := crud.Diff(from.Snapshot, to.Snapshot)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"from":    from.Number,
+		"to":      to.Number,
+		"changes": changes,
+	})
+}
+
+// restoreRevision - substitui o documento pela cópia da revisão, criando uma nova revisão
+//
+// As imagens não fazem parte da restauração: os arquivos das imagens removidas já
+// foram apagados do armazenamento.
+func (res *resource[T, PT]) restoreRevision(w http.ResponseWriter, r *http.Request) {
+	if !res.auth.allow(w, r, auth.ActionUpdate, res.category) {
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+
+	if err != nil {
+		writeInvalidID(w, r)
+		return
+	}
+
+	rev, ok := res.findRevision(w, r, id, "rev", mux.Vars(r)["rev"])
+
+	if !ok {
+		return
+	}
+
+	var doc T
+
+	// a cópia passa pelo JSON para que só os campos editáveis cheguem ao documento
+	raw, err := json.Marshal(rev.Snapshot)
+
+	if err == nil {
+		err = json.Unmarshal(raw, &doc)
+	}
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := crud.Validate(&doc); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	current, ok := res.readVisible(w, r, id)
+
+	if !ok || !res.canEdit(w, r, PT(current).PlantData()) {
+		return
+	}
+
+	currentVersion := PT(current).PlantData().Version
+
+	version, ok, err := ifMatchVersion(r, func() (int64, error) { return currentVersion, nil })
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if !ok || (version != crud.AnyVersion && version != currentVersion) {
+		writePreconditionFailed(w, r)
+		return
+	}
+
+	patch, err := crud.ReplacePatch(&doc)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	r = r.WithContext(crud.WithRestore(r.Context(), rev.Number))
+	res.writeContent(w, r, id, crud.ContentPatch(patch, PT(current).PlantData()), currentVersion, version)
+}
+
+// revisionsVisible - indica se o token vê as revisões do documento; os revisores veem também
+// as dos documentos na lixeira, e os demais somente as dos documentos que veem (ver visible)
+func (res *resource[T, PT]) revisionsVisible(w http.ResponseWriter, r *http.Request, id primitive.ObjectID) bool {
+	if res.reviewer(r) {
+		return true
+	}
+
+	_, ok := res.readVisible(w, r, id)
+	return ok
+}
+
+// findRevision - revisão de número informado no parâmetro name; escreve o erro e retorna false quando não existir
+func (res *resource[T, PT]) findRevision(w http.ResponseWriter, r *http.Request, id primitive.ObjectID, name, value string) (*crud.Revision, bool) {
+	number, err := strconv.ParseInt(value, 10, 64)
+
+	if err != nil || number < 1 {
+		writeInvalidParameter(w, r, name, fmt.Sprintf("O parâmetro '%s' deve ser o número de uma revisão", name))
+		return nil, false
+	}
+
+	rev, err := res.revisions.Find(r.Context(), res.category, id, number)
+
+	if err != nil {
+		writeError(w, r, err)
+		return nil, false
+	}
+
+	return rev, true
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"rastros-da-mata/crud"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// category - operações de uma categoria usadas pelas rotas que atravessam todas as categorias
+type category interface {
+	name() string
+	search(ctx context.Context, text, lang string, limit int64) ([]searchHit, error)
+	indexNames(ctx context.Context, ix *crud.NameIndex) error
+	lookup(ctx context.Context, name string) ([]*crud.Plant, error)
+	eachPlant(ctx context.Context, filter []crud.Condition, fields []string, fn func(plant *crud.Plant) error) error
+	trash(ctx context.Context, limit int64) ([]trashItem, error)
+	purge(ctx context.Context, before time.Time) (int, error)
+	publishDue(ctx context.Context, now time.Time) (int, error)
+}
+
+// Tamanhos da lista de sugestões do autocompletar
+const (
+	defaultSuggestions = 10
+	maxSuggestions     = 50
+)
+
+// searchHit - resultado da busca, identificando a categoria do documento
+type searchHit struct {
+	Category    string  `json:"category"`
+	Score       float64 `json:"score"`
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description,omitempty"`
+	ImagePath   string  `json:"image_path,omitempty"`
+}
+
+// name - nome da categoria, usado nas rotas e nas coleções
+func (res *resource[T, PT]) name() string {
+	return res.category
+}
+
+// search - busca textual nos documentos publicados da categoria, com os textos no idioma informado
+func (res *resource[T, PT]) search(ctx context.Context, text, lang string, limit int64) ([]searchHit, error) {
+	results, err := res.store.Search(ctx, text, []crud.Condition{publishedFilter}, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	hits := make([]searchHit, 0, len(results))
+	for i := range results {
+		plant := PT(&results[i].Doc).PlantData()
+		plant.Localize(lang)
+		hits = append(hits, searchHit{
+			Category:    res.category,
+			Score:       results[i].Score,
+			ID:          plant.ID.Hex(),
+			Name:        plant.Name,
+			Description: plant.Description,
+			ImagePath:   plant.ImagePath,
+		})
+	}
+	return hits, nil
+}
+
+// indexNames - carrega no índice os nomes dos documentos publicados da categoria
+func (res *resource[T, PT]) indexNames(ctx context.Context, ix *crud.NameIndex) error {
+	return res.each(ctx, []crud.Condition{publishedFilter}, []string{"name"}, func(doc *T) error {
+		plant := PT(doc).PlantData()
+		ix.Put(res.category, plant.ID, plant.Name)
+		return nil
+	})
+}
+
+// search - busca textual em todas as categorias, ordenada pela relevância
+func (app *App) search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	text := strings.TrimSpace(query.Get("q"))
+
+	if len(crud.Tokens(text)) == 0 {
+		writeInvalidParameter(w, r, "q", "Informe o texto da busca no parâmetro 'q'")
+		return
+	}
+
+	limit, qerr := pageSize(query)
+
+	if qerr != nil {
+		writeInvalidParameter(w, r, qerr.param, qerr.detail)
+		return
+	}
+
+	lang, qerr := requestLanguage(r)
+
+	if qerr != nil {
+		writeInvalidParameter(w, r, qerr.param, qerr.detail)
+		return
+	}
+
+	hits := []searchHit{}
+
+	for _, c := range app.categories {
+		found, err := c.search(r.Context(), text, lang, limit)
+
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		hits = append(hits, found...)
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Score > hits[j].Score
+	})
+
+	if int64(len(hits)) > limit {
+		hits = hits[:limit]
+	}
+
+	writeLanguage(w, lang)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"query": text,
+		"data":  hits,
+	})
+}
+
+// autocomplete - sugere nomes de plantas de todas as categorias a partir de um prefixo
+func (app *App) autocomplete(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	prefix := strings.TrimSpace(query.Get("prefix"))
+
+	if prefix == "" {
+		writeInvalidParameter(w, r, "prefix", "Informe o início do nome no parâmetro 'prefix'")
+		return
+	}
+
+	limit := defaultSuggestions
+
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+
+		if err != nil || n < 1 || n > maxSuggestions {
+			writeInvalidParameter(w, r, "limit", "O parâmetro 'limit' deve ser um inteiro entre 1 e "+strconv.Itoa(maxSuggestions))
+			return
+		}
+
+		limit = n
+	}
+
+	suggestions := app.names.Suggest(prefix, limit)
+
+	if suggestions == nil {
+		suggestions = []crud.Suggestion{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"prefix": prefix,
+		"data":   suggestions,
+	})
+}
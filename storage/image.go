@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+// MaxPixels - limite de pixels das imagens enviadas, contra imagens que ocupam
+// pouco em disco mas exigem muita memória ao serem decodificadas
+const MaxPixels = 40_000_000
+
+var (
+	// ErrUnsupportedImage - formato de imagem não aceito
+	ErrUnsupportedImage = errors.New("formato de imagem não suportado")
+	// ErrInvalidImage - conteúdo que não pode ser decodificado ou excede MaxPixels
+	ErrInvalidImage = errors.New("imagem inválida")
+)
+
+// ImageTypes - formatos aceitos e a extensão de arquivo de cada um
+var ImageTypes = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+// ThumbnailSizes - larguras máximas das miniaturas geradas para cada imagem
+var ThumbnailSizes = map[string]int{
+	"small":  160,
+	"medium": 480,
+	"large":  1024,
+}
+
+// Rendition - arquivo codificado de uma imagem (original ou miniatura)
+type Rendition struct {
+	Data        []byte
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+}
+
+// ProcessImage - valida a imagem enviada e gera as miniaturas de ThumbnailSizes
+//
+// O tipo é detectado pelo conteúdo, não pelo cabeçalho do cliente. As
+// miniaturas de imagens PNG e GIF são PNG, para preservar a transparência; as
+// demais são JPEG. Imagens menores que o tamanho da miniatura não são ampliadas.
+func ProcessImage(data []byte) (original Rendition, thumbnails map[string]Rendition, err error) {
+	contentType := http.DetectContentType(data)
+	ext, ok := ImageTypes[contentType]
+	if !ok {
+		return Rendition{}, nil, ErrUnsupportedImage
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width*config.Height > MaxPixels {
+		return Rendition{}, nil, ErrInvalidImage
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Rendition{}, nil, ErrInvalidImage
+	}
+
+	original = Rendition{Data: data, ContentType: contentType, Ext: ext, Width: config.Width, Height: config.Height}
+
+	src := toRGBA(img)
+	thumbnails = map[string]Rendition{}
+
+	for name, width := range ThumbnailSizes {
+		thumb := resize(src, width)
+
+		var buf bytes.Buffer
+		rendition := Rendition{Width: thumb.Bounds().Dx(), Height: thumb.Bounds().Dy()}
+
+		if contentType == "image/jpeg" {
+			err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85})
+			rendition.ContentType, rendition.Ext = "image/jpeg", "jpg"
+		} else {
+			err = png.Encode(&buf, thumb)
+			rendition.ContentType, rendition.Ext = "image/png", "png"
+		}
+
+		if err != nil {
+			return Rendition{}, nil, err
+		}
+
+		rendition.Data = buf.Bytes()
+		thumbnails[name] = rendition
+	}
+
+	return original, thumbnails, nil
+}
+
+// toRGBA - converte a imagem para RGBA (cores pré-multiplicadas), base do redimensionamento
+func toRGBA(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	return rgba
+}
+
+// resize - reduz a imagem à largura máxima, mantendo a proporção, pela média dos
+// pixels de origem cobertos por cada pixel de destino
+func resize(src *image.RGBA, maxWidth int) *image.RGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	if sw <= maxWidth {
+		return src
+	}
+
+	dw := maxWidth
+	dh := sh * dw / sw
+	if dh < 1 {
+		dh = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*sh/dh, (y+1)*sh/dh
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*sw/dw, (x+1)*sw/dw
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride+x0*4 : sy*src.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += int(row[i])
+					sum[1] += int(row[i+1])
+					sum[2] += int(row[i+2])
+					sum[3] += int(row[i+3])
+				}
+			}
+
+			n := (y1 - y0) * (x1 - x0)
+			p := dst.Pix[y*dst.Stride+x*4:]
+			for i := range sum {
+				p[i] = uint8(sum[i] / n)
+			}
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore - armazenamento em diretório local, servido pela própria API
+type LocalStore struct {
+	root    string
+	baseURL string
+	files   http.Handler
+}
+
+// NewLocalStore - cria o armazenamento em root, publicado no prefixo baseURL
+func NewLocalStore(root, baseURL string) (*LocalStore, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &LocalStore{
+		root:    root,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		files:   http.FileServer(http.Dir(root)),
+	}, nil
+}
+
+// Put - grava o arquivo em um temporário e o renomeia, para nunca servir arquivos incompletos
+func (s *LocalStore) Put(_ context.Context, key string, data []byte, _ string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
+
+// DeleteAll - remove o diretório e todo o seu conteúdo
+func (s *LocalStore) DeleteAll(_ context.Context, dir string) error {
+	name, err := s.path(dir)
+	if err != nil {
+		return err
+	}
+
+	return os.RemoveAll(name)
+}
+
+// URL - endereço do arquivo sob o prefixo público
+func (s *LocalStore) URL(key string) string {
+	return s.baseURL + "/" + strings.Trim(key, "/")
+}
+
+// Prefix - caminho do prefixo público, onde ServeHTTP deve ser registrado
+func (s *LocalStore) Prefix() string {
+	u, err := url.Parse(s.baseURL)
+	if err != nil {
+		return "/"
+	}
+	return strings.TrimSuffix(u.Path, "/") + "/"
+}
+
+// ServeHTTP - serve os arquivos; o caminho da requisição deve estar sem o prefixo público
+func (s *LocalStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// sem listagem de diretórios
+	if strings.HasSuffix(r.URL.Path, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	s.files.ServeHTTP(w, r)
+}
+
+func (s *LocalStore) path(key string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
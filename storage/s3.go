@@ -0,0 +1,260 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config - configuração de um armazenamento compatível com S3 (AWS, MinIO etc.)
+type S3Config struct {
+	// Endpoint - endereço do serviço, como https://s3.sa-east-1.amazonaws.com
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PublicURL - prefixo público dos arquivos; padrão Endpoint/Bucket
+	PublicURL string
+}
+
+// S3Store - armazenamento compatível com S3, com requisições assinadas (AWS Signature V4)
+// e endereçamento por caminho (Endpoint/Bucket/chave)
+type S3Store struct {
+	config S3Config
+	client *http.Client
+}
+
+// NewS3Store - cria o armazenamento S3 com a configuração informada
+func NewS3Store(config S3Config) (*S3Store, error) {
+	if config.Endpoint == "" || config.Bucket == "" || config.AccessKey == "" || config.SecretKey == "" {
+		return nil, errors.New("S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY e S3_SECRET_KEY são obrigatórios")
+	}
+
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+
+	config.Endpoint = strings.TrimSuffix(config.Endpoint, "/")
+
+	if config.PublicURL == "" {
+		config.PublicURL = config.Endpoint + "/" + config.Bucket
+	}
+	config.PublicURL = strings.TrimSuffix(config.PublicURL, "/")
+
+	return &S3Store{config: config, client: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+// Put - envia o arquivo com PutObject
+func (s *S3Store) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	key, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+
+	_, err = s.do(ctx, http.MethodPut, key, nil, header, data)
+	return err
+}
+
+// DeleteAll - lista os objetos do diretório com ListObjectsV2 e os remove um a um
+func (s *S3Store) DeleteAll(ctx context.Context, dir string) error {
+	dir, err := cleanKey(dir)
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{"list-type": {"2"}, "prefix": {dir + "/"}}
+
+	for {
+		body, err := s.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return err
+		}
+
+		var result struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return err
+		}
+
+		for _, object := range result.Contents {
+			if _, err := s.do(ctx, http.MethodDelete, object.Key, nil, nil, nil); err != nil {
+				return err
+			}
+		}
+
+		if !result.IsTruncated {
+			return nil
+		}
+
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+// URL - endereço público do objeto
+func (s *S3Store) URL(key string) string {
+	return s.config.PublicURL + "/" + escapePath(strings.Trim(key, "/"))
+}
+
+// do - executa uma requisição assinada sobre o bucket e devolve o corpo da resposta
+func (s *S3Store) do(ctx context.Context, method, key string, query url.Values, header http.Header, payload []byte) ([]byte, error) {
+	uri := "/" + s.config.Bucket
+	if key != "" {
+		uri += "/" + key
+	}
+
+	endpoint, err := url.Parse(s.config.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	basePath := strings.TrimSuffix(endpoint.Path, "/")
+
+	req, err := http.NewRequestWithContext(ctx, method, s.config.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	req.URL.Path = basePath + uri
+	req.URL.RawPath = escapePath(basePath + uri)
+	req.URL.RawQuery = canonicalQuery(query)
+	req.ContentLength = int64(len(payload))
+
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	s.sign(req, payload, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("S3 %s %s: %s: %s", method, uri, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return body, nil
+}
+
+// sign - adiciona o cabeçalho Authorization conforme a AWS Signature V4
+func (s *S3Store) sign(req *http.Request, payload []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+
+	var headers strings.Builder
+	for _, name := range names {
+		headers.WriteString(name + ":" + strings.TrimSpace(req.Header.Get(name)) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		headers.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.config.Region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretKey), date)
+	for _, part := range []string{s.config.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Del("Host")
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery - parâmetros ordenados e codificados como exige a assinatura
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		for _, value := range query[key] {
+			parts = append(parts, escape(key)+"="+escape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// escapePath - codifica cada segmento do caminho, mantendo as barras
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// escape - codificação de URI da AWS: somente letras, dígitos e "-._~" ficam sem escape
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"strings"
+)
+
+// ErrInvalidKey - chave fora do formato aceito pelos armazenamentos
+var ErrInvalidKey = errors.New("chave de arquivo inválida")
+
+// BlobStore - armazenamento de arquivos usado pelas imagens das plantas
+//
+// As chaves usam "/" como separador; um diretório é o prefixo comum das chaves
+// de um mesmo conjunto de arquivos (por exemplo, "fruits/<id>/<imagem>").
+type BlobStore interface {
+	// Put - grava o arquivo, substituindo o existente com a mesma chave
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// DeleteAll - remove todos os arquivos dentro do diretório
+	DeleteAll(ctx context.Context, dir string) error
+	// URL - endereço público do arquivo
+	URL(key string) string
+}
+
+// NewFromEnv - cria o armazenamento configurado nas variáveis de ambiente
+//
+// BLOB_STORE=s3 usa S3_ENDPOINT, S3_REGION, S3_BUCKET, S3_ACCESS_KEY,
+// S3_SECRET_KEY e S3_PUBLIC_URL; qualquer outro valor grava em MEDIA_DIR
+// (padrão "media") e publica em MEDIA_URL (padrão http://localhost:$PORT/media).
+func NewFromEnv() (BlobStore, error) {
+	if os.Getenv("BLOB_STORE") == "s3" {
+		return NewS3Store(S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			PublicURL: os.Getenv("S3_PUBLIC_URL"),
+		})
+	}
+
+	return NewLocalStore(getenv("MEDIA_DIR", "media"), getenv("MEDIA_URL", "http://localhost:"+os.Getenv("PORT")+"/media"))
+}
+
+// cleanKey - valida a chave e remove barras das extremidades
+func cleanKey(key string) (string, error) {
+	key = strings.Trim(key, "/")
+
+	if key == "" || path.Clean(key) != key || strings.HasPrefix(key, "..") {
+		return "", ErrInvalidKey
+	}
+
+	return key, nil
+}
+
+func getenv(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"rastros-da-mata/crud"
+	"sort"
+	"strings"
+)
+
+// plantRef - identificação resumida de um documento em qualquer categoria
+type plantRef struct {
+	Category string         `json:"category"`
+	ID       string         `json:"id"`
+	Name     string         `json:"name"`
+	Taxonomy *crud.Taxonomy `json:"taxonomy,omitempty"`
+}
+
+// lookupHit - documento encontrado pelo nome principal ou por um sinônimo
+type lookupHit struct {
+	plantRef
+	// MatchedName - nome que correspondeu à consulta
+	MatchedName    string            `json:"matched_name"`
+	ScientificName string            `json:"scientific_name,omitempty"`
+	CommonNames    []crud.CommonName `json:"common_names,omitempty"`
+}
+
+// duplicateGroup - documentos que compartilham a mesma espécie
+type duplicateGroup struct {
+	ScientificName string     `json:"scientific_name"`
+	Documents      []plantRef `json:"documents"`
+}
+
+// lookup - documentos da categoria com o nome principal ou popular informado
+func (res *resource[T, PT]) lookup(ctx context.Context, name string) ([]*crud.Plant, error) {
+	docs, err := res.store.FindByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	plants := make([]*crud.Plant, 0, len(docs))
+	for i := range docs {
+		if plant := PT(&docs[i]).PlantData(); plant.Published() {
+			plants = append(plants, plant)
+		}
+	}
+	return plants, nil
+}
+
+// eachPlant - percorre os dados comuns dos documentos da categoria que atendem ao filtro
+func (res *resource[T, PT]) eachPlant(ctx context.Context, filter []crud.Condition, fields []string, fn func(plant *crud.Plant) error) error {
+	return res.each(ctx, filter, fields, func(doc *T) error {
+		return fn(PT(doc).PlantData())
+	})
+}
+
+// lookupName - busca em todas as categorias pelo nome principal ou por qualquer sinônimo regional
+func (app *App) lookupName(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSpace(r.URL.Query().Get("name"))
+
+	if name == "" {
+		writeInvalidParameter(w, r, "name", "Informe o nome no parâmetro 'name'")
+		return
+	}
+
+	hits := []lookupHit{}
+
+	for _, c := range app.categories {
+		plants, err := c.lookup(r.Context(), name)
+
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		for _, plant := range plants {
+			matched, _ := plant.MatchName(name)
+			hits = append(hits, lookupHit{
+				plantRef:       plantRef{Category: c.name(), ID: plant.ID.Hex(), Name: plant.Name, Taxonomy: plant.Taxonomy},
+				MatchedName:    matched,
+				ScientificName: plant.Taxonomy.ScientificName(),
+				CommonNames:    plant.CommonNames,
+			})
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"name": name,
+		"data": hits,
+	})
+}
+
+// duplicates - relatório dos documentos de todas as categorias que compartilham gênero e espécie
+func (app *App) duplicates(w http.ResponseWriter, r *http.Request) {
+	groups := map[string]*duplicateGroup{}
+
+	// somente documentos com espécie informada
+	filter := []crud.Condition{{Field: "taxonomy.species", Op: crud.OpGte, Value: ""}, publishedFilter}
+	fields := []string{"name", "taxonomy"}
+
+	for _, c := range app.categories {
+		category := c.name()
+
+		err := c.eachPlant(r.Context(), filter, fields, func(plant *crud.Plant) error {
+			key := plant.Taxonomy.SpeciesKey()
+			if key == "" {
+				return nil
+			}
+
+			group, ok := groups[key]
+			if !ok {
+				group = &duplicateGroup{ScientificName: plant.Taxonomy.ScientificName()}
+				groups[key] = group
+			}
+			group.Documents = append(group.Documents, plantRef{Category: category, ID: plant.ID.Hex(), Name: plant.Name, Taxonomy: plant.Taxonomy})
+			return nil
+		})
+
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+
+	report := []duplicateGroup{}
+
+	for _, group := range groups {
+		if len(group.Documents) > 1 {
+			report = append(report, *group)
+		}
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].ScientificName < report[j].ScientificName
+	})
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"data": report,
+	})
+}
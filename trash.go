@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"log"
+	"net/http"
+	"rastros-da-mata/auth"
+	"rastros-da-mata/crud"
+	"sort"
+	"time"
+)
+
+// trashParams - parâmetros aceitos na listagem da lixeira
+var trashParams = map[string]bool{"category": true, "limit": true}
+
+// trashItem - documento da lixeira, identificando a categoria
+type trashItem struct {
+	Category  string    `json:"category"`
+	ID        string    `json:"id"`
+	Version   int64     `json:"version"`
+	Name      string    `json:"name"`
+	DeletedAt time.Time `json:"deleted_at"`
+	DeletedBy string    `json:"deleted_by,omitempty"`
+	// PurgeAt - momento a partir do qual o documento pode ser removido definitivamente
+	PurgeAt *time.Time `json:"purge_at,omitempty"`
+}
+
+// trash - documentos da lixeira da categoria, dos removidos mais recentemente aos mais antigos
+func (res *resource[T, PT]) trash(ctx context.Context, limit int64) ([]trashItem, error) {
+	docs, err := res.store.Trash(ctx, crud.ListOptions{
+		Sort:   []crud.SortField{{Field: "deleted_at", Desc: true}},
+		Fields: []string{"name", "version", "deleted_at", "deleted_by"},
+		Limit:  limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]trashItem, len(docs))
+	for i := range docs {
+		plant := PT(&docs[i]).PlantData()
+		items[i] = trashItem{
+			Category:  res.category,
+			ID:        plant.ID.Hex(),
+			Version:   plant.Version,
+			Name:      plant.Name,
+			DeletedAt: *plant.DeletedAt,
+			DeletedBy: plant.DeletedBy,
+		}
+	}
+	return items, nil
+}
+
+// restore - devolve o documento da lixeira, com a mesma verificação de versão da remoção
+func (res *resource[T, PT]) restore(w http.ResponseWriter, r *http.Request) {
+	if !res.auth.allow(w, r, auth.ActionDelete, res.category) {
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+
+	if err != nil {
+		writeInvalidID(w, r)
+		return
+	}
+
+	version, ok, err := ifMatchVersion(r, res.trashedVersion(r, id))
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if !ok {
+		writePreconditionFailed(w, r)
+		return
+	}
+
+	restored, err := res.store.Restore(r.Context(), id, version)
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	res.writeDocument(w, http.StatusOK, restored)
+}
+
+// trashedVersion - consulta a versão do documento na lixeira, para o If-Match com várias ETags
+func (res *resource[T, PT]) trashedVersion(r *http.Request, id primitive.ObjectID) func() (int64, error) {
+	return func() (int64, error) {
+		docs, err := res.store.Trash(r.Context(), crud.ListOptions{
+			Filter: []crud.Condition{{Field: "_id", Op: crud.OpEq, Value: id}},
+			Fields: []string{"version"},
+			Limit:  1,
+		})
+		if err != nil {
+			return 0, err
+		}
+		if len(docs) == 0 {
+			return 0, crud.ErrNotFound
+		}
+		return PT(&docs[0]).PlantData().Version, nil
+	}
+}
+
+// purge - remove definitivamente os documentos da lixeira anteriores ao momento informado e suas imagens
+func (res *resource[T, PT]) purge(ctx context.Context, before time.Time) (int, error) {
+	purged, err := res.store.Purge(crud.WithActor(ctx, crud.System), before)
+
+	for i := range purged {
+		res.deleteImages(ctx, res.imageDir(PT(&purged[i]).PlantData().ID))
+	}
+
+	return len(purged), err
+}
+
+// listTrash - documentos da lixeira das categorias em que o token pode remover
+//
+// O filtro ?category= restringe a uma categoria; ?limit= vale para o total.
+func (app *App) listTrash(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	for name := range query {
+		if !trashParams[name] {
+			writeInvalidParameter(w, r, name, "Parâmetro desconhecido")
+			return
+		}
+	}
+
+	limit, qerr := pageSize(query)
+
+	if qerr != nil {
+		writeInvalidParameter(w, r, qerr.param, qerr.detail)
+		return
+	}
+
+	categories := app.categories
+
+	if name := query.Get("category"); name != "" {
+		c := app.category(name)
+
+		if c == nil {
+			writeInvalidParameter(w, r, "category", "Categoria desconhecida")
+			return
+		}
+
+		if !app.auth.allow(w, r, auth.ActionDelete, name) {
+			return
+		}
+
+		categories = []category{c}
+	} else if _, ok := auth.FromContext(r.Context()); app.auth != nil && !ok {
+		writeUnauthorized(w, r, "", "Envie um token no cabeçalho Authorization: Bearer")
+		return
+	}
+
+	items := []trashItem{}
+
+	for _, c := range categories {
+		if !app.auth.permits(r, auth.ActionDelete, c.name()) {
+			continue
+		}
+
+		found, err := c.trash(r.Context(), limit)
+
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		items = append(items, found...)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].DeletedAt.After(items[j].DeletedAt) })
+
+	if int64(len(items)) > limit {
+		items = items[:limit]
+	}
+
+	if app.trashRetention > 0 {
+		for i := range items {
+			at := items[i].DeletedAt.Add(app.trashRetention)
+			items[i].PurgeAt = &at
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"data": items, "limit": limit})
+}
+
+// purgeTrash - remove definitivamente os documentos que passaram do tempo de retenção na lixeira
+func (app *App) purgeTrash(ctx context.Context) {
+	before := time.Now().Add(-app.trashRetention)
+
+	for _, c := range app.categories {
+		n, err := c.purge(ctx, before)
+
+		if err != nil {
+			log.Printf("Erro ao limpar a lixeira de %s: %v", c.name(), err)
+		}
+
+		if n > 0 {
+			log.Printf("%s: %d documentos removidos definitivamente da lixeira", c.name(), n)
+		}
+	}
+}
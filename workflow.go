@@ -0,0 +1,351 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"log"
+	"net/http"
+	"rastros-da-mata/auth"
+	"rastros-da-mata/crud"
+	"strings"
+	"time"
+)
+
+// statusRequest - corpo do PUT /{id}/status
+type statusRequest struct {
+	Status string `json:"status" validate:"required,oneof=draft in_review scheduled published archived"`
+}
+
+// reviewRequest - corpo da aprovação e da rejeição
+type reviewRequest struct {
+	Comment string `json:"comment" validate:"max=2000"`
+	// PublishAt - publicação agendada; vazio ou no passado publica imediatamente (somente na aprovação)
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+// publishedFilter - condição dos documentos visíveis ao público
+var publishedFilter = crud.Condition{Field: "status", Op: crud.OpIn, Value: crud.PublishedValues}
+
+// editor - indica se o token edita a categoria e vê os dados editoriais dos documentos
+func (res *resource[T, PT]) editor(r *http.Request) bool {
+	return res.auth.permits(r, auth.ActionUpdate, res.category)
+}
+
+// reviewer - indica se o token revisa e publica a categoria, vendo e alterando qualquer documento
+func (res *resource[T, PT]) reviewer(r *http.Request) bool {
+	return res.auth.permits(r, auth.ActionPublish, res.category)
+}
+
+// author - autor dos documentos não publicados que o token vê; vazio quando ele só vê os publicados
+func (res *resource[T, PT]) author(r *http.Request) string {
+	if !res.editor(r) {
+		return ""
+	}
+	return crud.Actor(r.Context())
+}
+
+// visible - indica se o token vê o documento: os publicados são públicos, os demais
+// são vistos pelos revisores e, entre os editores, somente pelo autor
+func (res *resource[T, PT]) visible(r *http.Request, plant *crud.Plant) bool {
+	if plant.Published() || res.reviewer(r) {
+		return true
+	}
+
+	author := res.author(r)
+	return author != "" && plant.CreatedBy == author
+}
+
+// visibleFilter - restringe o filtro aos documentos que o token vê (ver visible)
+func (res *resource[T, PT]) visibleFilter(r *http.Request, filter []crud.Condition) []crud.Condition {
+	if res.reviewer(r) {
+		return filter
+	}
+
+	author := res.author(r)
+
+	if author == "" {
+		return onlyPublished(filter)
+	}
+
+	return append(append([]crud.Condition(nil), filter...), crud.Condition{Op: crud.OpOr, Value: [][]crud.Condition{
+		{publishedFilter},
+		{{Field: "created_by", Op: crud.OpEq, Value: author}},
+	}})
+}
+
+// readVisible - lê o documento, respondendo 404 quando ele não existe ou o token não o vê;
+// quando ok é false, a resposta de erro já foi escrita
+func (res *resource[T, PT]) readVisible(w http.ResponseWriter, r *http.Request, id primitive.ObjectID) (doc *T, ok bool) {
+	doc, err := res.store.Read(r.Context(), id)
+
+	if err == nil && !res.visible(r, PT(doc).PlantData()) {
+		err = crud.ErrNotFound
+	}
+
+	if err != nil {
+		writeError(w, r, err)
+		return nil, false
+	}
+
+	return doc, true
+}
+
+// canEdit - indica se o token altera o conteúdo do documento: os revisores alteram qualquer
+// documento e os demais editores somente os próprios rascunhos, para que nada chegue ao
+// público sem revisão; escreve o erro quando não pode
+func (res *resource[T, PT]) canEdit(w http.ResponseWriter, r *http.Request, plant *crud.Plant) bool {
+	if reason := res.editDenied(r, plant); reason != "" {
+		writeProblem(w, r, newProblem(http.StatusForbidden, codeForbidden, reason))
+		return false
+	}
+	return true
+}
+
+// editDenied - motivo pelo qual o token não altera o conteúdo do documento, ou vazio (ver canEdit)
+func (res *resource[T, PT]) editDenied(r *http.Request, plant *crud.Plant) string {
+	if res.reviewer(r) {
+		return ""
+	}
+
+	if status := plant.EditorialStatus(); status != crud.StatusDraft {
+		return fmt.Sprintf("O documento está em %s; somente revisores alteram documentos fora de draft", status)
+	}
+
+	if plant.CreatedBy != res.author(r) {
+		return "Somente o autor altera o rascunho"
+	}
+
+	return ""
+}
+
+// publicView - remove os dados editoriais que só interessam à equipe
+func publicView(plant *crud.Plant) {
+	plant.Reviews = nil
+	plant.CreatedBy = ""
+}
+
+// onlyPublished - substitui os filtros de status pela condição dos documentos publicados
+func onlyPublished(filter []crud.Condition) []crud.Condition {
+	result := make([]crud.Condition, 0, len(filter)+1)
+	for _, c := range filter {
+		if c.Field != publishedFilter.Field {
+			result = append(result, c)
+		}
+	}
+	return append(result, publishedFilter)
+}
+
+// setStatus - muda a situação editorial do documento conforme crud.Transitions
+//
+// Tirar um documento de publicação exige a permissão de publicar; enviar para a
+// publicação só é possível pela aprovação (ver approve).
+func (res *resource[T, PT]) setStatus(w http.ResponseWriter, r *http.Request) {
+	if !res.auth.allow(w, r, auth.ActionUpdate, res.category) {
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+
+	if err != nil {
+		writeInvalidID(w, r)
+		return
+	}
+
+	var body statusRequest
+
+	if !decodeBody(w, r, &body) {
+		return
+	}
+
+	res.transition(w, r, id, func(plant *crud.Plant) (crud.Patch, bool) {
+		from := plant.EditorialStatus()
+
+		if from == crud.StatusPublished && !res.auth.allow(w, r, auth.ActionPublish, res.category) {
+			return crud.Patch{}, false
+		}
+
+		if !crud.CanTransition(from, body.Status) {
+			writeInvalidTransition(w, r, from, body.Status)
+			return crud.Patch{}, false
+		}
+
+		return crud.StatusPatch(body.Status, nil), true
+	})
+}
+
+// approve - aprova o documento em revisão, publicando-o agora ou agendando-o para o publish_at
+func (res *resource[T, PT]) approve(w http.ResponseWriter, r *http.Request) {
+	res.review(w, r, crud.ReviewApproved)
+}
+
+// reject - devolve o documento em revisão para rascunho, com o comentário obrigatório
+func (res *resource[T, PT]) reject(w http.ResponseWriter, r *http.Request) {
+	res.review(w, r, crud.ReviewRejected)
+}
+
+// review - registra o parecer do revisor e muda a situação do documento em revisão
+func (res *resource[T, PT]) review(w http.ResponseWriter, r *http.Request, decision string) {
+	if !res.auth.allow(w, r, auth.ActionPublish, res.category) {
+		return
+	}
+
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+
+	if err != nil {
+		writeInvalidID(w, r)
+		return
+	}
+
+	var body reviewRequest
+
+	if !decodeBody(w, r, &body) {
+		return
+	}
+
+	var fields []crud.FieldError
+
+	if decision == crud.ReviewRejected && strings.TrimSpace(body.Comment) == "" {
+		fields = append(fields, crud.FieldError{Field: "comment", Code: "required", Message: "explique o motivo da rejeição"})
+	}
+
+	if decision == crud.ReviewRejected && body.PublishAt != nil {
+		fields = append(fields, crud.FieldError{Field: "publish_at", Code: "not_allowed", Message: "só pode ser informado na aprovação"})
+	}
+
+	if len(fields) > 0 {
+		writeError(w, r, &crud.ValidationError{Fields: fields})
+		return
+	}
+
+	now := time.Now().UTC()
+
+	review := crud.Review{
+		Decision: decision,
+		Comment:  strings.TrimSpace(body.Comment),
+		Reviewer: crud.Actor(r.Context()),
+		At:       now,
+	}
+
+	res.transition(w, r, id, func(plant *crud.Plant) (crud.Patch, bool) {
+		if from := plant.EditorialStatus(); from != crud.StatusInReview {
+			writeProblem(w, r, newProblem(http.StatusConflict, codeInvalidTransition, "Somente documentos em in_review podem ser aprovados ou rejeitados; o documento está em "+from))
+			return crud.Patch{}, false
+		}
+
+		if decision == crud.ReviewRejected {
+			return crud.ReviewPatch(plant.Reviews, review, crud.StatusDraft, nil), true
+		}
+
+		// a aprovação agendada aguarda até a publicação (ver publishDue)
+		if body.PublishAt != nil && body.PublishAt.After(now) {
+			return crud.ReviewPatch(plant.Reviews, review, crud.StatusScheduled, body.PublishAt), true
+		}
+
+		return crud.ReviewPatch(plant.Reviews, review, crud.StatusPublished, &now), true
+	})
+}
+
+// transition - aplica o Patch montado a partir do estado atual, com a verificação de If-Match;
+// edit escreve a resposta de erro e retorna false quando a mudança não é permitida
+func (res *resource[T, PT]) transition(w http.ResponseWriter, r *http.Request, id primitive.ObjectID, edit func(plant *crud.Plant) (crud.Patch, bool)) {
+	current, ok := res.readVisible(w, r, id)
+
+	if !ok {
+		return
+	}
+
+	plant := PT(current).PlantData()
+
+	version, ok, err := ifMatchVersion(r, func() (int64, error) { return plant.Version, nil })
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if !ok || (version != crud.AnyVersion && version != plant.Version) {
+		writePreconditionFailed(w, r)
+		return
+	}
+
+	patch, ok := edit(plant)
+
+	if !ok {
+		return
+	}
+
+	updated, err := res.store.Patch(r.Context(), id, patch, plant.Version)
+
+	if errors.Is(err, crud.ErrVersionMismatch) && version == crud.AnyVersion {
+		err = fmt.Errorf("%w: %v", crud.ErrConflict, err)
+	}
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	res.writeDocument(w, http.StatusOK, updated)
+}
+
+// writeInvalidTransition - responde 409 com as situações permitidas a partir da atual
+func writeInvalidTransition(w http.ResponseWriter, r *http.Request, from, to string) {
+	detail := fmt.Sprintf("O documento está em %s e não pode passar para %s", from, to)
+
+	if allowed := crud.Transitions[from]; len(allowed) > 0 {
+		detail += "; permitido: " + strings.Join(allowed, ", ")
+	}
+
+	writeProblem(w, r, newProblem(http.StatusConflict, codeInvalidTransition, detail))
+}
+
+// publishDue - publica os documentos aprovados cuja publicação agendada já chegou
+func (res *resource[T, PT]) publishDue(ctx context.Context, now time.Time) (int, error) {
+	docs, err := res.store.List(ctx, crud.ListOptions{Filter: []crud.Condition{
+		{Field: "status", Op: crud.OpEq, Value: crud.StatusScheduled},
+		{Field: "publish_at", Op: crud.OpLte, Value: now},
+	}})
+	if err != nil {
+		return 0, err
+	}
+
+	ctx = crud.WithActor(ctx, crud.System)
+	published := 0
+
+	for i := range docs {
+		plant := PT(&docs[i]).PlantData()
+
+		_, err := res.store.Patch(ctx, plant.ID, crud.StatusPatch(crud.StatusPublished, plant.PublishAt), plant.Version)
+
+		switch {
+		// alterado ou removido depois da consulta; a próxima rodada decide
+		case errors.Is(err, crud.ErrVersionMismatch), errors.Is(err, crud.ErrNotFound):
+		case err != nil:
+			return published, err
+		default:
+			published++
+		}
+	}
+
+	return published, nil
+}
+
+// publishScheduled - publica os documentos agendados de todas as categorias
+func (app *App) publishScheduled(ctx context.Context) {
+	now := time.Now()
+
+	for _, c := range app.categories {
+		n, err := c.publishDue(ctx, now)
+
+		if err != nil {
+			log.Printf("Erro ao publicar os agendamentos de %s: %v", c.name(), err)
+		}
+
+		if n > 0 {
+			log.Printf("%s: %d documentos agendados publicados", c.name(), n)
+		}
+	}
+}
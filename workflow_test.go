@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"rastros-da-mata/crud"
+	"testing"
+	"time"
+)
+
+func TestWorkflowDraftVisibility(t *testing.T) {
+	s := newTestServer(t)
+
+	ana, bia := s.token("ana", "editor"), s.token("bia", "editor")
+	reviewer := s.token("rui", "reviewer")
+
+	id := s.create(ana, map[string]interface{}{"name": "Pitanga"})
+	path := "/api/fruits/" + id
+
+	for _, tt := range []struct {
+		who   string
+		token string
+		want  int
+	}{
+		{"autor", ana, http.StatusOK},
+		{"outro editor", bia, http.StatusNotFound},
+		{"revisor", reviewer, http.StatusOK},
+		{"anônimo", "", http.StatusNotFound},
+	} {
+		status, body := s.json("GET", path, tt.token, nil)
+		expectStatus(t, "GET como "+tt.who, status, tt.want, body)
+	}
+
+	status, body := s.json("PATCH", path, bia, map[string]interface{}{"description": "doce"}, "Content-Type", mediaMergePatch)
+	expectStatus(t, "PATCH de outro editor", status, http.StatusNotFound, body)
+
+	s.create(bia, map[string]interface{}{"name": "Acerola"})
+
+	status, body = s.json("GET", "/api/fruits", bia, nil)
+	expectStatus(t, "GET /api/fruits", status, http.StatusOK, body)
+
+	data := body["data"].([]interface{})
+	if len(data) != 1 || data[0].(map[string]interface{})["name"] != "Acerola" {
+		t.Errorf("o editor deveria listar somente o próprio rascunho: %v", data)
+	}
+}
+
+func TestWorkflowEditGating(t *testing.T) {
+	s := newTestServer(t)
+
+	ana, reviewer := s.token("ana", "editor"), s.token("rui", "reviewer")
+
+	id := s.create(ana, map[string]interface{}{"name": "Pitanga"})
+	path := "/api/fruits/" + id
+
+	status, body := s.json("PUT", path+"/status", ana, map[string]interface{}{"status": crud.StatusInReview})
+	expectStatus(t, "envio para revisão", status, http.StatusOK, body)
+
+	status, body = s.json("PATCH", path, ana, map[string]interface{}{"description": "doce"}, "Content-Type", mediaMergePatch)
+	expectStatus(t, "PATCH do autor em revisão", status, http.StatusForbidden, body)
+
+	status, body = s.json("PUT", path, ana, map[string]interface{}{"name": "Pitanga-roxa"})
+	expectStatus(t, "PUT do autor em revisão", status, http.StatusForbidden, body)
+
+	status, body = s.json("PATCH", path, reviewer, map[string]interface{}{"description": "doce"}, "Content-Type", mediaMergePatch)
+	expectStatus(t, "PATCH do revisor", status, http.StatusOK, body)
+
+	status, body = s.json("POST", path+"/approve", ana, map[string]interface{}{})
+	expectStatus(t, "aprovação pelo editor", status, http.StatusForbidden, body)
+
+	status, body = s.json("POST", path+"/reject", reviewer, map[string]interface{}{})
+	expectStatus(t, "rejeição sem comentário", status, http.StatusUnprocessableEntity, body)
+
+	status, body = s.json("POST", path+"/approve", reviewer, map[string]interface{}{})
+	expectStatus(t, "aprovação", status, http.StatusOK, body)
+
+	if body["status"] != crud.StatusPublished {
+		t.Errorf("status = %v, esperado published", body["status"])
+	}
+
+	status, body = s.json("GET", path, "", nil)
+	expectStatus(t, "GET anônimo do publicado", status, http.StatusOK, body)
+}
+
+func TestWorkflowScheduled(t *testing.T) {
+	s := newTestServer(t)
+
+	ana, reviewer := s.token("ana", "editor"), s.token("rui", "reviewer")
+
+	id := s.create(ana, map[string]interface{}{"name": "Pitanga"})
+	path := "/api/fruits/" + id
+
+	status, body := s.json("PUT", path+"/status", ana, map[string]interface{}{"status": crud.StatusInReview})
+	expectStatus(t, "envio para revisão", status, http.StatusOK, body)
+
+	publishAt := time.Now().Add(time.Hour).UTC()
+
+	status, body = s.json("POST", path+"/approve", reviewer, map[string]interface{}{"publish_at": publishAt})
+	expectStatus(t, "aprovação agendada", status, http.StatusOK, body)
+
+	if body["status"] != crud.StatusScheduled {
+		t.Fatalf("status = %v, esperado scheduled", body["status"])
+	}
+
+	status, body = s.json("GET", path, "", nil)
+	expectStatus(t, "GET anônimo do agendado", status, http.StatusNotFound, body)
+
+	// a edição depois da aprovação exige nova revisão
+	status, body = s.json("PATCH", path, reviewer, map[string]interface{}{"description": "doce"}, "Content-Type", mediaMergePatch)
+	expectStatus(t, "PATCH do agendado", status, http.StatusOK, body)
+
+	if body["status"] != crud.StatusInReview || body["publish_at"] != nil {
+		t.Errorf("status = %v, publish_at = %v; esperado in_review sem agendamento", body["status"], body["publish_at"])
+	}
+
+	status, body = s.json("POST", path+"/approve", reviewer, map[string]interface{}{"publish_at": publishAt})
+	expectStatus(t, "nova aprovação agendada", status, http.StatusOK, body)
+
+	n, err := s.app.categories[0].publishDue(context.Background(), publishAt.Add(-time.Minute))
+	if err != nil || n != 0 {
+		t.Fatalf("publishDue antes da data = %d, %v", n, err)
+	}
+
+	n, err = s.app.categories[0].publishDue(context.Background(), publishAt.Add(time.Minute))
+	if err != nil || n != 1 {
+		t.Fatalf("publishDue depois da data = %d, %v", n, err)
+	}
+
+	status, body = s.json("GET", path, "", nil)
+	expectStatus(t, "GET anônimo depois da publicação", status, http.StatusOK, body)
+}